@@ -1,98 +1,44 @@
 #!/bin/bash
+# Times the import and the tasks with `dbcli bench` inside the importer
+# container, so container and process startup are not part of the timings.
+# Extra arguments are passed to `dbcli bench`, e.g. --iterations 10 or
+# --task "largestNumberOfChildren=10". With BASELINE set to an earlier
+# report the new one is compared against it and the script fails on a
+# regression. CPU and memory of dbcli are sampled by dbcli itself; the
+# OrientDB server runs in its own container, so its PID is not visible to
+# --sample-pid here.
 
-captureStats() {
-  local task="$1"
-  local container="$2"
-  local log_file="docker_stats_$task.log"
-  local stop_file="stop_capture_$task"
+set -e
 
-  rm -f "$stop_file"
+container="importer"
 
-  while [ ! -f "$stop_file" ]; do
-    docker stats --no-stream --format "{{.Name}}: CPU {{.CPUPerc}} | Mem {{.MemUsage}} | Net {{.NetIO}} | Block {{.BlockIO}}" >> "$log_file" &
-    sleep 0.2
-  done
+warmup="${WARMUP:-1}"
+iterations="${ITERATIONS:-5}"
+results="${RESULTS:-bench}"
 
-  rm -f "$stop_file"
-}
-
-executeTask() {
-  local task="$1"
-  local container="$2"
-  local command="$3"
-  local iterations="${4:-5}"
-
-  echo "Executing task '$task' $iterations times for benchmarking..."
-
-  for ((i=1; i<=iterations; i++)); do
-    echo "Iteration $i for task '$task'..."
-    echo ""
-
-    local log_file="docker_stats_$task.log"
-    local stop_file="stop_capture_$task"
-    : > "$log_file"
-    
-    captureStats "$task" "$container" &
-    stats_pid=$!
-    
-    sleep 2
-    
-    start_time=$(date +%s%N)
-    echo "----------------------------------------------------------------------------------------"
-    docker exec -it "$container" /bin/bash -c "$command"
-    end_time=$(date +%s%N)
-    exec_time=$(((end_time - start_time) / 1000000))
-    
-    touch "$stop_file"
-    wait $stats_pid
-
-    echo "Iteration: $i: $exec_time ms"
-    echo ""
-    
-    sleep 2
-    
-    echo "Iteration: $i: $exec_time ms" >> "$log_file"
-    cat "$log_file" >> "$task"
-    echo >> "$task"
-    
-    echo "----------------------------------------------------------------------------------------"
-  done
-  echo "Finished executing task '$task' $iterations times."
-  rm "docker_stats_$task.log"
-  
-  echo "########################################################################################"
-  echo ""
-}
-
-# -----------------------------------------------------------------------------
-# Edit your commands here
-# -----------------------------------------------------------------------------
-
-importer="importer"
-app="app"
-db="orientdb"
-
-#preprocessingCommand=""
-importCommand='./dbcli import data'
-
-task10Command='./dbcli task 10'
-task16Command='./dbcli task 16 "Tourism_in_Uttarakhand" 6 6'
-task17Command='./dbcli task 17 "19th-century_works" "1887_directorial_debut_films" 6'
-task18Command='./dbcli task 18 "19th-century_works" "1887_directorial_debut_films" 5'
-
-# -----------------------------------------------------------------------------
-# Uncomment commands for tasks you want to run
-# -----------------------------------------------------------------------------
+importArgs=(--import data)
+if [ "${SKIP_IMPORT:-0}" = "1" ]; then
+  importArgs=()
+fi
 
 echo "Starting benchmarking..."
 
-#executeTask "preprocessing" "$importer" "$preprocessingCommand" 1
-executeTask "import" "$importer" "$importCommand" 1
-
-iterationsNumber=5
-executeTask "largestNumberOfChildren" "$app" "$task10Command" "$iterationsNumber"
-executeTask "neighborhoodPopularity" "$app" "$task16Command" "$iterationsNumber"
-executeTask "shortestPathPopularity" "$app" "$task17Command" "$iterationsNumber"
-executeTask "directPathWithHighPopularity" "$app" "$task18Command" "$iterationsNumber"
-
-echo "Benchmarking completed. Results saved to individual task files."
+docker exec "$container" ./dbcli bench \
+  "${importArgs[@]}" \
+  --warmup "$warmup" \
+  --iterations "$iterations" \
+  --json "/tmp/$results.json" \
+  --markdown "/tmp/$results.md" \
+  --resources-out "/tmp/$results-resources.json" \
+  "$@"
+
+docker cp "$container:/tmp/$results.json" "$results.json"
+docker cp "$container:/tmp/$results.md" "$results.md"
+docker cp "$container:/tmp/$results-resources.json" "$results-resources.json"
+
+echo "Benchmarking completed. Results saved to $results.json, $results.md and $results-resources.json."
+
+if [ -n "$BASELINE" ]; then
+  docker cp "$BASELINE" "$container:/tmp/baseline.json"
+  docker exec "$container" ./dbcli bench compare /tmp/baseline.json "/tmp/$results.json"
+fi
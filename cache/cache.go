@@ -0,0 +1,268 @@
+// Package cache keeps query results in memory or on disk with a TTL and
+// size limits. Entries belong to a generation: invalidating the cache moves
+// to the next generation, so results computed before a write are never
+// served after it. With a directory the generation lives in a file there,
+// which every process using the directory shares.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backends
+const (
+	Memory = "memory"
+	Disk   = "disk"
+)
+
+// generationFile holds the shared generation in the cache directory
+const generationFile = "generation"
+
+// Options configure a Cache
+type Options struct {
+	// Backend is Memory or Disk
+	Backend string
+	// Dir holds the shared generation and, for Disk, the entries. Without
+	// it a memory cache is only invalidated through its own Invalidate.
+	Dir string
+	// TTL is how long an entry is served, 0 for no limit
+	TTL time.Duration
+	// MaxEntries and MaxBytes bound the size, 0 for no limit; the least
+	// recently used entries are evicted first
+	MaxEntries int
+	MaxBytes   int64
+}
+
+// Stats counts the lookups of a cache since it was opened and describes
+// its current contents
+type Stats struct {
+	Backend       string  `json:"backend"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	HitRatio      float64 `json:"hitRatio"`
+	Expired       int64   `json:"expired"`
+	Evictions     int64   `json:"evictions"`
+	Invalidations int64   `json:"invalidations"`
+	Entries       int     `json:"entries"`
+	Bytes         int64   `json:"bytes"`
+	Generation    uint64  `json:"generation"`
+}
+
+// entry is a stored value
+type entry struct {
+	Generation uint64    `json:"generation"`
+	Stored     time.Time `json:"stored"`
+	Value      []byte    `json:"value"`
+}
+
+// store keeps entries by key in least recently used order
+type store interface {
+	get(key string) (entry, bool)
+	put(key string, e entry)
+	remove(key string)
+	clear()
+	size() (int, int64)
+	// evict drops the least recently used entries until both limits hold
+	// and returns how many it dropped
+	evict(maxEntries int, maxBytes int64) int
+}
+
+// Cache is a result cache. It is safe for concurrent use.
+type Cache struct {
+	opts  Options
+	mu    sync.Mutex
+	store store
+	// local is the generation of a cache without a directory
+	local uint64
+	// shared is the generation last read from the directory, and
+	// sharedFile the generation file it was read from
+	shared     uint64
+	sharedFile os.FileInfo
+
+	hits, misses, expired, evictions, invalidations int64
+}
+
+// Open returns a cache with the given options
+func Open(opts Options) (*Cache, error) {
+	c := &Cache{opts: opts}
+	switch opts.Backend {
+	case Memory:
+		c.store = newMemoryStore()
+	case Disk:
+		if opts.Dir == "" {
+			return nil, errors.New("disk cache needs a directory")
+		}
+		s, err := newDiskStore(filepath.Join(opts.Dir, "entries"))
+		if err != nil {
+			return nil, err
+		}
+		c.store = s
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q, expected %s or %s", opts.Backend, Memory, Disk)
+	}
+	return c, nil
+}
+
+// Key returns the key of a query and its parameters
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generation returns the current generation. Read it before computing a
+// value and pass it to Put, so a value computed across an invalidation is
+// dropped.
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation()
+}
+
+// generation returns the current generation; the caller holds c.mu. The
+// generation file is only read again after it was replaced, which a stat
+// tells without opening it.
+func (c *Cache) generation() uint64 {
+	if c.opts.Dir == "" {
+		return c.local
+	}
+	info, err := os.Stat(filepath.Join(c.opts.Dir, generationFile))
+	if err != nil {
+		c.shared, c.sharedFile = 0, nil
+		return 0
+	}
+	if c.sharedFile == nil || !os.SameFile(info, c.sharedFile) || !info.ModTime().Equal(c.sharedFile.ModTime()) {
+		c.shared, c.sharedFile = readGeneration(c.opts.Dir), info
+	}
+	return c.shared
+}
+
+// Get returns the value stored under key in the current generation
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.store.get(key)
+	switch {
+	case !ok:
+	case e.Generation != c.generation():
+		c.store.remove(key)
+		ok = false
+	case c.opts.TTL > 0 && time.Since(e.Stored) > c.opts.TTL:
+		c.store.remove(key)
+		c.expired++
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return e.Value, true
+}
+
+// Put stores value under key unless the cache was invalidated since
+// generation was read or the value alone exceeds MaxBytes
+func (c *Cache) Put(key string, generation uint64, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation() || (c.opts.MaxBytes > 0 && int64(len(value)) > c.opts.MaxBytes) {
+		return
+	}
+	c.store.put(key, entry{Generation: generation, Stored: time.Now(), Value: value})
+	c.evictions += int64(c.store.evict(c.opts.MaxEntries, c.opts.MaxBytes))
+}
+
+// Invalidate drops every entry and moves to the next generation, in all
+// processes sharing the directory
+func (c *Cache) Invalidate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidations++
+	c.store.clear()
+	if c.opts.Dir == "" {
+		c.local++
+		return nil
+	}
+	return bumpGeneration(c.opts.Dir)
+}
+
+// Stats returns the lookup counts and the current size
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, bytes := c.store.size()
+	stats := Stats{
+		Backend:       c.opts.Backend,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Expired:       c.expired,
+		Evictions:     c.evictions,
+		Invalidations: c.invalidations,
+		Entries:       entries,
+		Bytes:         bytes,
+		Generation:    c.generation(),
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRatio = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// InvalidateDir moves the caches sharing dir to the next generation, for
+// processes that write without opening a cache. Nothing is done when dir
+// does not exist, as then nothing can be cached there.
+func InvalidateDir(dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return bumpGeneration(dir)
+}
+
+// readGeneration returns the generation stored in dir, 0 if there is none
+func readGeneration(dir string) uint64 {
+	data, err := os.ReadFile(filepath.Join(dir, generationFile))
+	if err != nil {
+		return 0
+	}
+	generation, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return generation
+}
+
+// bumpGeneration increments the generation stored in dir. Two processes
+// bumping at once may both write the same number, which still moves both
+// past the generation they invalidate.
+func bumpGeneration(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	next := strconv.FormatUint(readGeneration(dir)+1, 10) + "\n"
+	return writeAtomic(filepath.Join(dir, generationFile), []byte(next))
+}
+
+// writeAtomic replaces path with data so readers never see a partial file
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
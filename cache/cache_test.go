@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c, err := Open(Options{Backend: Memory, MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := c.Generation()
+	c.Put("a", gen, []byte("1"))
+	c.Put("b", gen, []byte("2"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing")
+	}
+	// b is now the least recently used
+	c.Put("c", gen, []byte("3"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("b survived eviction")
+	}
+	if value, ok := c.Get("a"); !ok || string(value) != "1" {
+		t.Errorf("a = %q, %v", value, ok)
+	}
+
+	if err := c.Invalidate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("a survived invalidation")
+	}
+	// computed before the invalidation
+	c.Put("d", gen, []byte("4"))
+	if _, ok := c.Get("d"); ok {
+		t.Error("stored a value of an old generation")
+	}
+
+	stats := c.Stats()
+	want := Stats{Backend: Memory, Hits: 2, Misses: 3, HitRatio: 0.4, Evictions: 1, Invalidations: 1, Generation: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestCacheLimits(t *testing.T) {
+	c, err := Open(Options{Backend: Memory, TTL: time.Millisecond, MaxBytes: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("big", 0, []byte("12345"))
+	if _, ok := c.Get("big"); ok {
+		t.Error("stored a value larger than MaxBytes")
+	}
+	c.Put("small", 0, []byte("1"))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("small"); ok {
+		t.Error("served an expired value")
+	}
+	if stats := c.Stats(); stats.Expired != 1 || stats.Entries != 0 {
+		t.Errorf("stats = %+v, want 1 expired and no entries", stats)
+	}
+}
+
+func TestDiskCacheSharesGeneration(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Open(Options{Backend: Disk, Dir: dir, MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Open(Options{Backend: Disk, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory, err := Open(Options{Backend: Memory, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := first.Generation()
+	first.Put(Key("task", "1", "a"), gen, []byte(`{"result":[]}`))
+	memory.Put("m", memory.Generation(), []byte("x"))
+	if value, ok := second.Get(Key("task", "1", "a")); !ok || string(value) != `{"result":[]}` {
+		t.Fatalf("second cache got %q, %v", value, ok)
+	}
+	for _, key := range []string{"x", "y", "z"} {
+		first.Put(key, gen, []byte(key))
+	}
+	if entries, _ := first.store.size(); entries != 2 {
+		t.Errorf("%d entries on disk, want 2", entries)
+	}
+
+	// a write by another process
+	if err := InvalidateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := second.Get("z"); ok {
+		t.Error("disk entry survived invalidation")
+	}
+	if _, ok := memory.Get("m"); ok {
+		t.Error("memory entry survived invalidation of its directory")
+	}
+	if got := first.Generation(); got != gen+1 {
+		t.Errorf("generation = %d, want %d", got, gen+1)
+	}
+}
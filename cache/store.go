@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memoryStore keeps entries in a map with a recency list
+type memoryStore struct {
+	items map[string]*list.Element
+	order *list.List // front is the most recently used
+	bytes int64
+}
+
+// memoryItem is an element of the recency list
+type memoryItem struct {
+	key   string
+	entry entry
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (s *memoryStore) get(key string) (entry, bool) {
+	element, ok := s.items[key]
+	if !ok {
+		return entry{}, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*memoryItem).entry, true
+}
+
+func (s *memoryStore) put(key string, e entry) {
+	s.remove(key)
+	s.items[key] = s.order.PushFront(&memoryItem{key, e})
+	s.bytes += int64(len(e.Value))
+}
+
+func (s *memoryStore) remove(key string) {
+	if element, ok := s.items[key]; ok {
+		s.bytes -= int64(len(element.Value.(*memoryItem).entry.Value))
+		s.order.Remove(element)
+		delete(s.items, key)
+	}
+}
+
+func (s *memoryStore) clear() {
+	s.items = make(map[string]*list.Element)
+	s.order.Init()
+	s.bytes = 0
+}
+
+func (s *memoryStore) size() (int, int64) {
+	return len(s.items), s.bytes
+}
+
+func (s *memoryStore) evict(maxEntries int, maxBytes int64) int {
+	evicted := 0
+	for s.order.Len() > 0 && ((maxEntries > 0 && s.order.Len() > maxEntries) || (maxBytes > 0 && s.bytes > maxBytes)) {
+		s.remove(s.order.Back().Value.(*memoryItem).key)
+		evicted++
+	}
+	return evicted
+}
+
+// diskStore keeps one JSON file per entry; the modification time of a file
+// is the last time it was used. The entry count, bytes and recency order
+// are read from the directory once at open and then tracked in memory, so
+// entries other processes write are only counted once this one reads them.
+type diskStore struct {
+	dir   string
+	items map[string]*list.Element
+	order *list.List // front is the most recently used
+	bytes int64
+}
+
+// diskItem is an element of the recency list
+type diskItem struct {
+	key  string
+	size int64
+}
+
+// entrySuffix ends the name of every entry file
+const entrySuffix = ".json"
+
+func newDiskStore(dir string) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %w", dir, err)
+	}
+	s := &diskStore{dir: dir, items: make(map[string]*list.Element), order: list.New()}
+	for _, f := range s.files() {
+		s.track(strings.TrimSuffix(f.Name(), entrySuffix), f.Size())
+	}
+	return s, nil
+}
+
+func (s *diskStore) path(key string) string {
+	return filepath.Join(s.dir, key+entrySuffix)
+}
+
+// track records key as the most recently used entry
+func (s *diskStore) track(key string, size int64) {
+	s.untrack(key)
+	s.items[key] = s.order.PushFront(&diskItem{key, size})
+	s.bytes += size
+}
+
+// untrack forgets key
+func (s *diskStore) untrack(key string) {
+	if element, ok := s.items[key]; ok {
+		s.bytes -= element.Value.(*diskItem).size
+		s.order.Remove(element)
+		delete(s.items, key)
+	}
+}
+
+func (s *diskStore) get(key string) (entry, bool) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		// evicted or cleared by another process
+		s.untrack(key)
+		return entry{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		// written by an incompatible version or truncated
+		s.remove(key)
+		return entry{}, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(s.path(key), now, now)
+	s.track(key, int64(len(data)))
+	return e, true
+}
+
+func (s *diskStore) put(key string, e entry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	// a cache that cannot be written only costs lookups, so errors are ignored
+	if err := writeAtomic(s.path(key), data); err == nil {
+		s.track(key, int64(len(data)))
+	}
+}
+
+func (s *diskStore) remove(key string) {
+	_ = os.Remove(s.path(key))
+	s.untrack(key)
+}
+
+// clear removes every entry file, also those of other processes
+func (s *diskStore) clear() {
+	for _, f := range s.files() {
+		_ = os.Remove(filepath.Join(s.dir, f.Name()))
+	}
+	s.items = make(map[string]*list.Element)
+	s.order.Init()
+	s.bytes = 0
+}
+
+// files returns the entry files, least recently used first
+func (s *diskStore) files() []os.FileInfo {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	files := make([]os.FileInfo, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		if !strings.HasSuffix(d.Name(), entrySuffix) || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		if info, err := d.Info(); err == nil {
+			files = append(files, info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	return files
+}
+
+func (s *diskStore) size() (int, int64) {
+	return len(s.items), s.bytes
+}
+
+func (s *diskStore) evict(maxEntries int, maxBytes int64) int {
+	evicted := 0
+	for s.order.Len() > 0 && ((maxEntries > 0 && s.order.Len() > maxEntries) || (maxBytes > 0 && s.bytes > maxBytes)) {
+		key := s.order.Back().Value.(*diskItem).key
+		if err := os.Remove(s.path(key)); err == nil {
+			evicted++
+		}
+		s.untrack(key)
+	}
+	return evicted
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// analyzeCmd groups graph analyses that run over the whole taxonomy in memory
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze the structure of the category graph",
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+}
@@ -0,0 +1,455 @@
+package cmd
+
+import (
+	"dbcli/metrics"
+	"dbcli/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchWarmup     int
+	benchIterations int
+	benchTasks      []string
+	benchJSONPath   string
+	benchMDPath     string
+	benchImportDir  string
+	benchImports    int
+)
+
+// defaultBenchTasks are the tasks timed by benchmark.sh
+var defaultBenchTasks = []string{
+	"largestNumberOfChildren=10",
+	"neighborhoodPopularity=16 Tourism_in_Uttarakhand 6 6",
+	"shortestPathPopularity=17 19th-century_works 1887_directorial_debut_films 6",
+	"directPathWithHighPopularity=18 19th-century_works 1887_directorial_debut_films 5",
+}
+
+// benchTask is a task to time, parsed from --task
+type benchTask struct {
+	Name string   `json:"name"`
+	Task string   `json:"task"`
+	Args []string `json:"args"`
+}
+
+// benchResult holds the timings of one task. Durations are in milliseconds,
+// transfer figures are averages per iteration.
+type benchResult struct {
+	benchTask
+	SamplesMs     []float64 `json:"samplesMs"`
+	MinMs         float64   `json:"minMs"`
+	MeanMs        float64   `json:"meanMs"`
+	P50Ms         float64   `json:"p50Ms"`
+	P95Ms         float64   `json:"p95Ms"`
+	P99Ms         float64   `json:"p99Ms"`
+	StddevMs      float64   `json:"stddevMs"`
+	Requests      float64   `json:"requests"`
+	BytesSent     float64   `json:"bytesSent"`
+	BytesReceived float64   `json:"bytesReceived"`
+	ServerMs      float64   `json:"serverMs"`
+	// Resources is the resource use of every sampled process while the
+	// timed iterations ran
+	Resources []taskResources `json:"resources,omitempty"`
+
+	// started and finished bound the timed iterations
+	started, finished time.Time
+}
+
+// taskResources is the resource use of one process during a task
+type taskResources struct {
+	PID  int  `json:"pid"`
+	Self bool `json:"self"`
+	metrics.Summary
+}
+
+// benchReport is the outcome of a bench run
+type benchReport struct {
+	Engine     string    `json:"engine"`
+	Started    time.Time `json:"started"`
+	Warmup     int       `json:"warmup"`
+	Iterations int       `json:"iterations"`
+	// Import holds one result per import phase when --import was given,
+	// the first being the whole import
+	Import  []benchResult `json:"import,omitempty"`
+	Results []benchResult `json:"results"`
+	// Resources is the resource use sampled during the whole run; the
+	// results hold the part of it spent in each task
+	Resources []resourceSeries `json:"resources,omitempty"`
+}
+
+// benchColumns are the columns of the bench table
+var benchColumns = []string{"name", "task", "min", "mean", "p50", "p95", "p99", "stddev", "requests", "sent", "received", "server"}
+
+// benchCmd times tasks in-process
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Time tasks in-process and report latency statistics",
+	Long: `Run every --task a number of warmup iterations, then time the given number of
+iterations in this process, so container and process startup are not
+measured. A task is written as "name=number args...", for example
+--task "neighborhoodPopularity=16 Tourism_in_Uttarakhand 6 6"; without
+--task the tasks of benchmark.sh are timed.
+
+For each task the minimum, mean, median, 95th and 99th percentile and standard
+deviation of the wall-clock time are reported, together with the requests,
+bytes sent and received and the server time per iteration. Server time is the
+time until OrientDB starts responding, so it covers query execution and one
+round trip. --json and --markdown also write the report to files.
+
+--import also times an import of the given data directory, phase by phase,
+before the tasks. With --import-iterations above 1 the database is dropped
+before every further import. Two JSON reports can be compared with "bench compare".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if benchIterations < 1 {
+			log.Fatal("--iterations must be at least 1")
+		}
+		specs := benchTasks
+		if len(specs) == 0 {
+			specs = defaultBenchTasks
+		}
+		tasks := make([]benchTask, 0, len(specs))
+		for _, spec := range specs {
+			task, err := parseBenchTask(spec)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tasks = append(tasks, task)
+		}
+
+		stopResources := startResources()
+		engine, err := newTaskEngine()
+		if err != nil {
+			log.Fatalf("Failed to initialize %s engine: %v", engineName, err)
+		}
+
+		report := benchReport{Engine: engineName, Started: time.Now(), Warmup: benchWarmup, Iterations: benchIterations}
+		if benchImportDir != "" {
+			if engineName != engineOrientDB {
+				log.Fatalf("--import requires the %s engine", engineOrientDB)
+			}
+			report.Import, err = benchmarkImport(benchImportDir, benchImports)
+			if err != nil {
+				log.Fatalf("Failed to benchmark import: %v", err)
+			}
+			log.Printf("Import: mean %s", formatMillis(report.Import[0].MeanMs))
+		}
+		for _, task := range tasks {
+			result, err := benchmarkTask(engine, task, benchWarmup, benchIterations)
+			if err != nil {
+				log.Fatalf("Failed to benchmark %s: %v", task.Name, err)
+			}
+			log.Printf("%s: mean %s, p95 %s", task.Name, formatMillis(result.MeanMs), formatMillis(result.P95Ms))
+			report.Results = append(report.Results, result)
+		}
+		report.Resources = stopResources()
+		attachResources(report.Import, report.Resources)
+		attachResources(report.Results, report.Resources)
+
+		if err := writeBenchReport(os.Stdout, outputFormat, report); err != nil {
+			log.Fatal(err)
+		}
+		if benchJSONPath != "" {
+			if err := writeBenchFile(benchJSONPath, utils.FormatJSON, report); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if benchMDPath != "" {
+			if err := writeBenchFile(benchMDPath, utils.FormatMarkdown, report); err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	addGraphFlags(benchCmd)
+	addOutputFlag(benchCmd)
+	addResourceFlags(benchCmd)
+	benchCmd.Flags().IntVar(&benchWarmup, "warmup", 1, "untimed iterations run before timing each task")
+	benchCmd.Flags().IntVar(&benchIterations, "iterations", 5, "timed iterations per task")
+	benchCmd.Flags().StringArrayVar(&benchTasks, "task", nil, "task to time as \"name=number args...\", repeatable (default: the benchmark.sh tasks)")
+	benchCmd.Flags().StringVar(&benchJSONPath, "json", "", "also write the report, with every sample, as JSON to this file")
+	benchCmd.Flags().StringVar(&benchMDPath, "markdown", "", "also write the report as a Markdown table to this file")
+	benchCmd.Flags().StringVar(&benchImportDir, "import", "", "data directory to import and time before the tasks (orientdb engine)")
+	benchCmd.Flags().IntVar(&benchImports, "import-iterations", 1, "times to run the import; every run after the first drops the database and imports into a new one")
+	benchCmd.Flags().DurationVar(&searchTimeout, "timeout", 30*time.Second, "time limit for path searches (task 18)")
+	benchCmd.Flags().Int64Var(&searchMaxExpanded, "max-expanded", 10_000_000, "partial paths a path search may expand before giving up, 0 for no limit (task 18)")
+}
+
+// parseBenchTask parses "name=number args..." or "number args...", in which
+// case the task is named after its number
+func parseBenchTask(spec string) (benchTask, error) {
+	name, command, found := strings.Cut(spec, "=")
+	if !found {
+		command = spec
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return benchTask{}, fmt.Errorf("task %q has no task number", spec)
+	}
+	if !found {
+		name = "task" + fields[0]
+	}
+	if _, err := parseTask(fields[0], fields[1:]); err != nil {
+		return benchTask{}, fmt.Errorf("task %q: %w", spec, err)
+	}
+	return benchTask{Name: strings.TrimSpace(name), Task: fields[0], Args: fields[1:]}, nil
+}
+
+// benchmarkTask runs task warmup times untimed and then iterations times
+// timed. Mutating tasks (12 and 13) are timed like the others, so they should
+// be given arguments that can be applied repeatedly.
+func benchmarkTask(engine taskEngine, task benchTask, warmup, iterations int) (benchResult, error) {
+	result := benchResult{benchTask: task}
+	for i := 0; i < warmup; i++ {
+		if _, err := runTask(engine, task.Task, task.Args); err != nil {
+			return result, err
+		}
+	}
+
+	before := utils.Transfer()
+	result.started = time.Now()
+	for i := 0; i < iterations; i++ {
+		start := time.Now()
+		if _, err := runTask(engine, task.Task, task.Args); err != nil {
+			return result, err
+		}
+		result.SamplesMs = append(result.SamplesMs, milliseconds(time.Since(start)))
+	}
+	result.finished = time.Now()
+	transfer := utils.Transfer().Sub(before)
+
+	summarize(&result, transfer)
+	return result, nil
+}
+
+// benchmarkImport runs the import of dataDir iterations times and returns one
+// result per phase. The database is dropped between runs, since vertex names
+// are unique and a second import into the same database fails. Transfer
+// figures are only known for the whole import.
+func benchmarkImport(dataDir string, iterations int) ([]benchResult, error) {
+	if iterations < 1 {
+		return nil, fmt.Errorf("--import-iterations must be at least 1")
+	}
+	var results []benchResult
+	before := utils.Transfer()
+	started := time.Now()
+	for i := 0; i < iterations; i++ {
+		if i > 0 {
+			if err := dropDatabase(); err != nil {
+				return nil, err
+			}
+		}
+		phases, err := runImport(dataDir)
+		if err != nil {
+			return nil, err
+		}
+		if results == nil {
+			for _, phase := range phases {
+				results = append(results, benchResult{benchTask: benchTask{Name: phase.Name, Task: "import", Args: []string{dataDir}}})
+			}
+		}
+		for j, phase := range phases {
+			results[j].SamplesMs = append(results[j].SamplesMs, milliseconds(phase.Duration))
+		}
+	}
+	transfer := utils.Transfer().Sub(before)
+	// resources are only known for the whole import
+	results[0].started, results[0].finished = started, time.Now()
+	for i := range results {
+		if i > 0 {
+			transfer = utils.TransferStats{}
+		}
+		summarize(&results[i], transfer)
+	}
+	return results, nil
+}
+
+// attachResources sets the resource use of every result that was timed
+// from the samples of the whole run. Each task is bracketed by the nearest
+// samples, so tasks shorter than the sample interval are charged for more
+// than their own time.
+func attachResources(results []benchResult, series []resourceSeries) {
+	for i := range results {
+		if results[i].started.IsZero() {
+			continue
+		}
+		for _, one := range series {
+			if one.Error != "" {
+				continue
+			}
+			window := one.Between(results[i].started, results[i].finished)
+			results[i].Resources = append(results[i].Resources, taskResources{PID: one.PID, Self: one.Self, Summary: window.Summarize()})
+		}
+	}
+}
+
+// summarize fills in the statistics of result from its samples, with the
+// transfer spread over the iterations
+func summarize(result *benchResult, transfer utils.TransferStats) {
+	n := float64(len(result.SamplesMs))
+	result.Requests = float64(transfer.Requests) / n
+	result.BytesSent = float64(transfer.BytesSent) / n
+	result.BytesReceived = float64(transfer.BytesReceived) / n
+	result.ServerMs = milliseconds(transfer.ServerTime) / n
+
+	sorted := slices.Clone(result.SamplesMs)
+	slices.Sort(sorted)
+	result.MinMs = sorted[0]
+	result.P50Ms = percentile(sorted, 0.50)
+	result.P95Ms = percentile(sorted, 0.95)
+	result.P99Ms = percentile(sorted, 0.99)
+	result.MeanMs, result.StddevMs = meanStddev(sorted)
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile returns the p-th quantile of sorted samples, interpolating
+// linearly between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p * float64(len(sorted)-1)
+	low := int(math.Floor(rank))
+	high := int(math.Ceil(rank))
+	return sorted[low] + (sorted[high]-sorted[low])*(rank-float64(low))
+}
+
+// meanStddev returns the mean and the sample standard deviation
+func meanStddev(samples []float64) (float64, float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, s := range samples {
+		sum += s
+	}
+	mean := sum / float64(len(samples))
+	if len(samples) < 2 {
+		return mean, 0
+	}
+	squares := 0.0
+	for _, s := range samples {
+		squares += (s - mean) * (s - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(samples)-1))
+}
+
+// formatMillis renders milliseconds as a duration rounded to four
+// significant digits
+func formatMillis(ms float64) string {
+	d := time.Duration(ms * float64(time.Millisecond))
+	unit := time.Duration(1)
+	for d/unit >= 10000 {
+		unit *= 10
+	}
+	return d.Round(unit).String()
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(b float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", b, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", b, units[i])
+}
+
+// writeBenchReport writes the report as JSON, or as a table or Markdown
+// document with one row per import phase and task
+func writeBenchReport(w io.Writer, format string, report benchReport) error {
+	if format == utils.FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	results := slices.Concat(report.Import, report.Results)
+	rows := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(results))}
+	for _, r := range results {
+		rows.Result = append(rows.Result, map[string]interface{}{
+			"name":     r.Name,
+			"task":     strings.Join(append([]string{r.Task}, r.Args...), " "),
+			"min":      formatMillis(r.MinMs),
+			"mean":     formatMillis(r.MeanMs),
+			"p50":      formatMillis(r.P50Ms),
+			"p95":      formatMillis(r.P95Ms),
+			"p99":      formatMillis(r.P99Ms),
+			"stddev":   formatMillis(r.StddevMs),
+			"requests": r.Requests,
+			"sent":     formatBytes(r.BytesSent),
+			"received": formatBytes(r.BytesReceived),
+			"server":   formatMillis(r.ServerMs),
+		})
+	}
+	if format == utils.FormatMarkdown {
+		fmt.Fprintf(w, "### %s engine, %s\n\n", report.Engine, report.Started.Format(time.DateTime))
+		fmt.Fprintf(w, "%d warmup and %d timed iterations per task, transfer and server time per iteration.\n\n", report.Warmup, report.Iterations)
+	}
+	if err := utils.WriteResultSet(w, format, rows, benchColumns); err != nil {
+		return err
+	}
+	if len(report.Resources) == 0 {
+		return nil
+	}
+
+	resourceRows := utils.ResultSet{}
+	for _, r := range append(slices.Clone(report.Import), report.Results...) {
+		for _, resources := range r.Resources {
+			row := map[string]interface{}{
+				"name":     r.Name,
+				"process":  processLabel(resources.PID, resources.Self),
+				"cpu":      fmt.Sprintf("%.2fs", resources.CPUSec),
+				"peak cpu": fmt.Sprintf("%.0f%%", resources.PeakCPUPercent),
+				"peak rss": formatBytes(float64(resources.PeakRSSBytes)),
+			}
+			if resources.Self {
+				row["peak heap"] = formatBytes(float64(resources.PeakHeapBytes))
+				row["gc pauses"] = formatMillis(resources.GCPauseSec * 1000)
+			}
+			resourceRows.Result = append(resourceRows.Result, row)
+		}
+	}
+	fmt.Fprintln(w)
+	if format == utils.FormatMarkdown {
+		fmt.Fprintf(w, "Resource use during the timed iterations of each task, sampled every %s.\n\n", sampleInterval)
+	}
+	return utils.WriteResultSet(w, format, resourceRows, []string{"name", "process", "cpu", "peak cpu", "peak rss", "peak heap", "gc pauses"})
+}
+
+// writeBenchFile writes the report to path in the given format
+func writeBenchFile(path, format string, report benchReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := writeBenchReport(file, format, report); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	log.Printf("Wrote benchmark report to %s", path)
+	return nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseBenchTask(t *testing.T) {
+	task, err := parseBenchTask("neighborhood=16 Tourism_in_Uttarakhand 6 6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Name != "neighborhood" || task.Task != "16" || !slices.Equal(task.Args, []string{"Tourism_in_Uttarakhand", "6", "6"}) {
+		t.Errorf("parseBenchTask = %+v", task)
+	}
+
+	task, err = parseBenchTask("10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Name != "task10" || task.Task != "10" || len(task.Args) != 0 {
+		t.Errorf("parseBenchTask = %+v", task)
+	}
+
+	if _, err := parseBenchTask("empty= "); err == nil {
+		t.Error("expected an error for a task without a number")
+	}
+	if _, err := parseBenchTask("16 Tourism_in_Uttarakhand"); err == nil {
+		t.Error("expected an error for a task with missing arguments")
+	}
+}
+
+func TestBenchStatistics(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 10}
+	if got := percentile(sorted, 0.5); got != 3 {
+		t.Errorf("p50 = %v, want 3", got)
+	}
+	if got := percentile(sorted, 0.95); math.Abs(got-8.8) > 1e-9 {
+		t.Errorf("p95 = %v, want 8.8", got)
+	}
+	mean, stddev := meanStddev(sorted)
+	if mean != 4 || math.Abs(stddev-math.Sqrt(12.5)) > 1e-9 {
+		t.Errorf("meanStddev = %v, %v, want 4, %v", mean, stddev, math.Sqrt(12.5))
+	}
+}
+
+func TestBenchmarkTaskMemory(t *testing.T) {
+	engine := newFixtureEngine()
+	result, err := benchmarkTask(engine, benchTask{Name: "children", Task: "10"}, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.SamplesMs) != 3 || result.MinMs > result.P50Ms || result.P50Ms > result.P99Ms {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if _, err := benchmarkTask(engine, benchTask{Name: "bad", Task: "99"}, 0, 1); err == nil {
+		t.Error("expected an error for an unknown task")
+	}
+}
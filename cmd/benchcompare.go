@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"dbcli/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareThreshold float64
+	compareAlpha     float64
+)
+
+// Verdicts of a comparison
+const (
+	verdictRegression   = "regression"
+	verdictImprovement  = "improvement"
+	verdictUnchanged    = "unchanged"
+	verdictInconclusive = "inconclusive"
+	verdictAdded        = "added"
+	verdictRemoved      = "removed"
+)
+
+// benchComparison is one task or import phase lined up across two reports
+type benchComparison struct {
+	Name       string  `json:"name"`
+	Kind       string  `json:"kind"`
+	BaselineMs float64 `json:"baselineMs"`
+	CurrentMs  float64 `json:"currentMs"`
+	// DeltaPct is the change of the median in percent of the baseline
+	DeltaPct float64 `json:"deltaPct"`
+	// P is the two-sided Mann-Whitney p-value, NaN when there are no samples
+	P       float64 `json:"p"`
+	Verdict string  `json:"verdict"`
+}
+
+// benchCompareCmd compares two bench reports
+var benchCompareCmd = &cobra.Command{
+	Use:   "compare [baseline.json] [current.json]",
+	Short: "Compare two bench reports and fail on regressions",
+	Long: `Line up the import phases and tasks of two reports written with bench --json
+and compare their median times. A change is a regression or an improvement
+when the median moved by more than --threshold percent and a two-sided
+Mann-Whitney U test on the iteration samples gives p below --alpha; a large
+change that is not significant is reported as inconclusive. The command exits
+with a non-zero status when any regression is found.
+
+Few samples cannot reach significance: with 5 iterations on each side the
+smallest possible p is about 0.008, with 3 it is 0.1.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseline, err := readBenchReport(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		current, err := readBenchReport(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		comparisons := compareBenchResults("import", baseline.Import, current.Import, compareThreshold, compareAlpha)
+		comparisons = append(comparisons, compareBenchResults("task", baseline.Results, current.Results, compareThreshold, compareAlpha)...)
+		if err := writeBenchComparisons(comparisons); err != nil {
+			log.Fatal(err)
+		}
+
+		regressions := 0
+		for _, c := range comparisons {
+			if c.Verdict == verdictRegression {
+				regressions++
+			}
+		}
+		if regressions > 0 {
+			log.Fatalf("%d regression(s) beyond %g%% at alpha %g", regressions, compareThreshold, compareAlpha)
+		}
+	},
+}
+
+func init() {
+	benchCmd.AddCommand(benchCompareCmd)
+	addOutputFlag(benchCompareCmd)
+	benchCompareCmd.Flags().Float64Var(&compareThreshold, "threshold", 5, "smallest change of the median, in percent, that counts")
+	benchCompareCmd.Flags().Float64Var(&compareAlpha, "alpha", 0.05, "significance level of the Mann-Whitney test")
+}
+
+// readBenchReport reads a report written with bench --json
+func readBenchReport(path string) (benchReport, error) {
+	var report benchReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// compareBenchResults lines up results by name, in baseline order followed
+// by the results only present in current
+func compareBenchResults(kind string, baseline, current []benchResult, threshold, alpha float64) []benchComparison {
+	byName := make(map[string]benchResult, len(current))
+	for _, r := range current {
+		byName[r.Name] = r
+	}
+	var comparisons []benchComparison
+	seen := make(map[string]bool, len(baseline))
+	for _, b := range baseline {
+		seen[b.Name] = true
+		c, ok := byName[b.Name]
+		if !ok {
+			comparisons = append(comparisons, benchComparison{Name: b.Name, Kind: kind, BaselineMs: b.P50Ms, P: math.NaN(), Verdict: verdictRemoved})
+			continue
+		}
+		comparisons = append(comparisons, compareBenchResult(kind, b, c, threshold, alpha))
+	}
+	for _, c := range current {
+		if !seen[c.Name] {
+			comparisons = append(comparisons, benchComparison{Name: c.Name, Kind: kind, CurrentMs: c.P50Ms, P: math.NaN(), Verdict: verdictAdded})
+		}
+	}
+	return comparisons
+}
+
+// compareBenchResult compares the medians of two results of the same task
+func compareBenchResult(kind string, baseline, current benchResult, threshold, alpha float64) benchComparison {
+	comparison := benchComparison{
+		Name:       baseline.Name,
+		Kind:       kind,
+		BaselineMs: baseline.P50Ms,
+		CurrentMs:  current.P50Ms,
+		P:          mannWhitneyP(baseline.SamplesMs, current.SamplesMs),
+	}
+	if baseline.P50Ms > 0 {
+		comparison.DeltaPct = (current.P50Ms - baseline.P50Ms) / baseline.P50Ms * 100
+	}
+	switch {
+	case math.Abs(comparison.DeltaPct) <= threshold:
+		comparison.Verdict = verdictUnchanged
+	case math.IsNaN(comparison.P) || comparison.P >= alpha:
+		comparison.Verdict = verdictInconclusive
+	case comparison.DeltaPct > 0:
+		comparison.Verdict = verdictRegression
+	default:
+		comparison.Verdict = verdictImprovement
+	}
+	return comparison
+}
+
+// mannWhitneyP returns the two-sided p-value of the Mann-Whitney U test for
+// samples a and b. Small samples without ties use the exact distribution of
+// U, larger ones the normal approximation with tie and continuity correction.
+func mannWhitneyP(a, b []float64) float64 {
+	n1, n2 := len(a), len(b)
+	if n1 == 0 || n2 == 0 {
+		return math.NaN()
+	}
+
+	// rank the pooled samples, averaging the ranks of ties
+	type sample struct {
+		value float64
+		first bool
+	}
+	pooled := make([]sample, 0, n1+n2)
+	for _, v := range a {
+		pooled = append(pooled, sample{v, true})
+	}
+	for _, v := range b {
+		pooled = append(pooled, sample{v, false})
+	}
+	sort.Slice(pooled, func(i, j int) bool { return pooled[i].value < pooled[j].value })
+	rankSum := 0.0
+	tieTerm := 0.0
+	for i := 0; i < len(pooled); {
+		j := i
+		for j < len(pooled) && pooled[j].value == pooled[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if pooled[k].first {
+				rankSum += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+	u := rankSum - float64(n1*(n1+1))/2
+
+	if tieTerm == 0 && n1 <= 20 && n2 <= 20 {
+		return mannWhitneyExactP(u, n1, n2)
+	}
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := math.Max(math.Abs(u-mean)-0.5, 0) / math.Sqrt(variance)
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// mannWhitneyExactP returns the two-sided p-value of u from the exact null
+// distribution of U, counted with the recurrence
+// c(n1, n2, u) = c(n1-1, n2, u-n2) + c(n1, n2-1, u)
+func mannWhitneyExactP(u float64, n1, n2 int) float64 {
+	maxU := n1 * n2
+	// counts[j][k] holds c(i, j, k) for the current i
+	counts := make([][]float64, n2+1)
+	for j := range counts {
+		counts[j] = make([]float64, maxU+1)
+		counts[j][0] = 1
+	}
+	for i := 1; i <= n1; i++ {
+		next := make([][]float64, n2+1)
+		next[0] = make([]float64, maxU+1)
+		next[0][0] = 1
+		for j := 1; j <= n2; j++ {
+			next[j] = make([]float64, maxU+1)
+			for k := 0; k <= i*j; k++ {
+				next[j][k] = next[j-1][k]
+				if k >= j {
+					next[j][k] += counts[j][k-j]
+				}
+			}
+		}
+		counts = next
+	}
+
+	dist := counts[n2]
+	total, below, above := 0.0, 0.0, 0.0
+	for k, c := range dist {
+		total += c
+		if float64(k) <= u {
+			below += c
+		}
+		if float64(k) >= u {
+			above += c
+		}
+	}
+	return math.Min(1, 2*math.Min(below, above)/total)
+}
+
+// writeBenchComparisons prints the comparisons in the --output format
+func writeBenchComparisons(comparisons []benchComparison) error {
+	if outputFormat == utils.FormatJSON {
+		// NaN has no JSON representation
+		rows := slices.Clone(comparisons)
+		for i := range rows {
+			if math.IsNaN(rows[i].P) {
+				rows[i].P = -1
+			}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+
+	rows := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(comparisons))}
+	for _, c := range comparisons {
+		row := map[string]interface{}{
+			"name":    c.Name,
+			"kind":    c.Kind,
+			"verdict": c.Verdict,
+		}
+		if c.Verdict != verdictAdded {
+			row["baseline"] = formatMillis(c.BaselineMs)
+		}
+		if c.Verdict != verdictRemoved {
+			row["current"] = formatMillis(c.CurrentMs)
+		}
+		if c.Verdict != verdictAdded && c.Verdict != verdictRemoved {
+			row["delta"] = fmt.Sprintf("%+.1f%%", c.DeltaPct)
+			row["p"] = c.P
+		}
+		rows.Result = append(rows.Result, row)
+	}
+	return utils.WriteResultSet(os.Stdout, outputFormat, rows, []string{"kind", "name", "baseline", "current", "delta", "p", "verdict"})
+}
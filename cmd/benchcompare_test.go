@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"dbcli/utils"
+	"math"
+	"testing"
+)
+
+func TestMannWhitneyP(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		// exact: U = 0 is one of C(10, 5) = 252 arrangements, on either tail
+		{"separated", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 2.0 / 252},
+		// U = 10 of 25: 2 * P(U <= 10) = 2 * 87 / 252
+		{"interleaved", []float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}, 174.0 / 252},
+		// normal approximation because of the tie
+		{"tied", []float64{1, 1, 1}, []float64{1, 1, 1}, 1},
+	}
+	for _, tt := range tests {
+		if got := mannWhitneyP(tt.a, tt.b); math.Abs(got-tt.want) > 1e-3 {
+			t.Errorf("%s: p = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if p := mannWhitneyP(nil, []float64{1}); !math.IsNaN(p) {
+		t.Errorf("p without samples = %v, want NaN", p)
+	}
+}
+
+func TestCompareBenchResults(t *testing.T) {
+	result := func(name string, samples ...float64) benchResult {
+		r := benchResult{benchTask: benchTask{Name: name}, SamplesMs: samples}
+		summarize(&r, utils.TransferStats{})
+		return r
+	}
+	baseline := []benchResult{
+		result("slower", 10, 11, 10, 12, 11),
+		result("same", 10, 11, 10, 12, 11),
+		result("noisy", 10, 11, 10, 12, 11),
+		result("gone", 1),
+	}
+	current := []benchResult{
+		result("slower", 20, 21, 22, 20, 21),
+		result("same", 10, 11, 11, 12, 10),
+		result("noisy", 5, 30, 6, 31, 29),
+		result("new", 1),
+	}
+	want := map[string]string{
+		"slower": verdictRegression,
+		"same":   verdictUnchanged,
+		"noisy":  verdictInconclusive,
+		"gone":   verdictRemoved,
+		"new":    verdictAdded,
+	}
+	comparisons := compareBenchResults("task", baseline, current, 5, 0.05)
+	if len(comparisons) != len(want) {
+		t.Fatalf("got %d comparisons, want %d", len(comparisons), len(want))
+	}
+	for _, c := range comparisons {
+		if c.Verdict != want[c.Name] {
+			t.Errorf("%s: verdict %s, want %s (delta %.1f%%, p %v)", c.Name, c.Verdict, want[c.Name], c.DeltaPct, c.P)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"dbcli/cache"
+	"dbcli/metrics"
+	"dbcli/utils"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const cacheNone = "none"
+
+var (
+	cacheBackend    string
+	cacheDir        = defaultCacheDir()
+	cacheTTL        time.Duration
+	cacheMaxEntries int
+	cacheMaxBytes   int64
+
+	// resultCache is the cache opened by newTaskEngine, nil without --cache
+	resultCache *cache.Cache
+)
+
+// defaultCacheDir returns $DBCLI_CACHE_DIR, or dbcli in the user cache
+// directory. Commands that write invalidate the caches in it, so commands
+// without a --cache-dir flag can only be pointed elsewhere through the
+// environment.
+func defaultCacheDir() string {
+	if dir := os.Getenv("DBCLI_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ".dbcli-cache"
+	}
+	return filepath.Join(dir, "dbcli")
+}
+
+// addCacheFlags registers the result cache flags
+func addCacheFlags(c *cobra.Command) {
+	c.Flags().StringVar(&cacheBackend, "cache", cacheNone, "result cache: none, memory or disk")
+	c.Flags().StringVar(&cacheDir, "cache-dir", defaultCacheDir(), "directory of the disk cache and of the generation shared by all caches ($DBCLI_CACHE_DIR)")
+	c.Flags().DurationVar(&cacheTTL, "cache-ttl", 10*time.Minute, "how long a cached result is served, 0 for no limit")
+	c.Flags().IntVar(&cacheMaxEntries, "cache-max-entries", 10000, "cached results kept, 0 for no limit")
+	c.Flags().Int64Var(&cacheMaxBytes, "cache-max-bytes", 256<<20, "bytes of cached results kept, 0 for no limit")
+}
+
+// cacheCmd inspects the disk cache
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the result cache",
+	Long: `Task results can be cached with --cache memory or --cache disk on task,
+serve and loadtest. Entries are keyed by the engine, its data source, the
+task and its normalized arguments, and expire after --cache-ttl.
+
+import, materialize, writeback and tasks 12 and 13 on OrientDB move the
+caches sharing --cache-dir to the next generation, with or without a cache of
+their own, which drops every entry, also in other running processes. On the
+memory engine tasks 12 and 13 only change the graph of the running process,
+which from then on caches its results separately.`,
+}
+
+// cacheStatsCmd prints the size of the disk cache
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print the size and generation of the disk cache",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := cache.Open(cache.Options{Backend: cache.Disk, Dir: cacheDir})
+		if err != nil {
+			log.Fatal(err)
+		}
+		stats := c.Stats()
+		rows := utils.ResultSet{Result: []map[string]interface{}{{
+			"dir":        cacheDir,
+			"entries":    stats.Entries,
+			"size":       formatBytes(float64(stats.Bytes)),
+			"generation": stats.Generation,
+		}}}
+		if err := utils.WriteResultSet(os.Stdout, outputFormat, rows, []string{"dir", "entries", "size", "generation"}); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// cacheClearCmd invalidates the caches sharing the directory
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Drop every cached result, also in running processes",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := cache.Open(cache.Options{Backend: cache.Disk, Dir: cacheDir})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := c.Invalidate(); err != nil {
+			log.Fatalf("Failed to clear cache: %v", err)
+		}
+		log.Printf("Cache in %s is now at generation %d", cacheDir, c.Generation())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd, cacheClearCmd)
+	for _, c := range []*cobra.Command{cacheStatsCmd, cacheClearCmd} {
+		c.Flags().StringVar(&cacheDir, "cache-dir", defaultCacheDir(), "cache directory ($DBCLI_CACHE_DIR)")
+	}
+	addOutputFlag(cacheStatsCmd)
+}
+
+// openResultCache wraps engine in the cache selected with --cache
+func openResultCache(engine taskEngine) (taskEngine, error) {
+	if cacheBackend == "" || cacheBackend == cacheNone {
+		return engine, nil
+	}
+	c, err := cache.Open(cache.Options{
+		Backend:    cacheBackend,
+		Dir:        cacheDir,
+		TTL:        cacheTTL,
+		MaxEntries: cacheMaxEntries,
+		MaxBytes:   cacheMaxBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache: %w", err)
+	}
+	scope, err := cacheScope()
+	if err != nil {
+		return nil, err
+	}
+	resultCache = c
+	return &cachedEngine{taskEngine: engine, cache: c, source: scope, scope: scope}, nil
+}
+
+// cacheScope identifies the data an engine answers from, so results of
+// different databases or data files never share keys
+func cacheScope() (string, error) {
+	if engineName == engineOrientDB {
+		return fmt.Sprintf("%s %s/%s", engineOrientDB, utils.OrientDB.BaseURL, utils.OrientDB.Database), nil
+	}
+	files := []string{filepath.Join(dataDir, "popularity_iw.csv"), filepath.Join(dataDir, "taxonomy_iw.csv")}
+	if snapshotPath != "" {
+		files = []string{snapshotPath}
+	}
+	scope := engineMemory
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		absolute, _ := filepath.Abs(path)
+		scope += fmt.Sprintf(" %s:%d:%d", absolute, info.Size(), info.ModTime().UnixNano())
+	}
+	return scope, nil
+}
+
+// invalidateCaches drops the cached results after dbcli changed the
+// database, in this process and in all others sharing the cache directory.
+// Without --cache only the generation in the directory moves on; a directory
+// that does not exist holds no caches and is not created.
+func invalidateCaches() {
+	var err error
+	if resultCache != nil {
+		err = resultCache.Invalidate()
+	} else {
+		err = cache.InvalidateDir(cacheDir)
+	}
+	if err != nil {
+		log.Printf("Warning: could not invalidate the result cache: %v", err)
+	}
+}
+
+// logCacheStats logs the lookups of the result cache, if there is one
+func logCacheStats() {
+	if resultCache == nil {
+		return
+	}
+	stats := resultCache.Stats()
+	log.Printf("Result cache (%s): %d hits, %d misses, %d entries, %s", stats.Backend, stats.Hits, stats.Misses, stats.Entries, formatBytes(float64(stats.Bytes)))
+}
+
+// cachedEngine answers the read-only tasks from a cache
+type cachedEngine struct {
+	taskEngine
+	cache *cache.Cache
+	// source is the scope of the unmodified data
+	source string
+
+	mu    sync.RWMutex
+	scope string
+}
+
+// cached returns the cached result of a task or runs it and caches the result
+func (e *cachedEngine) cached(run func() (utils.ResultSet, error), task string, args ...string) (utils.ResultSet, error) {
+	e.mu.RLock()
+	key := cache.Key(append([]string{e.scope, task}, args...)...)
+	e.mu.RUnlock()
+	if data, ok := e.cache.Get(key); ok {
+		var result utils.ResultSet
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		// keep integers as they were returned
+		decoder.UseNumber()
+		if err := decoder.Decode(&result); err == nil {
+			metrics.Default.Counter("dbcli_cache_lookups_total", "Result cache lookups.", "result", "hit").Add(1)
+			return result, nil
+		}
+	}
+	metrics.Default.Counter("dbcli_cache_lookups_total", "Result cache lookups.", "result", "miss").Add(1)
+
+	generation := e.cache.Generation()
+	result, err := run()
+	if err != nil {
+		return result, err
+	}
+	if data, err := json.Marshal(result); err == nil {
+		e.cache.Put(key, generation, data)
+	}
+	return result, nil
+}
+
+// mutated moves a memory engine, whose graph now differs from its data
+// files, to a scope of its own. OrientDB engines invalidate all caches
+// themselves.
+func (e *cachedEngine) mutated() {
+	if _, ok := e.taskEngine.(*memoryEngine); !ok {
+		return
+	}
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	e.mu.Lock()
+	e.scope = e.source + " modified " + hex.EncodeToString(token)
+	e.mu.Unlock()
+}
+
+func (e *cachedEngine) task1(name string) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task1(name) }, "1", name)
+}
+
+func (e *cachedEngine) task2(name string) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task2(name) }, "2", name)
+}
+
+func (e *cachedEngine) task3(name string) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task3(name) }, "3", name)
+}
+
+func (e *cachedEngine) task4(name string) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task4(name) }, "4", name)
+}
+
+func (e *cachedEngine) task5(name string) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task5(name) }, "5", name)
+}
+
+func (e *cachedEngine) task6(name string) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task6(name) }, "6", name)
+}
+
+func (e *cachedEngine) task7() (utils.ResultSet, error) {
+	return e.cached(e.taskEngine.task7, "7")
+}
+
+func (e *cachedEngine) task8() (utils.ResultSet, error) {
+	return e.cached(e.taskEngine.task8, "8")
+}
+
+func (e *cachedEngine) task9() (utils.ResultSet, error) {
+	return e.cached(e.taskEngine.task9, "9")
+}
+
+func (e *cachedEngine) task10() (utils.ResultSet, error) {
+	return e.cached(e.taskEngine.task10, "10")
+}
+
+func (e *cachedEngine) task11() (utils.ResultSet, error) {
+	return e.cached(e.taskEngine.task11, "11")
+}
+
+func (e *cachedEngine) task12(oldName, newName string) (utils.ResultSet, error) {
+	defer e.mutated()
+	return e.taskEngine.task12(oldName, newName)
+}
+
+func (e *cachedEngine) task13(name string, popularity int) (utils.ResultSet, error) {
+	defer e.mutated()
+	return e.taskEngine.task13(name, popularity)
+}
+
+func (e *cachedEngine) task14(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task14(sourceName, targetName, depth) }, "14", sourceName, targetName, strconv.Itoa(depth))
+}
+
+func (e *cachedEngine) task15(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task15(sourceName, targetName, depth) }, "15", sourceName, targetName, strconv.Itoa(depth))
+}
+
+func (e *cachedEngine) task16(name string, radius int, depth int) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task16(name, radius, depth) }, "16", name, strconv.Itoa(radius), strconv.Itoa(depth))
+}
+
+func (e *cachedEngine) task17(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task17(sourceName, targetName, depth) }, "17", sourceName, targetName, strconv.Itoa(depth))
+}
+
+func (e *cachedEngine) task18(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	return e.cached(func() (utils.ResultSet, error) { return e.taskEngine.task18(sourceName, targetName, depth) }, "18", sourceName, targetName, strconv.Itoa(depth))
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"dbcli/cache"
+	"encoding/json"
+	"testing"
+)
+
+func TestCachedEngine(t *testing.T) {
+	c, err := cache.Open(cache.Options{Backend: cache.Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := &cachedEngine{taskEngine: newFixtureEngine(), cache: c, source: "fixture", scope: "fixture"}
+
+	for i := 0; i < 2; i++ {
+		result, err := engine.task1("root")
+		if err != nil || len(result.Result) != 2 {
+			t.Fatalf("task1 = %v, %v", result, err)
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 hit and 1 miss", stats)
+	}
+	// numbers come back as they were returned, not as floats
+	result, _ := engine.task1("root")
+	if _, ok := result.Result[0]["popularity"].(json.Number); !ok {
+		t.Errorf("cached popularity is %T, want json.Number", result.Result[0]["popularity"])
+	}
+
+	if _, err := engine.task13("a", 42); err != nil {
+		t.Fatal(err)
+	}
+	result, err = engine.task1("root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, row := range result.Result {
+		if row["name"] == "a" {
+			found = true
+			if row["popularity"] != int64(42) {
+				t.Errorf("popularity of a after task 13 = %v (%T), want fresh 42", row["popularity"], row["popularity"])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("task1 after task 13 = %v", result)
+	}
+}
+
+func TestWriteWithoutCacheInvalidatesDiskCache(t *testing.T) {
+	srv := newOrientServer(t)
+	seedFixture(t, srv)
+	if resultCache != nil {
+		t.Fatal("a result cache is open")
+	}
+	// a serve or task --cache disk process sharing the directory
+	c, err := cache.Open(cache.Options{Backend: cache.Disk, Dir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cache.Key("task", "5", "c")
+	c.Put(key, c.Generation(), []byte(`{"result":[]}`))
+	if _, ok := c.Get(key); !ok {
+		t.Fatal("entry not cached")
+	}
+
+	if _, err := (orientEngine{}).task13("c", 9); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Error("disk cache served an entry from before task 13")
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	metricPageRank    = "pagerank"
+	metricBetweenness = "betweenness-approx"
+	metricInDegree    = "in-degree"
+	metricOutDegree   = "out-degree"
+	metricHarmonic    = "harmonic"
+)
+
+var (
+	centralityMetric     string
+	centralityDirection  string
+	centralityDamping    float64
+	centralityTolerance  float64
+	centralityIterations int
+	centralitySamples    int
+	centralitySeed       uint64
+	centralityTop        int
+	centralityWrite      string
+)
+
+// directions maps the --direction values to the way importance flows:
+// up from categories to their parents, down to their children
+var directions = map[string]graph.Direction{
+	"up":   graph.In,
+	"down": graph.Out,
+	"both": graph.Both,
+}
+
+// analyzePageRankCmd ranks categories by structural importance
+var analyzePageRankCmd = &cobra.Command{
+	Use:   "pagerank",
+	Short: "Compute PageRank or another centrality metric and optionally store it on Vertex",
+	Long: `Compute a centrality metric for every category: pagerank, betweenness-approx,
+harmonic, in-degree or out-degree. Importance flows along --direction, by
+default up from subcategories to their parents.
+
+With --write the scores are stored as an indexed Vertex property, so queries
+can use it, e.g. SELECT FROM Vertex ORDER BY pagerank DESC LIMIT 10.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, ok := directions[centralityDirection]
+		if !ok {
+			log.Fatalf("Unknown direction %q, expected up, down or both", centralityDirection)
+		}
+		g, err := loadGraph()
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+
+		startMetric := time.Now()
+		scores, err := computeCentrality(g, centralityMetric, dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Computed %s in %s", centralityMetric, time.Since(startMetric))
+
+		if centralityWrite != "" {
+			propertyType := "DOUBLE"
+			if centralityMetric == metricInDegree || centralityMetric == metricOutDegree {
+				propertyType = "INTEGER"
+			}
+			err := writeVertexProperty(g, centralityWrite, propertyType, func(v int32) string {
+				return strconv.FormatFloat(scores[v], 'f', -1, 64)
+			})
+			if err != nil {
+				log.Fatalf("Failed to write scores: %v", err)
+			}
+		}
+
+		result := rankScores(g, scores, centralityTop)
+		if err := utils.WriteResultSet(os.Stdout, outputFormat, result, []string{"rank", "name", "score", "popularity"}); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzePageRankCmd)
+	addGraphFlags(analyzePageRankCmd)
+	addOutputFlag(analyzePageRankCmd)
+	flags := analyzePageRankCmd.Flags()
+	flags.StringVar(&centralityMetric, "metric", metricPageRank, "pagerank, betweenness-approx, harmonic, in-degree or out-degree")
+	flags.StringVar(&centralityDirection, "direction", "up", "direction importance flows: up (to parents), down (to children) or both")
+	flags.Float64Var(&centralityDamping, "damping", 0.85, "PageRank damping factor")
+	flags.Float64Var(&centralityTolerance, "tolerance", 1e-9, "PageRank convergence threshold (L1 change per iteration)")
+	flags.IntVar(&centralityIterations, "max-iterations", 100, "PageRank iteration limit")
+	flags.IntVar(&centralitySamples, "samples", 64, "source vertices sampled for betweenness-approx and harmonic, 0 for exact")
+	flags.Uint64Var(&centralitySeed, "seed", 1, "random seed for sampling")
+	flags.IntVar(&centralityTop, "top", 20, "number of top categories to print, 0 for all")
+	flags.StringVar(&centralityWrite, "write", "", "store the scores in this Vertex property and index it")
+}
+
+// computeCentrality returns one score per vertex for the given metric
+func computeCentrality(g *graph.Graph, metric string, dir graph.Direction) ([]float64, error) {
+	switch metric {
+	case metricPageRank:
+		scores, iterations := g.PageRank(dir, centralityDamping, centralityTolerance, centralityIterations)
+		log.Printf("PageRank finished after %d iterations", iterations)
+		return scores, nil
+	case metricBetweenness:
+		return g.Betweenness(dir, centralitySamples, centralitySeed), nil
+	case metricHarmonic:
+		return g.Harmonic(dir, centralitySamples, centralitySeed), nil
+	case metricInDegree, metricOutDegree:
+		scores := make([]float64, g.NumVertices())
+		for v := range scores {
+			if metric == metricInDegree {
+				scores[v] = float64(g.InDegree(int32(v)))
+			} else {
+				scores[v] = float64(g.OutDegree(int32(v)))
+			}
+		}
+		return scores, nil
+	default:
+		return nil, fmt.Errorf("unknown metric %q", metric)
+	}
+}
+
+// rankScores returns the top categories by score, ties broken by
+// popularity, or all of them when top is 0
+func rankScores(g *graph.Graph, scores []float64, top int) utils.ResultSet {
+	ranked := make([]int32, g.NumVertices())
+	for v := range ranked {
+		ranked[v] = int32(v)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return g.Popularity(a) > g.Popularity(b)
+	})
+	if top > 0 && len(ranked) > top {
+		ranked = ranked[:top]
+	}
+
+	result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(ranked))}
+	for i, v := range ranked {
+		result.Result = append(result.Result, map[string]interface{}{
+			"rank":       i + 1,
+			"name":       g.Name(v),
+			"score":      scores[v],
+			"popularity": g.Popularity(v),
+		})
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	closureMinDepth int
+	closureMaxDepth int
+	closureSummary  bool
+)
+
+// descendantsCmd generalizes tasks 1 and 3 to any depth
+var descendantsCmd = &cobra.Command{
+	Use:   "descendants [name]",
+	Short: "List all descendants within a depth range, with per-level counts",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runClosure(args[0], graph.Out)
+	},
+}
+
+// ancestorsCmd generalizes tasks 4 and 6 to any depth
+var ancestorsCmd = &cobra.Command{
+	Use:   "ancestors [name]",
+	Short: "List all ancestors within a depth range, with per-level counts",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runClosure(args[0], graph.In)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{descendantsCmd, ancestorsCmd} {
+		rootCmd.AddCommand(c)
+		addGraphFlags(c)
+		addOutputFlag(c)
+		c.Flags().IntVar(&closureMinDepth, "min-depth", 1, "smallest depth to report (0 includes the node itself)")
+		c.Flags().IntVar(&closureMaxDepth, "max-depth", -1, "largest depth to follow, -1 for no limit")
+		c.Flags().BoolVar(&closureSummary, "summary", false, "only print the per-level breakdown")
+	}
+}
+
+// runClosure prints the vertices reached from name and the level breakdown
+func runClosure(name string, dir graph.Direction) {
+	var g *graph.Graph
+	var err error
+	if engineName == engineOrientDB {
+		g, err = fetchSubgraph(name, dir, closureMaxDepth)
+	} else {
+		g, err = loadGraph()
+	}
+	if err != nil {
+		log.Fatalf("Failed to load graph: %v", err)
+	}
+
+	src, ok := g.Lookup(name)
+	if !ok {
+		log.Fatalf("Vertex %q not found", name)
+	}
+	visits, levels := g.Closure(src, dir, closureMinDepth, closureMaxDepth)
+
+	nodes := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(visits))}
+	for _, visit := range visits {
+		nodes.Result = append(nodes.Result, map[string]interface{}{
+			"name":       g.Name(visit.Vertex),
+			"depth":      visit.Depth,
+			"popularity": g.Popularity(visit.Vertex),
+		})
+	}
+	levelRows := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(levels))}
+	var total graph.Level
+	for _, level := range levels {
+		total.Count += level.Count
+		total.Popularity += level.Popularity
+		levelRows.Result = append(levelRows.Result, map[string]interface{}{
+			"depth":      level.Depth,
+			"count":      level.Count,
+			"popularity": level.Popularity,
+		})
+	}
+
+	if outputFormat == utils.FormatJSON {
+		report := map[string]interface{}{
+			"levels":     levels,
+			"count":      total.Count,
+			"popularity": total.Popularity,
+		}
+		if !closureSummary {
+			report["nodes"] = nodes.Result
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if !closureSummary {
+		if err := utils.WriteResultSet(os.Stdout, outputFormat, nodes, []string{"name", "depth", "popularity"}); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println()
+	}
+	if err := utils.WriteResultSet(os.Stdout, outputFormat, levelRows, []string{"depth", "count", "popularity"}); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("\nTotal: %d nodes, popularity %d\n", total.Count, total.Popularity)
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	componentsTop        int
+	componentsWrite      string
+	componentsExportID   int
+	componentsExportPath string
+)
+
+// componentsReport summarizes the weakly connected components of the graph
+type componentsReport struct {
+	Vertices         int              `json:"vertices"`
+	Edges            int              `json:"edges"`
+	Components       int              `json:"components"`
+	Singletons       int              `json:"singletons"`
+	SizeDistribution []graph.Bucket   `json:"sizeDistribution"`
+	Largest          []componentStats `json:"largest"`
+}
+
+// componentStats describes one weakly connected component
+type componentStats struct {
+	ID                  int    `json:"id"`
+	Size                int    `json:"size"`
+	Edges               int    `json:"edges"`
+	Popularity          int64  `json:"popularity"`
+	TopMember           string `json:"topMember"`
+	TopMemberPopularity int64  `json:"topMemberPopularity"`
+}
+
+// analyzeComponentsCmd finds islands in the taxonomy
+var analyzeComponentsCmd = &cobra.Command{
+	Use:   "components",
+	Short: "Report weakly connected components, optionally tagging or exporting them",
+	Long: `Compute weakly connected components, ignoring edge direction. Components
+are numbered by size, 0 being the largest, so the ids stay stable for the
+same data.
+
+--write stores the component id in a Vertex property. --export-id with
+--export-path saves one component as a snapshot (.snap), DOT (.dot) or
+GraphML (.graphml) file, or, for a path without extension, as a data
+directory with popularity_iw.csv and taxonomy_iw.csv.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		g, err := loadGraph()
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+
+		members, ids := componentsBySize(g)
+		report := componentsReport{Vertices: g.NumVertices(), Edges: g.NumEdges(), Components: len(members)}
+		sizes := make([]int, len(members))
+		for c, vertices := range members {
+			sizes[c] = len(vertices)
+			if len(vertices) == 1 {
+				report.Singletons++
+			}
+		}
+		report.SizeDistribution = graph.LogHistogram(sizes)
+		for c := 0; c < len(members) && c < componentsTop; c++ {
+			report.Largest = append(report.Largest, summarizeWeakComponent(g, c, members[c]))
+		}
+
+		if componentsWrite != "" {
+			err := writeVertexProperty(g, componentsWrite, "INTEGER", func(v int32) string {
+				return strconv.Itoa(ids[v])
+			})
+			if err != nil {
+				log.Fatalf("Failed to tag components: %v", err)
+			}
+		}
+
+		if componentsExportPath != "" {
+			if componentsExportID < 0 || componentsExportID >= len(members) {
+				log.Fatalf("Component %d does not exist, ids range from 0 to %d", componentsExportID, len(members)-1)
+			}
+			if err := exportGraph(g.Induced(members[componentsExportID]), componentsExportPath); err != nil {
+				log.Fatalf("Failed to export component %d: %v", componentsExportID, err)
+			}
+			log.Printf("Wrote component %d (%d vertices) to %s", componentsExportID, len(members[componentsExportID]), componentsExportPath)
+		}
+
+		if err := writeComponentsReport(report); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeComponentsCmd)
+	addGraphFlags(analyzeComponentsCmd)
+	addOutputFlag(analyzeComponentsCmd)
+	analyzeComponentsCmd.Flags().IntVar(&componentsTop, "top", 10, "number of largest components to list")
+	analyzeComponentsCmd.Flags().StringVar(&componentsWrite, "write", "", "store the component id in this Vertex property, e.g. component")
+	analyzeComponentsCmd.Flags().IntVar(&componentsExportID, "export-id", 0, "component to export with --export-path")
+	analyzeComponentsCmd.Flags().StringVar(&componentsExportPath, "export-path", "", "write the selected component to a .snap, .dot or .graphml file or a data directory")
+}
+
+// componentsBySize groups vertices by weakly connected component and renumbers
+// the components from largest to smallest. It returns the members of each
+// component and the new component id of every vertex.
+func componentsBySize(g *graph.Graph) ([][]int32, []int) {
+	comp, count := g.WeaklyConnectedComponents()
+	members := make([][]int32, count)
+	for v, c := range comp {
+		members[c] = append(members[c], int32(v))
+	}
+	// the stable sort keeps equal-sized components in order of their lowest vertex
+	sort.SliceStable(members, func(i, j int) bool {
+		return len(members[i]) > len(members[j])
+	})
+	ids := make([]int, len(comp))
+	for c, vertices := range members {
+		for _, v := range vertices {
+			ids[v] = c
+		}
+	}
+	return members, ids
+}
+
+// summarizeWeakComponent counts edges and popularity and finds the most popular member
+func summarizeWeakComponent(g *graph.Graph, id int, vertices []int32) componentStats {
+	stats := componentStats{ID: id, Size: len(vertices)}
+	top := vertices[0]
+	for _, v := range vertices {
+		stats.Edges += g.OutDegree(v)
+		stats.Popularity += g.Popularity(v)
+		if g.Popularity(v) > g.Popularity(top) {
+			top = v
+		}
+	}
+	stats.TopMember = g.Name(top)
+	stats.TopMemberPopularity = g.Popularity(top)
+	return stats
+}
+
+// exportGraph saves g in the format given by the extension of path: a
+// snapshot (.snap), Graphviz DOT (.dot), GraphML (.graphml), or CSV files in
+// the directory path if it has no extension
+func exportGraph(g *graph.Graph, path string) error {
+	switch ext := filepath.Ext(path); ext {
+	case ".snap":
+		return g.WriteSnapshot(path)
+	case ".dot", ".graphml":
+	case "":
+		return g.WriteCSV(path)
+	default:
+		return fmt.Errorf("unknown export format %q, expected .snap, .dot, .graphml or a directory without extension", ext)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+	if filepath.Ext(path) == ".dot" {
+		err = g.WriteDOT(file, strings.TrimSuffix(filepath.Base(path), ".dot"))
+	} else {
+		err = g.WriteGraphML(file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// writeComponentsReport prints the report in the selected output format
+func writeComponentsReport(report componentsReport) error {
+	switch outputFormat {
+	case utils.FormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case utils.FormatTable, utils.FormatMarkdown:
+	default:
+		return fmt.Errorf("unknown output format %q, expected %s, %s or %s", outputFormat, utils.FormatTable, utils.FormatJSON, utils.FormatMarkdown)
+	}
+
+	overviewRows := utils.ResultSet{}
+	for _, row := range [][2]interface{}{
+		{"Vertices", report.Vertices},
+		{"Edges", report.Edges},
+		{"Weakly connected components", report.Components},
+		{"Singletons", report.Singletons},
+	} {
+		overviewRows.Result = append(overviewRows.Result, map[string]interface{}{"metric": row[0], "value": row[1]})
+	}
+
+	sizeRows := utils.ResultSet{}
+	for _, bucket := range report.SizeDistribution {
+		sizeRows.Result = append(sizeRows.Result, map[string]interface{}{"size": bucketLabel(bucket), "components": bucket.Count})
+	}
+
+	largestRows := utils.ResultSet{}
+	for _, c := range report.Largest {
+		largestRows.Result = append(largestRows.Result, map[string]interface{}{
+			"id":         c.ID,
+			"size":       c.Size,
+			"edges":      c.Edges,
+			"popularity": c.Popularity,
+			"top member": fmt.Sprintf("%s (%d)", c.TopMember, c.TopMemberPopularity),
+		})
+	}
+
+	return writeSections([]reportSection{
+		{"Overview", overviewRows, []string{"metric", "value"}},
+		{"Component sizes", sizeRows, []string{"size", "components"}},
+		{"Largest components", largestRows, []string{"id", "size", "edges", "popularity", "top member"}},
+	})
+}
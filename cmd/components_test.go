@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestComponentsBySize(t *testing.T) {
+	// x - y and a - b - c, plus the singleton z
+	vertices := map[string]struct{}{"a": {}, "b": {}, "c": {}, "x": {}, "y": {}, "z": {}}
+	g := graph.New(map[string]int{}, vertices, [][2]string{{"x", "y"}, {"a", "b"}, {"c", "b"}})
+	members, ids := componentsBySize(g)
+
+	var sizes []int
+	for _, vertices := range members {
+		sizes = append(sizes, len(vertices))
+	}
+	if !slices.Equal(sizes, []int{3, 2, 1}) {
+		t.Fatalf("component sizes = %v, want [3 2 1]", sizes)
+	}
+	for name, want := range map[string]int{"a": 0, "b": 0, "c": 0, "x": 1, "y": 1, "z": 2} {
+		v, _ := g.Lookup(name)
+		if ids[v] != want {
+			t.Errorf("component of %s = %d, want %d", name, ids[v], want)
+		}
+	}
+}
+
+func TestExportGraph(t *testing.T) {
+	g := newFixtureEngine().g
+	dir := t.TempDir()
+
+	// the snapshot and CSV exports load back as the same graph
+	for path, load := range map[string]func(string) (*graph.Graph, error){
+		filepath.Join(dir, "fixture.snap"): graph.LoadSnapshot,
+		filepath.Join(dir, "fixture"):      graph.LoadCSV,
+	} {
+		if err := exportGraph(g, path); err != nil {
+			t.Fatalf("export to %s: %v", path, err)
+		}
+		loaded, err := load(path)
+		if err != nil {
+			t.Fatalf("load %s: %v", path, err)
+		}
+		if loaded.NumVertices() != g.NumVertices() || loaded.NumEdges() != g.NumEdges() {
+			t.Errorf("%s: %d vertices and %d edges, want %d and %d", path, loaded.NumVertices(), loaded.NumEdges(), g.NumVertices(), g.NumEdges())
+		}
+		for v := int32(0); v < int32(g.NumVertices()); v++ {
+			u, ok := loaded.Lookup(g.Name(v))
+			if !ok || loaded.Popularity(u) != g.Popularity(v) || loaded.OutDegree(u) != g.OutDegree(v) {
+				t.Errorf("%s: vertex %s differs", path, g.Name(v))
+			}
+		}
+	}
+
+	for path, want := range map[string]string{
+		filepath.Join(dir, "fixture.dot"):     `"root" -> "a";`,
+		filepath.Join(dir, "fixture.graphml"): `<edge source="root" target="a"/>`,
+	} {
+		if err := exportGraph(g, path); err != nil {
+			t.Fatalf("export to %s: %v", path, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s does not contain %s", path, want)
+		}
+	}
+
+	if err := exportGraph(g, filepath.Join(dir, "fixture.json")); err == nil {
+		t.Error("export to .json succeeded")
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/importer"
+	"dbcli/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cyclesTop         int
+	cyclesSample      int
+	cyclesExportEdges string
+	cyclesMinimize    bool
+)
+
+// cyclesReport summarizes the strongly connected components of the graph
+type cyclesReport struct {
+	Vertices         int                `json:"vertices"`
+	Edges            int                `json:"edges"`
+	Components       int                `json:"components"`
+	NonTrivial       int                `json:"nonTrivialComponents"`
+	VerticesInCycles int                `json:"verticesInCycles"`
+	SelfLoops        int                `json:"selfLoops"`
+	SizeDistribution []graph.Bucket     `json:"sizeDistribution"`
+	Largest          []componentSummary `json:"largest"`
+	FeedbackEdges    int                `json:"feedbackEdges"`
+}
+
+// componentSummary describes one component with its most popular members
+type componentSummary struct {
+	ID         int32    `json:"id"`
+	Size       int      `json:"size"`
+	Popularity int64    `json:"popularity"`
+	Sample     []string `json:"sample"`
+}
+
+// analyzeCyclesCmd reports cycles via Tarjan's SCC algorithm
+var analyzeCyclesCmd = &cobra.Command{
+	Use:   "cycles",
+	Short: "Report strongly connected components and edges that break all cycles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		g, err := loadGraph()
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+
+		comp, count := g.StronglyConnectedComponents()
+		members := make([][]int32, count)
+		for v, c := range comp {
+			members[c] = append(members[c], int32(v))
+		}
+
+		report := cyclesReport{Vertices: g.NumVertices(), Edges: g.NumEdges(), Components: count}
+		var sizes []int
+		var nonTrivial []int32
+		for c, vertices := range members {
+			selfLoop := len(vertices) == 1 && g.HasSelfLoop(vertices[0])
+			if selfLoop {
+				report.SelfLoops++
+			}
+			if len(vertices) > 1 || selfLoop {
+				report.NonTrivial++
+				report.VerticesInCycles += len(vertices)
+				sizes = append(sizes, len(vertices))
+				nonTrivial = append(nonTrivial, int32(c))
+			}
+		}
+		report.SizeDistribution = graph.LogHistogram(sizes)
+
+		sort.Slice(nonTrivial, func(i, j int) bool {
+			return len(members[nonTrivial[i]]) > len(members[nonTrivial[j]])
+		})
+		if len(nonTrivial) > cyclesTop {
+			nonTrivial = nonTrivial[:cyclesTop]
+		}
+		for _, c := range nonTrivial {
+			report.Largest = append(report.Largest, summarizeComponent(g, c, members[c], cyclesSample))
+		}
+
+		feedback := g.FeedbackEdges(comp, cyclesMinimize)
+		report.FeedbackEdges = len(feedback)
+		if cyclesExportEdges != "" {
+			pairs := make([][2]string, len(feedback))
+			for i, edge := range feedback {
+				pairs[i] = [2]string{g.Name(edge[0]), g.Name(edge[1])}
+			}
+			if err := importer.WriteEdges(cyclesExportEdges, pairs); err != nil {
+				log.Fatalf("Failed to export feedback edges: %v", err)
+			}
+			log.Printf("Wrote %d feedback edges to %s", len(pairs), cyclesExportEdges)
+		}
+
+		if err := writeCyclesReport(report); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeCyclesCmd)
+	addGraphFlags(analyzeCyclesCmd)
+	addOutputFlag(analyzeCyclesCmd)
+	analyzeCyclesCmd.Flags().IntVar(&cyclesTop, "top", 10, "number of largest components to list")
+	analyzeCyclesCmd.Flags().IntVar(&cyclesSample, "sample", 5, "members to show per component, most popular first")
+	analyzeCyclesCmd.Flags().StringVar(&cyclesExportEdges, "export-edges", "", "write edges whose removal makes the graph a DAG to this CSV file")
+	analyzeCyclesCmd.Flags().BoolVar(&cyclesMinimize, "minimize", true, "reduce the exported edges to a minimal set (slower on huge components)")
+}
+
+// summarizeComponent sums popularity and picks the most popular members
+func summarizeComponent(g *graph.Graph, id int32, vertices []int32, sample int) componentSummary {
+	summary := componentSummary{ID: id, Size: len(vertices)}
+	sorted := append([]int32(nil), vertices...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return g.Popularity(sorted[i]) > g.Popularity(sorted[j])
+	})
+	for i, v := range sorted {
+		summary.Popularity += g.Popularity(v)
+		if i < sample {
+			summary.Sample = append(summary.Sample, g.Name(v))
+		}
+	}
+	return summary
+}
+
+// writeCyclesReport prints the report as JSON, or as a series of tables in
+// table or Markdown format
+func writeCyclesReport(report cyclesReport) error {
+	switch outputFormat {
+	case utils.FormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case utils.FormatTable, utils.FormatMarkdown:
+	default:
+		return fmt.Errorf("unknown output format %q, expected %s, %s or %s", outputFormat, utils.FormatTable, utils.FormatJSON, utils.FormatMarkdown)
+	}
+
+	overview := [][2]interface{}{
+		{"Vertices", report.Vertices},
+		{"Edges", report.Edges},
+		{"Strongly connected components", report.Components},
+		{"Non-trivial components", report.NonTrivial},
+		{"Vertices in cycles", report.VerticesInCycles},
+		{"Self-loops", report.SelfLoops},
+		{"Feedback edges", report.FeedbackEdges},
+	}
+	overviewRows := utils.ResultSet{}
+	for _, row := range overview {
+		overviewRows.Result = append(overviewRows.Result, map[string]interface{}{"metric": row[0], "value": row[1]})
+	}
+	sections := []reportSection{{"Overview", overviewRows, []string{"metric", "value"}}}
+
+	if len(report.SizeDistribution) > 0 {
+		sizeRows := utils.ResultSet{}
+		for _, bucket := range report.SizeDistribution {
+			sizeRows.Result = append(sizeRows.Result, map[string]interface{}{"size": bucketLabel(bucket), "components": bucket.Count})
+		}
+		sections = append(sections, reportSection{"Non-trivial component sizes", sizeRows, []string{"size", "components"}})
+	}
+	if len(report.Largest) > 0 {
+		largestRows := utils.ResultSet{}
+		for _, c := range report.Largest {
+			largestRows.Result = append(largestRows.Result, map[string]interface{}{
+				"id":         c.ID,
+				"size":       c.Size,
+				"popularity": c.Popularity,
+				"sample":     strings.Join(c.Sample, ", "),
+			})
+		}
+		sections = append(sections, reportSection{"Largest components", largestRows, []string{"id", "size", "popularity", "sample"}})
+	}
+	return writeSections(sections)
+}
+
+// bucketLabel renders a histogram bucket range such as "4-7"
+func bucketLabel(bucket graph.Bucket) string {
+	if bucket.Min == bucket.Max {
+		return fmt.Sprint(bucket.Min)
+	}
+	return fmt.Sprintf("%d-%d", bucket.Min, bucket.Max)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	engineOrientDB = "orientdb"
+	engineMemory   = "memory"
+)
+
+var (
+	engineName   string
+	dataDir      string
+	snapshotPath string
+
+	searchTimeout     time.Duration
+	searchMaxExpanded int64
+)
+
+// taskEngine answers the 18 tasks. Every engine returns results shaped like
+// the OrientDB REST response so they can be compared one to one.
+type taskEngine interface {
+	task1(name string) (utils.ResultSet, error)
+	task2(name string) (utils.ResultSet, error)
+	task3(name string) (utils.ResultSet, error)
+	task4(name string) (utils.ResultSet, error)
+	task5(name string) (utils.ResultSet, error)
+	task6(name string) (utils.ResultSet, error)
+	task7() (utils.ResultSet, error)
+	task8() (utils.ResultSet, error)
+	task9() (utils.ResultSet, error)
+	task10() (utils.ResultSet, error)
+	task11() (utils.ResultSet, error)
+	task12(oldName, newName string) (utils.ResultSet, error)
+	task13(name string, popularity int) (utils.ResultSet, error)
+	task14(sourceName, targetName string, depth int) (utils.ResultSet, error)
+	task15(sourceName, targetName string, depth int) (utils.ResultSet, error)
+	task16(name string, radius int, depth int) (utils.ResultSet, error)
+	task17(sourceName, targetName string, depth int) (utils.ResultSet, error)
+	task18(sourceName, targetName string, depth int) (utils.ResultSet, error)
+
+	// suggest returns names similar to name if no vertex is called exactly that
+	suggest(name string) ([]string, error)
+	// sampleNames returns n vertex names picked at random, possibly repeated
+	sampleNames(n int, rng *rand.Rand) ([]string, error)
+}
+
+// suggestionLimit is how many "did you mean" names are offered
+const suggestionLimit = 3
+
+// addGraphFlags registers the flags that select where graph data comes from.
+// The defaults must stay the same for every command since the variables are shared.
+func addGraphFlags(c *cobra.Command) {
+	c.Flags().StringVar(&engineName, "engine", engineOrientDB, "query engine: orientdb or memory")
+	c.Flags().StringVar(&dataDir, "data", "data", "data directory with the CSV files (memory engine)")
+	c.Flags().StringVar(&snapshotPath, "snapshot", "", "graph snapshot to load instead of the CSV files (memory engine)")
+}
+
+// newTaskEngine returns the engine selected with --engine, wrapped
+// behind the result cache selected with --cache
+func newTaskEngine() (taskEngine, error) {
+	var engine taskEngine
+	switch engineName {
+	case engineOrientDB:
+		engine = orientEngine{}
+	case engineMemory:
+		g, err := loadGraph()
+		if err != nil {
+			return nil, err
+		}
+		engine = &memoryEngine{g: g}
+	default:
+		return nil, fmt.Errorf("unknown engine %q, expected %s or %s", engineName, engineOrientDB, engineMemory)
+	}
+	return openResultCache(engine)
+}
+
+// asMemoryEngine returns the memory engine behind engine, if it is one
+func asMemoryEngine(engine taskEngine) (*memoryEngine, bool) {
+	if cached, ok := engine.(*cachedEngine); ok {
+		engine = cached.taskEngine
+	}
+	memory, ok := engine.(*memoryEngine)
+	return memory, ok
+}
+
+// loadGraph loads the whole graph into memory: from OrientDB with the
+// orientdb engine, otherwise from --snapshot if given or the CSV files in --data
+func loadGraph() (*graph.Graph, error) {
+	startLoad := time.Now()
+	var g *graph.Graph
+	var err error
+	switch {
+	case engineName == engineOrientDB:
+		if g, err = fetchGraph(); err != nil {
+			return nil, err
+		}
+	case engineName != engineMemory:
+		return nil, fmt.Errorf("unknown engine %q, expected %s or %s", engineName, engineOrientDB, engineMemory)
+	case snapshotPath != "":
+		if g, err = graph.LoadSnapshot(snapshotPath); err != nil {
+			return nil, err
+		}
+	default:
+		if g, err = graph.LoadCSV(dataDir); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Loaded graph with %d vertices and %d edges in %s", g.NumVertices(), g.NumEdges(), time.Since(startLoad))
+	return g, nil
+}
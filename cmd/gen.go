@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"dbcli/datagen"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	genVertices       int
+	genAvgOutDegree   float64
+	genRoots          int
+	genCycleRate      float64
+	genPopularity     string
+	genMaxPopularity  int64
+	genRealisticNames bool
+	genEdgeCases      float64
+	genSeed           uint64
+)
+
+// genCmd writes a synthetic dataset in the format of the Wikipedia dump
+var genCmd = &cobra.Command{
+	Use:   "gen [output directory]",
+	Short: "Generate synthetic popularity and taxonomy files",
+	Long: `Generate popularity_iw.csv and taxonomy_iw.csv for a synthetic taxonomy.
+Every category except the roots is reachable from a root, --cycle-rate of the
+edges point back to an ancestor and popularity follows --popularity, either
+zipf:<exponent> or uniform. The same flags and seed give the same files.
+
+Names given a comma by --edge-cases are left out of popularity_iw.csv, as the
+importer splits its lines at the first comma; they only appear in
+taxonomy_iw.csv and import with popularity 0.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dist, exponent, err := datagen.ParsePopularity(genPopularity)
+		if err != nil {
+			log.Fatalf("Invalid --popularity: %v", err)
+		}
+
+		startGenerate := time.Now()
+		d, err := datagen.Generate(datagen.Options{
+			Vertices:       genVertices,
+			AvgOutDegree:   genAvgOutDegree,
+			Roots:          genRoots,
+			CycleRate:      genCycleRate,
+			Popularity:     dist,
+			ZipfExponent:   exponent,
+			MaxPopularity:  genMaxPopularity,
+			RealisticNames: genRealisticNames,
+			EdgeCaseRate:   genEdgeCases,
+			Seed:           genSeed,
+		})
+		if err != nil {
+			log.Fatalf("Failed to generate dataset: %v", err)
+		}
+		elapsedGenerate := time.Since(startGenerate)
+
+		startWrite := time.Now()
+		if err := d.Write(args[0]); err != nil {
+			log.Fatalf("Failed to write dataset: %v", err)
+		}
+		elapsedWrite := time.Since(startWrite)
+
+		fmt.Printf("Dataset written to %s (%d vertices, %d edges, %d cycle edges, %d edge case names)\n",
+			args[0], len(d.Names), len(d.Edges), d.CycleEdges, d.EdgeCases)
+		if unlisted := d.Unlisted(); unlisted > 0 {
+			log.Printf("Note: %d names with a comma are left out of popularity_iw.csv and import with popularity 0", unlisted)
+		}
+		log.Printf("Generate: %s", elapsedGenerate)
+		log.Printf("Write files: %s", elapsedWrite)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(genCmd)
+
+	flags := genCmd.Flags()
+	flags.IntVar(&genVertices, "vertices", 10000, "number of categories")
+	flags.Float64Var(&genAvgOutDegree, "avg-out-degree", 2, "average number of children per category")
+	flags.IntVar(&genRoots, "roots", 1, "number of categories without parents")
+	flags.Float64Var(&genCycleRate, "cycle-rate", 0, "fraction of edges pointing back to an ancestor")
+	flags.StringVar(&genPopularity, "popularity", "zipf:1", "popularity distribution, zipf:<exponent> or uniform")
+	flags.Int64Var(&genMaxPopularity, "max-popularity", 1000000, "highest popularity")
+	flags.BoolVar(&genRealisticNames, "realistic-names", false, "make up names like Rivers_of_Peru instead of Category_<n>")
+	flags.Float64Var(&genEdgeCases, "edge-cases", 0, "fraction of names with quotes, apostrophes, non-ASCII characters or commas (names with a comma get no popularity)")
+	flags.Uint64Var(&genSeed, "seed", 1, "random seed")
+}
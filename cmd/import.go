@@ -2,30 +2,48 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"dbcli/importer"
+	"dbcli/metrics"
 	"dbcli/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
 const (
-	orientDBBaseURL  = "http://orientdb:2480" // Replace with your OrientDB REST endpoint
-	orientDBUsername = "root"
-	orientDBPassword = "rootpwd"
-	databaseName     = "dbcli"
-
 	batchSize = 20000
 	workers   = 6
+
+	// batchAttempts bounds how often a failing batch is sent
+	batchAttempts = 3
+
+	// importSteps is the number of progress steps runImportContext reports:
+	// the schema setup and the six timed phases
+	importSteps = 7
 )
 
+// errImportRunning is returned when an import starts while another one runs
+var errImportRunning = errors.New("another import is running")
+
+// importMu allows one import at a time, since concurrent imports fail on
+// each other's vertex names
+var importMu sync.Mutex
+
+// batchRetryDelay is the pause before the second attempt of a batch; it
+// grows linearly with every further attempt
+var batchRetryDelay = time.Second
+
 // BatchOperation represents an operation in the batch request
 type BatchOperation struct {
 	Type     string                 `json:"type"`
@@ -47,114 +65,206 @@ var importCmd = &cobra.Command{
 	Short: "Import data from popularity and taxonomy files into OrientDB",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		dataDir := args[0]
-
-		// 1) Ensure the database exists
-		if err := ensureDatabaseExists(); err != nil {
-			log.Fatalf("Failed to ensure database existence: %v", err)
+		finishMetrics := startMetrics("import")
+		stopResources := startResources()
+		phases, err := runImport(args[0])
+		stopResources()
+		finishMetrics(err == nil)
+		if err != nil {
+			log.Fatal(err)
 		}
+		fmt.Println("Data import completed successfully!")
 
-		// 2) Turn off lightweight edges
-		if _, err := utils.ExecuteQuery("ALTER DATABASE CUSTOM useLightweightEdges=FALSE"); err != nil {
-			log.Printf("Warning: could not alter db: %v", err)
+		// Log times
+		log.Printf("Import completed in %s", phases[0].Duration)
+		for _, phase := range phases[1:] {
+			log.Printf("%s: %s", phase.Name, phase.Duration)
 		}
+	},
+}
 
-		// 3) Create Vertex class & properties via REST
-		if _, err := utils.ExecuteQuery("CREATE CLASS `Vertex` EXTENDS V"); err != nil {
-			log.Printf("Warning: could not alter db: %v", err)
-		}
-		// Create properties for Vertex class
-		vertexProps := map[string]map[string]string{
-			"name": {
-				"propertyType": "STRING",
-			},
-			"popularity": {
-				"propertyType": "INTEGER",
-			},
-		}
-		if err := createProperties("Vertex", vertexProps); err != nil {
-			log.Printf("Warning: could not create properties on Vertex: %v", err)
-		}
+func init() {
+	rootCmd.AddCommand(importCmd)
+	addMetricsFlags(importCmd)
+	addResourceFlags(importCmd)
+}
 
-		// 4) Create a unique index on Vertex.name using the command endpoint
-		if err := executeSQLCommand("CREATE INDEX `Vertex.name` UNIQUE"); err != nil {
-			log.Printf("Warning: could not create unique index on Vertex.name: %v", err)
-		}
+// importPhase is the time spent in one step of the import and the number
+// of records it handled
+type importPhase struct {
+	Name     string
+	Duration time.Duration
+	Records  int
+}
 
-		// 5) Create Edge class & properties via REST
-		if _, err := utils.ExecuteQuery("CREATE CLASS `Edge` EXTENDS E"); err != nil {
-			log.Printf("Warning: could not alter db: %v", err)
-		}
-		//Create properties for Edge class
-		edgeProps := map[string]map[string]string{
-			"in": {
-				"propertyType": "LINK",
-				"linkedClass":  "Vertex",
-			},
-			"out": {
-				"propertyType": "LINK",
-				"linkedClass":  "Vertex",
-			},
+// runImport imports the CSV files in dataDir into OrientDB and returns the
+// total time followed by the time of every phase
+func runImport(dataDir string) ([]importPhase, error) {
+	return runImportContext(context.Background(), dataDir, nil)
+}
+
+// runImportContext is runImport that stops once ctx is done and calls
+// progress, if not nil, as each step starts. It stops between phases and,
+// while inserting, between batches; a batch that was sent runs to completion.
+func runImportContext(ctx context.Context, dataDir string, progress func(step string, done, total int)) ([]importPhase, error) {
+	if !importMu.TryLock() {
+		return nil, errImportRunning
+	}
+	defer importMu.Unlock()
+
+	done := 0
+	step := func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("import stopped before %s: %w", name, err)
 		}
-		if err := createProperties("Edge", edgeProps); err != nil {
-			log.Printf("Warning: could not create properties on Edge: %v", err)
+		if progress != nil {
+			progress(name, done, importSteps)
 		}
+		done++
+		return nil
+	}
 
-		startImport := time.Now()
+	if err := step("Create schema"); err != nil {
+		return nil, err
+	}
+	// 1) Ensure the database exists
+	if err := ensureDatabaseExists(); err != nil {
+		return nil, fmt.Errorf("failed to ensure database existence: %w", err)
+	}
 
-		// Load popularity data
-		startLoadPopularity := time.Now()
-		popularityMap, popularityVertices := importer.LoadPopularity(filepath.Join(dataDir, "popularity_iw.csv"))
-		elapsedLoadPopularity := time.Since(startLoadPopularity)
+	// 2) Turn off lightweight edges
+	if _, err := utils.ExecuteQuery("ALTER DATABASE CUSTOM useLightweightEdges=FALSE"); err != nil {
+		log.Printf("Warning: could not alter db: %v", err)
+	}
 
-		// Load taxonomy edges and gather vertices
-		startLoadTaxonomy := time.Now()
-		taxonomyVertices, edgePairs := importer.LoadEdges(filepath.Join(dataDir, "taxonomy_iw.csv"))
-		elapsedLoadTaxonomy := time.Since(startLoadTaxonomy)
+	// 3) Create Vertex class & properties via REST
+	if _, err := utils.ExecuteQuery("CREATE CLASS `Vertex` EXTENDS V"); err != nil {
+		log.Printf("Warning: could not alter db: %v", err)
+	}
+	// Create properties for Vertex class
+	vertexProps := map[string]map[string]string{
+		"name": {
+			"propertyType": "STRING",
+		},
+		"popularity": {
+			"propertyType": "INTEGER",
+		},
+	}
+	if err := createProperties("Vertex", vertexProps); err != nil {
+		log.Printf("Warning: could not create properties on Vertex: %v", err)
+	}
 
-		// Merge vertices
-		startMerge := time.Now()
-		allVertices := mergeVertices(popularityVertices, taxonomyVertices)
-		elapsedMerge := time.Since(startMerge)
+	// 4) Create a unique index on Vertex.name using the command endpoint
+	if err := executeSQLCommand("CREATE INDEX `Vertex.name` UNIQUE"); err != nil {
+		log.Printf("Warning: could not create unique index on Vertex.name: %v", err)
+	}
+	// Full-text index for the search command; it falls back to a local scan without it
+	if err := executeSQLCommand(fmt.Sprintf("CREATE INDEX `%s` ON `Vertex` (name) FULLTEXT ENGINE LUCENE", nameSearchIndex)); err != nil {
+		log.Printf("Warning: could not create full-text index on Vertex.name: %v", err)
+	}
 
-		// Insert all vertices in batches
-		startInsertVertices := time.Now()
-		if err := insertAllVertices(allVertices, popularityMap); err != nil {
-			log.Fatalf("Failed to insert vertices: %v", err)
-		}
-		elapsedInsertVertices := time.Since(startInsertVertices)
+	// 5) Create Edge class & properties via REST
+	if _, err := utils.ExecuteQuery("CREATE CLASS `Edge` EXTENDS E"); err != nil {
+		log.Printf("Warning: could not alter db: %v", err)
+	}
+	//Create properties for Edge class
+	edgeProps := map[string]map[string]string{
+		"in": {
+			"propertyType": "LINK",
+			"linkedClass":  "Vertex",
+		},
+		"out": {
+			"propertyType": "LINK",
+			"linkedClass":  "Vertex",
+		},
+	}
+	if err := createProperties("Edge", edgeProps); err != nil {
+		log.Printf("Warning: could not create properties on Edge: %v", err)
+	}
 
-		// Fetch RIDs after inserting vertices
-		startFetchVertexRIDs := time.Now()
-		vertexRIDMap, err := fetchAllVertexRIDs()
-		if err != nil {
-			log.Fatalf("Failed to fetch vertex RIDs: %v", err)
-		}
-		elapsedFetchVertexRIDs := time.Since(startFetchVertexRIDs)
+	startImport := time.Now()
 
-		// Insert edges in batches using known RIDs
-		startInsertEdges := time.Now()
-		if err := insertAllEdges(edgePairs, vertexRIDMap); err != nil {
-			log.Fatalf("Failed to insert edges: %v", err)
-		}
-		elapsedInsertEdges := time.Since(startInsertEdges)
+	if err := step("Load popularity"); err != nil {
+		return nil, err
+	}
+	// Load popularity data
+	startLoadPopularity := time.Now()
+	popularityMap, popularityVertices, err := importer.LoadPopularity(filepath.Join(dataDir, "popularity_iw.csv"))
+	if err != nil {
+		return nil, err
+	}
+	elapsedLoadPopularity := time.Since(startLoadPopularity)
 
-		fmt.Println("Data import completed successfully!")
-		elapsedImport := time.Since(startImport)
+	if err := step("Load taxonomy"); err != nil {
+		return nil, err
+	}
+	// Load taxonomy edges and gather vertices
+	startLoadTaxonomy := time.Now()
+	taxonomyVertices, edgePairs, err := importer.LoadEdges(filepath.Join(dataDir, "taxonomy_iw.csv"))
+	if err != nil {
+		return nil, err
+	}
+	elapsedLoadTaxonomy := time.Since(startLoadTaxonomy)
 
-		// Log times
-		log.Printf("Import completed in %s", elapsedImport)
-		log.Printf("Load popularity: %s", elapsedLoadPopularity)
-		log.Printf("Load taxonomy: %s", elapsedLoadTaxonomy)
-		log.Printf("Merge vertices: %s", elapsedMerge)
-		log.Printf("Insert vertices: %s", elapsedInsertVertices)
-		log.Printf("Fetch vertex RIDs: %s", elapsedFetchVertexRIDs)
-		log.Printf("Insert edges: %s", elapsedInsertEdges)
-	},
-}
+	if err := step("Merge vertices"); err != nil {
+		return nil, err
+	}
+	// Merge vertices
+	startMerge := time.Now()
+	allVertices := mergeVertices(popularityVertices, taxonomyVertices)
+	elapsedMerge := time.Since(startMerge)
 
-func init() {
-	rootCmd.AddCommand(importCmd)
+	if err := step("Insert vertices"); err != nil {
+		return nil, err
+	}
+	// Insert all vertices in batches; from here on cached results are stale,
+	// even if the import fails
+	defer invalidateCaches()
+	startInsertVertices := time.Now()
+	if err := insertAllVertices(ctx, allVertices, popularityMap); err != nil {
+		return nil, fmt.Errorf("failed to insert vertices: %w", err)
+	}
+	elapsedInsertVertices := time.Since(startInsertVertices)
+
+	if err := step("Fetch vertex RIDs"); err != nil {
+		return nil, err
+	}
+	// Fetch RIDs after inserting vertices
+	startFetchVertexRIDs := time.Now()
+	vertexRIDMap, err := fetchAllVertexRIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch vertex RIDs: %w", err)
+	}
+	elapsedFetchVertexRIDs := time.Since(startFetchVertexRIDs)
+
+	if err := step("Insert edges"); err != nil {
+		return nil, err
+	}
+	// Insert edges in batches using known RIDs
+	startInsertEdges := time.Now()
+	insertedEdges, err := insertAllEdges(ctx, edgePairs, vertexRIDMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert edges: %w", err)
+	}
+	elapsedInsertEdges := time.Since(startInsertEdges)
+
+	// Aggregates computed by materialize no longer match the imported data
+	markAggregatesStale()
+
+	phases := []importPhase{
+		{"Import", time.Since(startImport), len(allVertices) + insertedEdges},
+		{"Load popularity", elapsedLoadPopularity, len(popularityMap)},
+		{"Load taxonomy", elapsedLoadTaxonomy, len(edgePairs)},
+		{"Merge vertices", elapsedMerge, len(allVertices)},
+		{"Insert vertices", elapsedInsertVertices, len(allVertices)},
+		{"Fetch vertex RIDs", elapsedFetchVertexRIDs, len(vertexRIDMap)},
+		{"Insert edges", elapsedInsertEdges, insertedEdges},
+	}
+	for _, phase := range phases {
+		metrics.Default.Gauge("dbcli_import_phase_duration_seconds", "Time spent in an import phase.", "phase", phase.Name).Set(phase.Duration.Seconds())
+		metrics.Default.Gauge("dbcli_import_phase_records", "Records handled by an import phase.", "phase", phase.Name).Set(float64(phase.Records))
+	}
+	return phases, nil
 }
 
 // --------------------------------------------------------------------------------
@@ -163,13 +273,13 @@ func init() {
 
 // ensureDatabaseExists checks or creates the OrientDB database via REST
 func ensureDatabaseExists() error {
-	dbCheckURL := fmt.Sprintf("%s/database/%s", orientDBBaseURL, databaseName)
+	dbCheckURL := utils.OrientDB.URL("database")
 
 	req, err := http.NewRequest("GET", dbCheckURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	req.SetBasicAuth(orientDBUsername, orientDBPassword)
+	req.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -184,12 +294,12 @@ func ensureDatabaseExists() error {
 	}
 
 	// Otherwise create it
-	dbCreateURL := fmt.Sprintf("%s/database/%s/plocal", orientDBBaseURL, databaseName)
+	dbCreateURL := utils.OrientDB.URL("database", "plocal")
 	req, err = http.NewRequest("POST", dbCreateURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	req.SetBasicAuth(orientDBUsername, orientDBPassword)
+	req.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 
 	resp, err = http.DefaultClient.Do(req)
 	if err != nil {
@@ -206,6 +316,27 @@ func ensureDatabaseExists() error {
 	return nil
 }
 
+// dropDatabase deletes the OrientDB database and everything in it via REST
+func dropDatabase() error {
+	req, err := http.NewRequest("DELETE", utils.OrientDB.URL("database"), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to drop database: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to drop database, status: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 // createClassIfNotExists attempts to create a new class extending another class.
 // Example: createClassIfNotExists("Vertex", "V") => "CREATE CLASS Vertex EXTENDS V"
 func createClassIfNotExists(className, superClass string) error {
@@ -217,12 +348,12 @@ func createClassIfNotExists(className, superClass string) error {
 	// If it doesn't exist, we do a POST to create it.
 	// But if you want to forcibly create, just do the POST and ignore 409 errors.
 
-	checkURL := fmt.Sprintf("%s/class/%s/%s", orientDBBaseURL, databaseName, className)
+	checkURL := utils.OrientDB.URL("class", className)
 	reqCheck, err := http.NewRequest("GET", checkURL, nil)
 	if err != nil {
 		return err
 	}
-	reqCheck.SetBasicAuth(orientDBUsername, orientDBPassword)
+	reqCheck.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 
 	respCheck, err := http.DefaultClient.Do(reqCheck)
 	if err != nil {
@@ -237,7 +368,7 @@ func createClassIfNotExists(className, superClass string) error {
 	}
 
 	// If not found, create it
-	createURL := fmt.Sprintf("%s/class/%s/%s", orientDBBaseURL, databaseName, className)
+	createURL := utils.OrientDB.URL("class", className)
 	// We can pass ?superClass=<name> as a query parameter, or rely on OrientDB
 	// to handle creation. In older versions, we might have used a command.
 	// According to OrientDB docs, you can do:
@@ -251,7 +382,7 @@ func createClassIfNotExists(className, superClass string) error {
 	if err != nil {
 		return err
 	}
-	reqCreate.SetBasicAuth(orientDBUsername, orientDBPassword)
+	reqCreate.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 
 	respCreate, err := http.DefaultClient.Do(reqCreate)
 	if err != nil {
@@ -259,7 +390,8 @@ func createClassIfNotExists(className, superClass string) error {
 	}
 	defer respCreate.Body.Close()
 
-	if respCreate.StatusCode != http.StatusOK {
+	// OrientDB answers 201, older versions 200
+	if respCreate.StatusCode != http.StatusCreated && respCreate.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(respCreate.Body)
 		return fmt.Errorf("failed to create class %s, status: %d, body: %s",
 			className, respCreate.StatusCode, string(body))
@@ -285,12 +417,12 @@ func createProperties(className string, props map[string]map[string]string) erro
 		return fmt.Errorf("failed to marshal properties: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/property/%s/%s", orientDBBaseURL, databaseName, className)
+	url := utils.OrientDB.URL("property", className)
 	req, err := http.NewRequest("POST", url, bytes.NewReader(propsData))
 	if err != nil {
 		return fmt.Errorf("failed to create request for properties: %w", err)
 	}
-	req.SetBasicAuth(orientDBUsername, orientDBPassword)
+	req.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
@@ -311,7 +443,7 @@ func createProperties(className string, props map[string]map[string]string) erro
 
 // executeSQLCommand runs an SQL command via POST /command/<database>/sql
 func executeSQLCommand(sql string) error {
-	url := fmt.Sprintf("%s/command/%s/sql", orientDBBaseURL, databaseName)
+	url := utils.OrientDB.URL("command", "sql")
 
 	// The request body for a POST command must be JSON with "command": <sql> or "command": "sql to run"
 	payload := map[string]interface{}{
@@ -326,7 +458,7 @@ func executeSQLCommand(sql string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create command request: %w", err)
 	}
-	req.SetBasicAuth(orientDBUsername, orientDBPassword)
+	req.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
@@ -358,8 +490,12 @@ func mergeVertices(vertices1, vertices2 map[string]struct{}) map[string]struct{}
 	return merged
 }
 
-// insertAllVertices inserts all vertices using batch operations
-func insertAllVertices(allVertices map[string]struct{}, popularityMap map[string]int) error {
+// insertAllVertices inserts all vertices using batch operations. It stops
+// sending batches once ctx is done or a batch failed.
+func insertAllVertices(ctx context.Context, allVertices map[string]struct{}, popularityMap map[string]int) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	vertexChan := make(chan map[string]interface{})
 	errChan := make(chan error, workers)
 	var wg sync.WaitGroup
@@ -380,35 +516,41 @@ func insertAllVertices(allVertices map[string]struct{}, popularityMap map[string
 				})
 				// When we hit batchSize, send a batch request
 				if len(batch) >= batchSize {
-					if err := sendBatchRequest(batch, true); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					if err := sendBatchRequest(batch, true, "vertices"); err != nil {
 						errChan <- err
+						cancel()
 						return
 					}
 					batch = batch[:0]
 				}
 			}
-			// Send final leftover
-			if len(batch) > 0 {
-				if err := sendBatchRequest(batch, true); err != nil {
+			// Send final leftover, unless the producer stopped early
+			if len(batch) > 0 && ctx.Err() == nil {
+				if err := sendBatchRequest(batch, true, "vertices"); err != nil {
 					errChan <- err
+					cancel()
 				}
 			}
 		}()
 	}
 
-	// Feed data into the workers
+	// Feed data into the workers until they are done or one failed
 	go func() {
+		defer close(vertexChan)
 		for name := range allVertices {
 			popularity := 0
 			if p, ok := popularityMap[name]; ok {
 				popularity = p
 			}
-			vertexChan <- map[string]interface{}{
-				"name":       name,
-				"popularity": popularity,
+			select {
+			case vertexChan <- map[string]interface{}{"name": name, "popularity": popularity}:
+			case <-ctx.Done():
+				return
 			}
 		}
-		close(vertexChan)
 	}()
 
 	wg.Wait()
@@ -420,15 +562,21 @@ func insertAllVertices(allVertices map[string]struct{}, popularityMap map[string
 			return err
 		}
 	}
-	return nil
+	return parent.Err()
 }
 
 // insertAllEdges inserts edges using a single "script" operation per worker
 // with up to 20,000 CREATE EDGE commands in a single BEGIN/COMMIT script block.
-func insertAllEdges(edgePairs [][2]string, vertexRIDMap map[string]string) error {
+// It returns the number of edges inserted; edges with an unknown endpoint are
+// skipped. It stops sending scripts once ctx is done or a script failed.
+func insertAllEdges(ctx context.Context, edgePairs [][2]string, vertexRIDMap map[string]string) (int, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	edgeChan := make(chan [2]string)
 	errChan := make(chan error, 1)
 	var wg sync.WaitGroup
+	var inserted atomic.Int64
 
 	for i := 0; i < 1; i++ {
 		wg.Add(1)
@@ -442,7 +590,7 @@ func insertAllEdges(edgePairs [][2]string, vertexRIDMap map[string]string) error
 				fromRID, okFrom := vertexRIDMap[fromName]
 				toRID, okTo := vertexRIDMap[toName]
 				if !okFrom || !okTo {
-					// skip or log
+					metrics.Default.Counter("dbcli_import_skipped_edges_total", "Edges skipped because an endpoint has no vertex.").Add(1)
 					continue
 				}
 
@@ -452,44 +600,55 @@ func insertAllEdges(edgePairs [][2]string, vertexRIDMap map[string]string) error
 
 				// If we've reached batchSize, send the script as a single operation
 				if count >= 10000 {
+					if ctx.Err() != nil {
+						return
+					}
 					scriptLines = append(scriptLines, "COMMIT;")
 					op := BatchOperation{
 						Type:     "script",
 						Language: "sql",
 						Script:   scriptLines,
 					}
-					if err := sendBatchRequest([]BatchOperation{op}, false); err != nil {
+					if err := sendBatchRequest([]BatchOperation{op}, false, "edges"); err != nil {
 						errChan <- err
+						cancel()
 						return
 					}
+					inserted.Add(int64(count))
 					// Reset for next batch
 					scriptLines = []string{"BEGIN;"}
 					count = 0
 				}
 			}
 
-			// Send any leftover in final partial batch
-			if count > 0 {
+			// Send any leftover in final partial batch, unless the producer
+			// stopped early
+			if count > 0 && ctx.Err() == nil {
 				scriptLines = append(scriptLines, "COMMIT;")
 				op := BatchOperation{
 					Type:     "script",
 					Language: "sql",
 					Script:   scriptLines,
 				}
-				if err := sendBatchRequest([]BatchOperation{op}, false); err != nil {
+				if err := sendBatchRequest([]BatchOperation{op}, false, "edges"); err != nil {
 					errChan <- err
 					return
 				}
+				inserted.Add(int64(count))
 			}
 		}()
 	}
 
-	// Producer: feed all edges
+	// Producer: feed all edges until the worker is done or failed
 	go func() {
+		defer close(edgeChan)
 		for _, pair := range edgePairs {
-			edgeChan <- pair
+			select {
+			case edgeChan <- pair:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(edgeChan)
 	}()
 
 	// Wait for all workers
@@ -501,20 +660,20 @@ func insertAllEdges(edgePairs [][2]string, vertexRIDMap map[string]string) error
 	// Check for errors
 	for err := range errChan {
 		if err != nil {
-			return err
+			return int(inserted.Load()), err
 		}
 	}
-	return nil
+	return int(inserted.Load()), parent.Err()
 }
 
 // fetchAllVertexRIDs returns a map of name->@rid for all Vertex records
 func fetchAllVertexRIDs() (map[string]string, error) {
-	url := fmt.Sprintf("%s/query/%s/sql/SELECT name,@rid FROM V LIMIT -1", orientDBBaseURL, databaseName)
+	url := utils.OrientDB.URL("query", "sql", "SELECT name,@rid FROM V LIMIT -1")
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	req.SetBasicAuth(orientDBUsername, orientDBPassword)
+	req.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -547,8 +706,10 @@ func fetchAllVertexRIDs() (map[string]string, error) {
 	return m, nil
 }
 
-// sendBatchRequest sends a batch of operations to the OrientDB REST API
-func sendBatchRequest(operations []BatchOperation, transaction bool) error {
+// sendBatchRequest sends a batch of operations to the OrientDB REST API,
+// retrying failures that left the database unchanged. phase labels the
+// batch metrics.
+func sendBatchRequest(operations []BatchOperation, transaction bool, phase string) error {
 	request := BatchRequest{
 		Transaction: transaction,
 		Operations:  operations,
@@ -558,24 +719,52 @@ func sendBatchRequest(operations []BatchOperation, transaction bool) error {
 		return fmt.Errorf("failed to marshal batch request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/batch/%s", orientDBBaseURL, databaseName)
+	latency := metrics.Default.Histogram("dbcli_batch_duration_seconds", "Latency of batch request attempts.", batchDurationBuckets, "phase", phase)
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		retryable, err := postBatch(jsonData)
+		latency.Observe(time.Since(start).Seconds())
+		if err == nil || !retryable || attempt == batchAttempts {
+			metrics.Default.Counter("dbcli_batches_total", "Batch requests sent, retries not counted.", "phase", phase).Add(1)
+			if err != nil {
+				metrics.Default.Counter("dbcli_batch_failures_total", "Batch requests that failed after all attempts.", "phase", phase).Add(1)
+			}
+			return err
+		}
+		metrics.Default.Counter("dbcli_batch_retries_total", "Batch request attempts repeated after a transient failure.", "phase", phase).Add(1)
+		log.Printf("Warning: batch attempt %d of %d failed, retrying: %v", attempt, batchAttempts, err)
+		time.Sleep(time.Duration(attempt) * batchRetryDelay)
+	}
+}
+
+// postBatch sends one batch request. Only failures that provably did not
+// commit are reported as retryable: a connection that could not be opened
+// and a concurrent modification conflict, which OrientDB raises before the
+// commit. A transport error after the request was sent or a server error may
+// come after the batch committed, and the edge batches, which are scripts
+// with their own BEGIN/COMMIT, would be inserted twice by a retry.
+func postBatch(jsonData []byte) (bool, error) {
+	url := utils.OrientDB.URL("batch")
 	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create batch request: %w", err)
+		return false, fmt.Errorf("failed to create batch request: %w", err)
 	}
-	req.SetBasicAuth(orientDBUsername, orientDBPassword)
+	req.SetBasicAuth(utils.OrientDB.Username, utils.OrientDB.Password)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send batch: %w", err)
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial", fmt.Errorf("failed to send batch: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("batch insert failed with status: %d, body: %s", resp.StatusCode, string(body))
+		// 409 is also used for duplicate keys, which fail again on retry
+		retryable := resp.StatusCode == http.StatusConflict && bytes.Contains(body, []byte("OConcurrentModificationException"))
+		return retryable, fmt.Errorf("batch insert failed with status: %d, body: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	return false, nil
 }
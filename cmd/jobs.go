@@ -0,0 +1,553 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"dbcli/graph"
+	"dbcli/metrics"
+	"dbcli/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Job kinds
+const (
+	jobImport   = "import"
+	jobTask     = "task"
+	jobPageRank = "pagerank"
+)
+
+// Job states; succeeded, failed and canceled are final. A running job is
+// canceling from its cancellation until its work returns.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCanceling = "canceling"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobCanceled  = "canceled"
+)
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job already finished")
+	errQueueFull   = errors.New("job queue is full")
+)
+
+// jobSpec is the work a job does, as posted to /v1/jobs
+type jobSpec struct {
+	Kind string `json:"kind"`
+	// DataDir is the directory an import reads, relative to --import-root
+	// when submitted and resolved against it by normalize
+	DataDir string `json:"dataDir,omitempty"`
+	// Task and Args run a task as on the command line
+	Task string   `json:"task,omitempty"`
+	Args []string `json:"args,omitempty"`
+	// PageRank parameters, defaulting to those of analyze pagerank
+	Direction     string  `json:"direction,omitempty"`
+	Damping       float64 `json:"damping,omitempty"`
+	Tolerance     float64 `json:"tolerance,omitempty"`
+	MaxIterations int     `json:"maxIterations,omitempty"`
+	Top           int     `json:"top,omitempty"`
+}
+
+// normalize fills in defaults and rejects specs that cannot run
+func (s *jobSpec) normalize() error {
+	switch s.Kind {
+	case jobImport:
+		root := serveImportRoot
+		if root == "" {
+			root = dataDir
+		}
+		if s.DataDir != "" && !filepath.IsLocal(s.DataDir) {
+			return badRequest("dataDir must be a relative path within the import root %s", root)
+		}
+		s.DataDir = filepath.Join(root, s.DataDir)
+		// fail before queueing rather than in the middle of the import
+		for _, name := range []string{"popularity_iw.csv", "taxonomy_iw.csv"} {
+			if _, err := os.Stat(filepath.Join(s.DataDir, name)); err != nil {
+				return badRequest("cannot import %s: %v", s.DataDir, err)
+			}
+		}
+	case jobTask:
+		if n, err := strconv.Atoi(s.Task); err != nil || n < 1 || n > 18 {
+			return badRequest("task must be a number from 1 to 18")
+		}
+		if _, err := parseTask(s.Task, s.Args); err != nil {
+			return badRequest("%v", err)
+		}
+	case jobPageRank:
+		if s.Direction == "" {
+			s.Direction = "up"
+		}
+		if _, ok := directions[s.Direction]; !ok {
+			return badRequest("unknown direction %q, expected up, down or both", s.Direction)
+		}
+		if s.Damping == 0 {
+			s.Damping = 0.85
+		}
+		if s.Damping < 0 || s.Damping >= 1 {
+			return badRequest("damping must be in [0, 1)")
+		}
+		if s.Tolerance == 0 {
+			s.Tolerance = 1e-9
+		}
+		if s.MaxIterations == 0 {
+			s.MaxIterations = 100
+		}
+		if s.Top == 0 {
+			s.Top = 20
+		}
+	default:
+		return badRequest("unknown job kind %q, expected %s, %s or %s", s.Kind, jobImport, jobTask, jobPageRank)
+	}
+	return nil
+}
+
+// jobProgress is how far a running job got
+type jobProgress struct {
+	Step  string `json:"step,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// job is the state of one submitted job
+type job struct {
+	ID       string           `json:"id"`
+	Spec     jobSpec          `json:"spec"`
+	Status   string           `json:"status"`
+	Progress jobProgress      `json:"progress"`
+	Result   *utils.ResultSet `json:"result,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Created  time.Time        `json:"created"`
+	Started  *time.Time       `json:"started,omitempty"`
+	Finished *time.Time       `json:"finished,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// finished reports whether the job reached a final state
+func (j *job) finished() bool {
+	return j.Status == jobSucceeded || j.Status == jobFailed || j.Status == jobCanceled
+}
+
+// finish moves the job to a final state
+func (j *job) finish(status string, result *utils.ResultSet, err error) {
+	now := time.Now()
+	j.Status = status
+	j.Result = result
+	if err != nil {
+		j.Error = err.Error()
+	}
+	j.Finished = &now
+	j.cancel = nil
+}
+
+// jobRunner does the work of a job, reporting progress as it goes
+type jobRunner func(ctx context.Context, spec jobSpec, progress func(jobProgress)) (utils.ResultSet, error)
+
+// jobManager runs jobs on a fixed number of workers and keeps their state
+// in a file, so finished results survive restarts. The state file only
+// holds the jobs' metadata; each result is written once to its own file in
+// resultsDir and read back on request.
+type jobManager struct {
+	path       string
+	resultsDir string
+	history    int
+	run        jobRunner
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	queue chan *job
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// newJobManager loads the jobs saved in path, if any, and starts the
+// workers. Jobs that were queued are queued again; jobs that were running
+// when the process stopped are marked failed. At most history finished
+// jobs are kept, 0 for no limit; the oldest are dropped first.
+func newJobManager(path string, workers, queueSize, history int, run jobRunner) (*jobManager, error) {
+	m := &jobManager{path: path, history: history, run: run, jobs: make(map[string]*job)}
+	if path != "" {
+		m.resultsDir = path + ".results"
+		if err := os.MkdirAll(m.resultsDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", m.resultsDir, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if len(data) > 0 {
+			var saved []*job
+			if err := json.Unmarshal(data, &saved); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			for _, j := range saved {
+				m.jobs[j.ID] = j
+				// state files written before results were stored apart
+				if j.Result != nil {
+					m.storeResult(j)
+				}
+			}
+		}
+	}
+
+	var requeued []*job
+	for _, j := range m.sorted() {
+		switch j.Status {
+		case jobQueued:
+			requeued = append(requeued, j)
+		case jobRunning:
+			j.finish(jobFailed, nil, errors.New("interrupted by restart"))
+		case jobCanceling:
+			j.finish(jobCanceled, nil, nil)
+		}
+	}
+	m.queue = make(chan *job, max(queueSize, len(requeued)))
+	for _, j := range requeued {
+		m.queue <- j
+	}
+	if len(m.jobs) > 0 {
+		log.Printf("Loaded %d jobs from %s, %d queued", len(m.jobs), path, len(requeued))
+	}
+	m.mu.Lock()
+	m.save()
+	m.mu.Unlock()
+
+	m.ctx, m.stop = context.WithCancel(context.Background())
+	for range workers {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m, nil
+}
+
+// sorted returns the jobs by creation time
+func (m *jobManager) sorted() []*job {
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		if !jobs[a].Created.Equal(jobs[b].Created) {
+			return jobs[a].Created.Before(jobs[b].Created)
+		}
+		return jobs[a].ID < jobs[b].ID
+	})
+	return jobs
+}
+
+// save writes the metadata of all jobs to the state file; the caller holds
+// m.mu
+func (m *jobManager) save() {
+	finished := 0
+	jobs := m.sorted()
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if !jobs[i].finished() {
+			continue
+		}
+		if finished++; m.history > 0 && finished > m.history {
+			delete(m.jobs, jobs[i].ID)
+			if m.resultsDir != "" {
+				os.Remove(m.resultPath(jobs[i].ID))
+			}
+		}
+	}
+	if m.path == "" {
+		return
+	}
+	jobs = m.sorted()
+	for i, j := range jobs {
+		summary := *j
+		summary.Result = nil
+		jobs[i] = &summary
+	}
+	err := metrics.WriteFile(m.path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jobs)
+	})
+	if err != nil {
+		log.Printf("Warning: could not save jobs: %v", err)
+	}
+}
+
+// resultPath returns the file that holds the result of job id
+func (m *jobManager) resultPath(id string) string {
+	return filepath.Join(m.resultsDir, id+".json")
+}
+
+// storeResult moves the result of a finished job from memory to its file;
+// the caller holds m.mu. Without a state file results stay in memory.
+func (m *jobManager) storeResult(j *job) {
+	if m.resultsDir == "" || j.Result == nil {
+		return
+	}
+	err := metrics.WriteFile(m.resultPath(j.ID), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(j.Result)
+	})
+	if err != nil {
+		log.Printf("Warning: could not save result of job %s, keeping it in memory: %v", j.ID, err)
+		return
+	}
+	j.Result = nil
+}
+
+// loadResult reads the stored result of a succeeded job
+func (m *jobManager) loadResult(id string) (*utils.ResultSet, error) {
+	data, err := os.ReadFile(m.resultPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result: %w", err)
+	}
+	var result utils.ResultSet
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	return &result, nil
+}
+
+// submit queues a new job
+func (m *jobManager) submit(spec jobSpec) (job, error) {
+	if err := spec.normalize(); err != nil {
+		return job{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return job{}, fmt.Errorf("failed to generate job id: %w", err)
+	}
+	j := &job{ID: hex.EncodeToString(id), Spec: spec, Status: jobQueued, Created: time.Now()}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if spec.Kind == jobImport {
+		for _, other := range m.jobs {
+			if other.Spec.Kind == jobImport && !other.finished() {
+				return job{}, errImportRunning
+			}
+		}
+	}
+	select {
+	case m.queue <- j:
+	default:
+		return job{}, errQueueFull
+	}
+	m.jobs[j.ID] = j
+	m.save()
+	return *j, nil
+}
+
+// get returns a copy of the job with the given id, with its result
+func (m *jobManager) get(id string) (job, bool) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return job{}, false
+	}
+	copied := *j
+	m.mu.Unlock()
+
+	if copied.Status == jobSucceeded && copied.Result == nil && m.resultsDir != "" {
+		result, err := m.loadResult(id)
+		if err != nil {
+			copied.Error = err.Error()
+		}
+		copied.Result = result
+	}
+	return copied, true
+}
+
+// list returns copies of all jobs without their results
+func (m *jobManager) list() []job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]job, 0, len(m.jobs))
+	for _, j := range m.sorted() {
+		summary := *j
+		summary.Result = nil
+		jobs = append(jobs, summary)
+	}
+	return jobs
+}
+
+// cancelJob cancels a queued or running job. A running job is canceling
+// until its work returns, which for one that cannot be interrupted is when
+// it finishes; its result is discarded.
+func (m *jobManager) cancelJob(id string) (job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return job{}, errJobNotFound
+	}
+	if j.finished() {
+		return *j, errJobFinished
+	}
+	if j.Status == jobQueued {
+		j.finish(jobCanceled, nil, nil)
+	} else {
+		j.cancel()
+		j.Status = jobCanceling
+	}
+	m.save()
+	return *j, nil
+}
+
+// worker runs queued jobs until the manager is closed
+func (m *jobManager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case j := <-m.queue:
+			m.execute(j)
+		}
+	}
+}
+
+// execute runs one job and records its outcome
+func (m *jobManager) execute(j *job) {
+	m.mu.Lock()
+	if j.Status != jobQueued {
+		// canceled while queued
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	now := time.Now()
+	j.Status = jobRunning
+	j.Started = &now
+	j.cancel = cancel
+	spec := j.Spec
+	m.save()
+	m.mu.Unlock()
+
+	log.Printf("Job %s started: %s", j.ID, spec.Kind)
+	result, err := m.run(ctx, spec, func(progress jobProgress) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if j.Status == jobRunning {
+			j.Progress = progress
+		}
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case j.Status == jobCanceling:
+		j.finish(jobCanceled, nil, nil)
+	case j.Status != jobRunning:
+		// given up on by close
+	case m.ctx.Err() != nil:
+		j.finish(jobFailed, nil, errors.New("interrupted by shutdown"))
+	case err != nil:
+		j.finish(jobFailed, nil, err)
+	default:
+		j.finish(jobSucceeded, &result, nil)
+		m.storeResult(j)
+	}
+	log.Printf("Job %s %s in %s", j.ID, j.Status, time.Since(now).Round(time.Millisecond))
+	m.save()
+}
+
+// close stops the workers, interrupting running jobs, and waits for them
+// until ctx is done. Queued jobs stay queued in the state file.
+func (m *jobManager) close(ctx context.Context) error {
+	m.stop()
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, j := range m.jobs {
+			switch j.Status {
+			case jobRunning:
+				j.finish(jobFailed, nil, errors.New("interrupted by shutdown"))
+			case jobCanceling:
+				j.finish(jobCanceled, nil, nil)
+			}
+		}
+		m.save()
+		return fmt.Errorf("jobs still running: %w", ctx.Err())
+	}
+}
+
+// runJob does the work of a job against the server's engine
+func (s *apiServer) runJob(ctx context.Context, spec jobSpec, progress func(jobProgress)) (utils.ResultSet, error) {
+	switch spec.Kind {
+	case jobImport:
+		phases, err := runImportContext(ctx, spec.DataDir, func(step string, done, total int) {
+			progress(jobProgress{Step: step, Done: done, Total: total})
+		})
+		if err != nil {
+			return utils.ResultSet{}, err
+		}
+		result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(phases))}
+		for _, phase := range phases {
+			result.Result = append(result.Result, map[string]interface{}{
+				"phase":   phase.Name,
+				"seconds": phase.Duration.Seconds(),
+				"records": phase.Records,
+			})
+		}
+		return result, nil
+
+	case jobTask:
+		progress(jobProgress{Step: "Task " + spec.Task, Total: 1})
+		if s.lock != nil {
+			if spec.Task == "12" || spec.Task == "13" {
+				s.lock.Lock()
+				defer s.lock.Unlock()
+			} else {
+				s.lock.RLock()
+				defer s.lock.RUnlock()
+			}
+		}
+		return runTask(s.engine, spec.Task, spec.Args)
+
+	case jobPageRank:
+		var g *graph.Graph
+		if memory, ok := asMemoryEngine(s.engine); ok {
+			g = memory.g
+			s.lock.RLock()
+			defer s.lock.RUnlock()
+		} else {
+			progress(jobProgress{Step: "Load graph"})
+			var err error
+			if g, err = loadGraph(); err != nil {
+				return utils.ResultSet{}, fmt.Errorf("failed to load graph: %w", err)
+			}
+		}
+		scores, iterations, err := g.PageRankContext(ctx, directions[spec.Direction], spec.Damping, spec.Tolerance, spec.MaxIterations, func(iteration int) {
+			progress(jobProgress{Step: "PageRank", Done: iteration, Total: spec.MaxIterations})
+		})
+		if err != nil {
+			return utils.ResultSet{}, fmt.Errorf("PageRank stopped after %d iterations: %w", iterations, err)
+		}
+		return rankScores(g, scores, spec.Top), nil
+
+	default:
+		return utils.ResultSet{}, fmt.Errorf("unknown job kind %q", spec.Kind)
+	}
+}
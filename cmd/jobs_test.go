@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"dbcli/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForJob polls until the job reaches a final state
+func waitForJob(t *testing.T, m *jobManager, id string) job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, ok := m.get(id); ok && j.finished() {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return job{}
+}
+
+func TestJobsRunAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	api := newAPIServer(newFixtureEngine())
+	m, err := newJobManager(path, 2, 10, 0, api.runJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pagerank, err := m.submit(jobSpec{Kind: jobPageRank, Top: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := m.submit(jobSpec{Kind: jobTask, Task: "14", Args: []string{"root", "target", "3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := waitForJob(t, m, pagerank.ID)
+	if done.Status != jobSucceeded || len(done.Result.Result) != 3 {
+		t.Fatalf("pagerank job = %+v, want 3 results", done)
+	}
+	if done.Result.Result[0]["name"] != "d" {
+		t.Errorf("top category = %v, want d", done.Result.Result[0]["name"])
+	}
+	if done.Progress.Step != "PageRank" || done.Progress.Done == 0 {
+		t.Errorf("progress = %+v", done.Progress)
+	}
+	if done := waitForJob(t, m, task.ID); done.Status != jobSucceeded || len(done.Result.Result) != 5 {
+		t.Errorf("task job = %+v, want 5 results", done)
+	}
+	if err := m.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// results are stored apart from the job metadata
+	if data, err := os.ReadFile(path); err != nil || strings.Contains(string(data), `"result"`) {
+		t.Errorf("jobs file holds results: %s, %v", data, err)
+	}
+
+	reloaded, err := newJobManager(path, 1, 10, 0, api.runJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.close(context.Background())
+	j, ok := reloaded.get(pagerank.ID)
+	if !ok || j.Status != jobSucceeded || len(j.Result.Result) != 3 {
+		t.Errorf("reloaded job = %+v, want the finished pagerank job", j)
+	}
+	if jobs := reloaded.list(); len(jobs) != 2 || jobs[0].Result != nil {
+		t.Errorf("list = %+v, want two jobs without results", jobs)
+	}
+}
+
+func TestJobsRestartRequeuesAndFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	created := time.Now()
+	saved, err := json.Marshal([]*job{
+		{ID: "queued", Spec: jobSpec{Kind: jobTask, Task: "7"}, Status: jobQueued, Created: created},
+		{ID: "running", Spec: jobSpec{Kind: jobTask, Task: "7"}, Status: jobRunning, Created: created, Started: &created},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, saved, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newJobManager(path, 1, 1, 0, func(ctx context.Context, spec jobSpec, progress func(jobProgress)) (utils.ResultSet, error) {
+		return utils.ResultSet{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.close(context.Background())
+	if j := waitForJob(t, m, "queued"); j.Status != jobSucceeded {
+		t.Errorf("requeued job = %+v, want succeeded", j)
+	}
+	if j, _ := m.get("running"); j.Status != jobFailed || j.Error != "interrupted by restart" {
+		t.Errorf("interrupted job = %+v, want failed", j)
+	}
+}
+
+func TestJobsQueueFull(t *testing.T) {
+	// without workers jobs stay queued
+	m, err := newJobManager("", 0, 1, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.close(context.Background())
+	if _, err := m.submit(jobSpec{Kind: jobTask, Task: "7"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.submit(jobSpec{Kind: jobTask, Task: "7"}); !errors.Is(err, errQueueFull) {
+		t.Errorf("second submit: %v, want errQueueFull", err)
+	}
+}
+
+func TestJobsCancel(t *testing.T) {
+	started := make(chan struct{})
+	m, err := newJobManager("", 1, 10, 0, func(ctx context.Context, spec jobSpec, progress func(jobProgress)) (utils.ResultSet, error) {
+		close(started)
+		<-ctx.Done()
+		return utils.ResultSet{}, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.close(context.Background())
+
+	running, err := m.submit(jobSpec{Kind: jobTask, Task: "1", Args: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := m.submit(jobSpec{Kind: jobTask, Task: "2", Args: []string{"root"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if j, err := m.cancelJob(queued.ID); err != nil || j.Status != jobCanceled {
+		t.Errorf("cancel queued = %+v, %v", j, err)
+	}
+	if j, err := m.cancelJob(running.ID); err != nil || j.Status != jobCanceling {
+		t.Errorf("cancel running = %+v, %v", j, err)
+	}
+	if j := waitForJob(t, m, running.ID); j.Status != jobCanceled || j.Error != "" {
+		t.Errorf("canceled job = %+v", j)
+	}
+	if _, err := m.cancelJob(running.ID); !errors.Is(err, errJobFinished) {
+		t.Errorf("second cancel: %v, want errJobFinished", err)
+	}
+	if _, err := m.cancelJob("missing"); !errors.Is(err, errJobNotFound) {
+		t.Errorf("cancel missing: %v, want errJobNotFound", err)
+	}
+}
+
+func TestJobsEndpoints(t *testing.T) {
+	api := newAPIServer(newFixtureEngine())
+	m, err := newJobManager("", 1, 10, 0, api.runJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.close(context.Background())
+	api.handleJobs(m)
+
+	if status, response := request(t, api, http.MethodPost, "/v1/jobs", `{"kind": "backup"}`); status != http.StatusBadRequest {
+		t.Errorf("unknown kind = %d %v, want 400", status, response)
+	}
+	if status, _ := request(t, api, http.MethodPost, "/v1/jobs", `{"kind": "pagerank", "direction": "sideways"}`); status != http.StatusBadRequest {
+		t.Errorf("bad direction = %d, want 400", status)
+	}
+	if status, response := request(t, api, http.MethodPost, "/v1/jobs", `{"kind": "task", "task": "16", "args": ["root", "one", "2"]}`); status != http.StatusBadRequest || !strings.Contains(response["error"].(string), "radius") {
+		t.Errorf("bad task argument = %d %v, want 400", status, response)
+	}
+	status, response := request(t, api, http.MethodPost, "/v1/jobs", `{"kind": "task", "task": "8"}`)
+	if status != http.StatusAccepted {
+		t.Fatalf("submit = %d %v, want 202", status, response)
+	}
+	id := response["id"].(string)
+	waitForJob(t, m, id)
+	status, response = request(t, api, http.MethodGet, "/v1/jobs/"+id, "")
+	if status != http.StatusOK || response["status"] != jobSucceeded {
+		t.Errorf("GET job = %d %v", status, response)
+	}
+	if status, _ := request(t, api, http.MethodDelete, "/v1/jobs/"+id, ""); status != http.StatusConflict {
+		t.Errorf("DELETE finished job = %d, want 409", status)
+	}
+	if status, _ := request(t, api, http.MethodGet, "/v1/jobs/missing", ""); status != http.StatusNotFound {
+		t.Errorf("GET missing job = %d, want 404", status)
+	}
+}
+
+func TestJobsImportRootAndExclusion(t *testing.T) {
+	root := writeFixtureFiles(t)
+	saved := serveImportRoot
+	serveImportRoot = root
+	t.Cleanup(func() { serveImportRoot = saved })
+
+	for _, dir := range []string{"..", "../data", root} {
+		spec := jobSpec{Kind: jobImport, DataDir: dir}
+		if err := spec.normalize(); err == nil || !strings.Contains(err.Error(), "import root") {
+			t.Errorf("dataDir %s: %v, want an import root error", dir, err)
+		}
+	}
+
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	m, err := newJobManager("", 2, 10, 0, func(ctx context.Context, spec jobSpec, progress func(jobProgress)) (utils.ResultSet, error) {
+		if spec.DataDir != root {
+			t.Errorf("import of %s, want %s", spec.DataDir, root)
+		}
+		started <- struct{}{}
+		<-ctx.Done()
+		// an import only notices the cancellation between batches
+		<-release
+		return utils.ResultSet{}, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.close(context.Background())
+
+	running, err := m.submit(jobSpec{Kind: jobImport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err := m.submit(jobSpec{Kind: jobImport}); !errors.Is(err, errImportRunning) {
+		t.Errorf("second import: %v, want errImportRunning", err)
+	}
+	if _, err := m.cancelJob(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	// the canceled import still runs until it stops
+	if _, err := m.submit(jobSpec{Kind: jobImport}); !errors.Is(err, errImportRunning) {
+		t.Errorf("import while canceling: %v, want errImportRunning", err)
+	}
+	close(release)
+	if j := waitForJob(t, m, running.ID); j.Status != jobCanceled {
+		t.Errorf("canceled import = %+v", j)
+	}
+	next, err := m.submit(jobSpec{Kind: jobImport})
+	if err != nil {
+		t.Fatalf("import after cancellation: %v", err)
+	}
+	<-started
+	if _, err := m.cancelJob(next.ID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	lcaRankDistance   = "distance"
+	lcaRankPopularity = "popularity"
+)
+
+var (
+	lcaRank  string
+	lcaLimit int
+)
+
+// lcaCmd finds the broadest categories shared by several categories
+var lcaCmd = &cobra.Command{
+	Use:   "lca [name] [name]...",
+	Short: "Find the lowest common ancestors of two or more categories",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var g *graph.Graph
+		var err error
+		if engineName == engineOrientDB {
+			g, err = fetchSubgraphs(args, graph.In, -1)
+		} else {
+			g, err = loadGraph()
+		}
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+
+		inputs := make([]int32, len(args))
+		for i, name := range args {
+			v, ok := g.Lookup(name)
+			if !ok {
+				log.Fatalf("Vertex %q not found", name)
+			}
+			inputs[i] = v
+		}
+
+		ancestors := g.LowestCommonAncestors(inputs)
+		if err := sortCommonAncestors(g, ancestors, lcaRank); err != nil {
+			log.Fatal(err)
+		}
+		if lcaLimit > 0 && len(ancestors) > lcaLimit {
+			ancestors = ancestors[:lcaLimit]
+		}
+		if len(ancestors) == 0 {
+			log.Printf("No common ancestor of %s", strings.Join(args, ", "))
+		}
+
+		result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(ancestors))}
+		for _, ca := range ancestors {
+			distances := make(map[string]int, len(args))
+			parts := make([]string, len(args))
+			for i, name := range args {
+				distances[name] = ca.Distances[i]
+				parts[i] = fmt.Sprintf("%s=%d", name, ca.Distances[i])
+			}
+			row := map[string]interface{}{
+				"name":          g.Name(ca.Vertex),
+				"popularity":    g.Popularity(ca.Vertex),
+				"totalDistance": ca.TotalDistance(),
+				"distances":     distances,
+			}
+			if outputFormat != utils.FormatJSON {
+				row["distances"] = strings.Join(parts, ", ")
+			}
+			result.Result = append(result.Result, row)
+		}
+		if err := utils.WriteResultSet(os.Stdout, outputFormat, result, []string{"name", "popularity", "totalDistance", "distances"}); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lcaCmd)
+	addGraphFlags(lcaCmd)
+	addOutputFlag(lcaCmd)
+	lcaCmd.Flags().StringVar(&lcaRank, "rank", lcaRankDistance, "rank by combined distance or by popularity")
+	lcaCmd.Flags().IntVar(&lcaLimit, "limit", 0, "maximum number of ancestors to print, 0 for all")
+}
+
+// sortCommonAncestors orders LCAs by combined distance (closest first, ties
+// broken by the farthest single input) or by popularity (highest first)
+func sortCommonAncestors(g *graph.Graph, ancestors []graph.CommonAncestor, rank string) error {
+	maxDistance := func(ca graph.CommonAncestor) int {
+		m := 0
+		for _, d := range ca.Distances {
+			if d > m {
+				m = d
+			}
+		}
+		return m
+	}
+
+	switch rank {
+	case lcaRankDistance:
+		sort.Slice(ancestors, func(i, j int) bool {
+			a, b := ancestors[i], ancestors[j]
+			if a.TotalDistance() != b.TotalDistance() {
+				return a.TotalDistance() < b.TotalDistance()
+			}
+			if maxDistance(a) != maxDistance(b) {
+				return maxDistance(a) < maxDistance(b)
+			}
+			return g.Popularity(a.Vertex) > g.Popularity(b.Vertex)
+		})
+	case lcaRankPopularity:
+		sort.Slice(ancestors, func(i, j int) bool {
+			a, b := ancestors[i], ancestors[j]
+			if g.Popularity(a.Vertex) != g.Popularity(b.Vertex) {
+				return g.Popularity(a.Vertex) > g.Popularity(b.Vertex)
+			}
+			return a.TotalDistance() < b.TotalDistance()
+		})
+	default:
+		return fmt.Errorf("unknown rank %q, expected %s or %s", rank, lcaRankDistance, lcaRankPopularity)
+	}
+	return nil
+}
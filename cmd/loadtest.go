@@ -0,0 +1,856 @@
+package cmd
+
+import (
+	"context"
+	"dbcli/cache"
+	"dbcli/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	loadtestSpecPath      string
+	loadtestConcurrency   int
+	loadtestRate          float64
+	loadtestDuration      time.Duration
+	loadtestRequests      int64
+	loadtestWriterRate    float64
+	loadtestIntervalWidth time.Duration
+	loadtestSeed          uint64
+	loadtestImportDir     string
+	loadtestJSONPath      string
+)
+
+// loadtestSpec describes a workload. Task arguments starting with $ name a
+// pool and are drawn from it for every operation.
+type loadtestSpec struct {
+	Concurrency int `json:"concurrency"`
+	// Rate is the number of operations started per second across all
+	// workers, 0 to let every worker run back to back
+	Rate     float64 `json:"rate"`
+	Duration string  `json:"duration"`
+	// Requests stops the test after this many operations, 0 for no limit
+	Requests int64                   `json:"requests"`
+	Seed     uint64                  `json:"seed"`
+	Pools    map[string]argumentPool `json:"pools"`
+	Mix      []workloadTask          `json:"mix"`
+	Writer   *writerSpec             `json:"writer,omitempty"`
+}
+
+// argumentPool supplies task arguments: fixed values, vertex names sampled
+// once before the test, or integers drawn from an inclusive range
+type argumentPool struct {
+	Values []string `json:"values,omitempty"`
+	Sample int      `json:"sample,omitempty"`
+	Range  []int    `json:"range,omitempty"`
+}
+
+// workloadTask is one entry of a weighted task mix
+type workloadTask struct {
+	Name   string   `json:"name"`
+	Task   string   `json:"task"`
+	Args   []string `json:"args"`
+	Weight float64  `json:"weight"`
+}
+
+// writerSpec runs a mix of mutating tasks at a fixed rate next to the readers
+type writerSpec struct {
+	Rate float64        `json:"rate"`
+	Mix  []workloadTask `json:"mix"`
+}
+
+// defaultLoadtestSpec is the workload used without --spec: the lookups of
+// tasks 1-6 on random categories with a few neighborhood sums
+func defaultLoadtestSpec() loadtestSpec {
+	return loadtestSpec{
+		Concurrency: 4,
+		Duration:    "30s",
+		Seed:        1,
+		Pools: map[string]argumentPool{
+			"name":       {Sample: 1000},
+			"popularity": {Range: []int{0, 100000}},
+		},
+		Mix: []workloadTask{
+			{Name: "children", Task: "1", Args: []string{"$name"}, Weight: 3},
+			{Name: "childCount", Task: "2", Args: []string{"$name"}, Weight: 2},
+			{Name: "grandchildren", Task: "3", Args: []string{"$name"}, Weight: 1},
+			{Name: "parents", Task: "4", Args: []string{"$name"}, Weight: 3},
+			{Name: "parentCount", Task: "5", Args: []string{"$name"}, Weight: 2},
+			{Name: "grandparents", Task: "6", Args: []string{"$name"}, Weight: 1},
+			{Name: "neighborhoodPopularity", Task: "16", Args: []string{"$name", "2", "2"}, Weight: 1},
+		},
+	}
+}
+
+// defaultWriterMix is what --writer-rate runs when the spec has no writer
+var defaultWriterMix = []workloadTask{
+	{Name: "updatePopularity", Task: "13", Args: []string{"$name", "$popularity"}, Weight: 1},
+}
+
+// loadtestCmd drives concurrent mixed workloads
+var loadtestCmd = &cobra.Command{
+	Use:   "loadtest",
+	Short: "Run a concurrent weighted mix of tasks and report throughput and latency",
+	Long: `Run a weighted mix of tasks from several concurrent workers for a duration
+or a number of operations, and report throughput, latency percentiles and
+error rates overall, per task and per --interval.
+
+The workload comes from a JSON spec (--spec) with the fields concurrency,
+rate, duration, requests, seed, pools, mix and writer; flags given on the
+command line override the spec. Task arguments starting with $ are drawn
+from the pool of that name for every operation:
+
+  {
+    "pools": {
+      "name": {"sample": 1000},
+      "depth": {"values": ["2", "3"]},
+      "popularity": {"range": [0, 100000]}
+    },
+    "mix": [
+      {"name": "children", "task": "1", "args": ["$name"], "weight": 3},
+      {"name": "path", "task": "17", "args": ["$name", "$name", "$depth"], "weight": 1}
+    ],
+    "writer": {"rate": 2, "mix": [{"task": "13", "args": ["$name", "$popularity"]}]}
+  }
+
+A "sample" pool holds vertex names picked at random before the test starts.
+With --rate 0 every worker starts its next operation as soon as the last one
+finishes; with a rate, operations are scheduled at fixed times and latency is
+measured from the scheduled time, so queueing behind slow operations counts.
+The writer runs its mix from a single extra worker at its own rate and
+changes the data. --import runs an import of the given directory while the
+load is applied.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		spec := defaultLoadtestSpec()
+		if loadtestSpecPath != "" {
+			// the spec replaces the default mix; its pools are added to the defaults
+			spec.Mix = nil
+			data, err := os.ReadFile(loadtestSpecPath)
+			if err != nil {
+				log.Fatalf("Failed to read spec: %v", err)
+			}
+			if err := json.Unmarshal(data, &spec); err != nil {
+				log.Fatalf("Failed to parse spec %s: %v", loadtestSpecPath, err)
+			}
+		}
+		if err := applyLoadtestFlags(cmd, &spec); err != nil {
+			log.Fatal(err)
+		}
+		if loadtestImportDir != "" && engineName != engineOrientDB {
+			log.Fatalf("--import requires the %s engine", engineOrientDB)
+		}
+
+		engine, err := newTaskEngine()
+		if err != nil {
+			log.Fatalf("Failed to initialize %s engine: %v", engineName, err)
+		}
+		run, err := newLoadtestRun(engine, spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report := run.execute(loadtestImportDir, loadtestIntervalWidth)
+
+		if err := writeLoadtestReport(report); err != nil {
+			log.Fatal(err)
+		}
+		logCacheStats()
+		if loadtestJSONPath != "" {
+			if err := writeJSONFile(loadtestJSONPath, report); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Wrote load test report to %s", loadtestJSONPath)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loadtestCmd)
+	addGraphFlags(loadtestCmd)
+	addOutputFlag(loadtestCmd)
+	addCacheFlags(loadtestCmd)
+	defaults := defaultLoadtestSpec()
+	loadtestCmd.Flags().StringVar(&loadtestSpecPath, "spec", "", "JSON workload spec (default: tasks 1-6 and 16 on random categories)")
+	loadtestCmd.Flags().IntVar(&loadtestConcurrency, "concurrency", defaults.Concurrency, "concurrent workers")
+	loadtestCmd.Flags().Float64Var(&loadtestRate, "rate", defaults.Rate, "operations started per second across all workers, 0 for back to back")
+	loadtestCmd.Flags().DurationVar(&loadtestDuration, "duration", 30*time.Second, "how long to apply the load, 0 for no limit")
+	loadtestCmd.Flags().Int64Var(&loadtestRequests, "requests", 0, "stop after this many operations, 0 for no limit")
+	loadtestCmd.Flags().Float64Var(&loadtestWriterRate, "writer-rate", 0, "operations per second of the writer (task 13 on random categories unless the spec has a writer), 0 for none")
+	loadtestCmd.Flags().DurationVar(&loadtestIntervalWidth, "interval", time.Second, "width of the timeline intervals")
+	loadtestCmd.Flags().Uint64Var(&loadtestSeed, "seed", defaults.Seed, "seed for sampling names and drawing tasks and arguments")
+	loadtestCmd.Flags().StringVar(&loadtestImportDir, "import", "", "data directory to import while the load runs (orientdb engine)")
+	loadtestCmd.Flags().StringVar(&loadtestJSONPath, "json", "", "also write the report as JSON to this file")
+}
+
+// applyLoadtestFlags overrides the spec with the flags set on the command line
+func applyLoadtestFlags(cmd *cobra.Command, spec *loadtestSpec) error {
+	flags := cmd.Flags()
+	if flags.Changed("concurrency") {
+		spec.Concurrency = loadtestConcurrency
+	}
+	if flags.Changed("rate") {
+		spec.Rate = loadtestRate
+	}
+	if flags.Changed("duration") {
+		spec.Duration = loadtestDuration.String()
+	}
+	if flags.Changed("requests") {
+		spec.Requests = loadtestRequests
+	}
+	if flags.Changed("seed") {
+		spec.Seed = loadtestSeed
+	}
+	if flags.Changed("writer-rate") {
+		if spec.Writer == nil {
+			spec.Writer = &writerSpec{Mix: defaultWriterMix}
+		}
+		spec.Writer.Rate = loadtestWriterRate
+	}
+	if spec.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	return nil
+}
+
+// workload draws tasks from a weighted mix and fills in their arguments
+type workload struct {
+	tasks      []workloadTask
+	cumulative []float64
+	pools      map[string]func(rng *rand.Rand) string
+	// offset is the index of the first task in the report
+	offset int
+}
+
+// newWorkload checks the mix and resolves its pool references. Every task
+// is parsed with arguments drawn from its pools, so a bad task number or
+// argument fails before the load starts.
+func newWorkload(mix []workloadTask, pools map[string]func(rng *rand.Rand) string, offset int) (*workload, error) {
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("the task mix is empty")
+	}
+	w := &workload{pools: pools, offset: offset}
+	sampleRNG := rand.New(rand.NewPCG(0, 0))
+	total := 0.0
+	for _, task := range mix {
+		if task.Name == "" {
+			task.Name = "task" + task.Task
+		}
+		if task.Weight < 0 {
+			return nil, fmt.Errorf("task %s has a negative weight", task.Name)
+		}
+		if task.Weight == 0 {
+			task.Weight = 1
+		}
+		sample := make([]string, len(task.Args))
+		for i, arg := range task.Args {
+			sample[i] = arg
+			if ref, ok := strings.CutPrefix(arg, "$"); ok {
+				pool, ok := pools[ref]
+				if !ok {
+					return nil, fmt.Errorf("task %s uses unknown pool %q", task.Name, ref)
+				}
+				sample[i] = pool(sampleRNG)
+			}
+		}
+		if _, err := parseTask(task.Task, sample); err != nil {
+			return nil, fmt.Errorf("task %s: %w", task.Name, err)
+		}
+		total += task.Weight
+		w.tasks = append(w.tasks, task)
+		w.cumulative = append(w.cumulative, total)
+	}
+	return w, nil
+}
+
+// draw picks a task by weight and returns its report index and arguments
+func (w *workload) draw(rng *rand.Rand) (int, []string) {
+	r := rng.Float64() * w.cumulative[len(w.cumulative)-1]
+	i := sort.SearchFloat64s(w.cumulative, r)
+	if i == len(w.tasks) {
+		i--
+	}
+	args := make([]string, len(w.tasks[i].Args))
+	for j, arg := range w.tasks[i].Args {
+		if ref, ok := strings.CutPrefix(arg, "$"); ok {
+			args[j] = w.pools[ref](rng)
+		} else {
+			args[j] = arg
+		}
+	}
+	return w.offset + i, args
+}
+
+// resolvePools turns the pool specs into drawing functions, sampling names
+// from the engine where asked
+func resolvePools(engine taskEngine, specs map[string]argumentPool, rng *rand.Rand) (map[string]func(rng *rand.Rand) string, error) {
+	pools := make(map[string]func(rng *rand.Rand) string, len(specs))
+	for name, spec := range specs {
+		values := spec.Values
+		switch {
+		case len(spec.Range) > 0:
+			if len(spec.Range) != 2 || spec.Range[0] > spec.Range[1] {
+				return nil, fmt.Errorf("pool %s: range must be [min, max]", name)
+			}
+			low, span := spec.Range[0], spec.Range[1]-spec.Range[0]+1
+			pools[name] = func(rng *rand.Rand) string { return strconv.Itoa(low + rng.IntN(span)) }
+			continue
+		case spec.Sample > 0:
+			sampled, err := engine.sampleNames(spec.Sample, rng)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sample names for pool %s: %w", name, err)
+			}
+			values = sampled
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("pool %s has no values", name)
+		}
+		pools[name] = func(rng *rand.Rand) string { return values[rng.IntN(len(values))] }
+	}
+	return pools, nil
+}
+
+// operation is one timed task execution
+type operation struct {
+	task int
+	// done is when the operation finished, since the test started
+	done    time.Duration
+	latency time.Duration
+	failed  bool
+}
+
+// loadRecorder collects the operations of one worker, so workers never
+// contend on a lock while recording
+type loadRecorder struct {
+	operations []operation
+	errors     map[string]int64
+}
+
+// loadtestRun is a prepared load test
+type loadtestRun struct {
+	spec     loadtestSpec
+	engine   taskEngine
+	duration time.Duration
+	readers  *workload
+	writer   *workload
+	// lock serializes mutating tasks against the others on the memory
+	// engine, whose graph is not safe for concurrent writes
+	lock *sync.RWMutex
+
+	started    time.Time
+	issued     atomic.Int64
+	completed  atomic.Int64
+	failed     atomic.Int64
+	recordersM sync.Mutex
+	recorders  []*loadRecorder
+}
+
+// newLoadtestRun validates the spec and samples the argument pools
+func newLoadtestRun(engine taskEngine, spec loadtestSpec) (*loadtestRun, error) {
+	duration, err := time.ParseDuration(spec.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %q: %w", spec.Duration, err)
+	}
+	if duration <= 0 && spec.Requests <= 0 {
+		return nil, fmt.Errorf("either a duration or a number of requests is needed")
+	}
+	run := &loadtestRun{spec: spec, engine: engine, duration: duration}
+	if _, ok := asMemoryEngine(engine); ok {
+		run.lock = &sync.RWMutex{}
+	}
+
+	pools, err := resolvePools(engine, spec.Pools, rand.New(rand.NewPCG(spec.Seed, math.MaxUint64)))
+	if err != nil {
+		return nil, err
+	}
+	if run.readers, err = newWorkload(spec.Mix, pools, 0); err != nil {
+		return nil, err
+	}
+	if spec.Writer != nil && spec.Writer.Rate > 0 {
+		if run.writer, err = newWorkload(spec.Writer.Mix, pools, len(run.readers.tasks)); err != nil {
+			return nil, fmt.Errorf("writer: %w", err)
+		}
+	}
+	return run, nil
+}
+
+// newRecorder registers the recorder of a new worker
+func (r *loadtestRun) newRecorder() *loadRecorder {
+	recorder := &loadRecorder{errors: make(map[string]int64)}
+	r.recordersM.Lock()
+	r.recorders = append(r.recorders, recorder)
+	r.recordersM.Unlock()
+	return recorder
+}
+
+// perform runs one drawn operation and records it. It returns false once the
+// request limit is reached.
+func (r *loadtestRun) perform(w *workload, rng *rand.Rand, recorder *loadRecorder, scheduled time.Time) bool {
+	if r.spec.Requests > 0 && r.issued.Add(1) > r.spec.Requests {
+		return false
+	}
+	task, args := w.draw(rng)
+	number := w.tasks[task-w.offset].Task
+	if r.lock != nil {
+		if number == "12" || number == "13" {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+		} else {
+			r.lock.RLock()
+			defer r.lock.RUnlock()
+		}
+	}
+
+	_, err := runTask(r.engine, number, args)
+	now := time.Now()
+	op := operation{task: task, done: now.Sub(r.started), latency: now.Sub(scheduled), failed: err != nil}
+	recorder.operations = append(recorder.operations, op)
+	r.completed.Add(1)
+	if err != nil {
+		r.failed.Add(1)
+		recorder.errors[err.Error()]++
+	}
+	return true
+}
+
+// closedLoop runs workers that start their next operation as soon as the
+// previous one is done
+func (r *loadtestRun) closedLoop(ctx context.Context, stop context.CancelFunc, w *workload, workers int, stream uint64) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			recorder := r.newRecorder()
+			for ctx.Err() == nil {
+				if !r.perform(w, rng, recorder, time.Now()) {
+					stop()
+					return
+				}
+			}
+		}(rand.New(rand.NewPCG(r.spec.Seed, stream+uint64(i))))
+	}
+	return &wg
+}
+
+// openLoop schedules operations at a fixed rate and hands them to workers.
+// Latency is measured from the scheduled time, so operations that wait for
+// a free worker are not hidden by coordinated omission.
+func (r *loadtestRun) openLoop(ctx context.Context, stop context.CancelFunc, w *workload, rate float64, workers int, stream uint64) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	schedule := make(chan time.Time)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			recorder := r.newRecorder()
+			for scheduled := range schedule {
+				if !r.perform(w, rng, recorder, scheduled) {
+					stop()
+					return
+				}
+			}
+		}(rand.New(rand.NewPCG(r.spec.Seed, stream+uint64(i))))
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(schedule)
+		interval := time.Duration(float64(time.Second) / rate)
+		next := time.Now()
+		for {
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			// the schedule never slips: an operation that is late, because
+			// the timer fired late or every worker was busy, keeps its
+			// scheduled time and the delay counts towards its latency
+			select {
+			case <-ctx.Done():
+				return
+			case schedule <- next:
+			}
+			next = next.Add(interval)
+		}
+	}()
+	return &wg
+}
+
+// execute applies the load, optionally alongside an import, and builds the
+// report
+func (r *loadtestRun) execute(importDir string, interval time.Duration) loadtestReport {
+	ctx, stop := context.WithCancel(context.Background())
+	if r.duration > 0 {
+		ctx, stop = context.WithTimeout(context.Background(), r.duration)
+	}
+	defer stop()
+	r.started = time.Now()
+
+	var importPhases []importPhase
+	var importErr error
+	importDone := make(chan struct{})
+	if importDir != "" {
+		go func() {
+			defer close(importDone)
+			importPhases, importErr = runImport(importDir)
+		}()
+	} else {
+		close(importDone)
+	}
+
+	var groups []*sync.WaitGroup
+	if r.spec.Rate > 0 {
+		groups = append(groups, r.openLoop(ctx, stop, r.readers, r.spec.Rate, r.spec.Concurrency, 0))
+	} else {
+		groups = append(groups, r.closedLoop(ctx, stop, r.readers, r.spec.Concurrency, 0))
+	}
+	if r.writer != nil {
+		groups = append(groups, r.openLoop(ctx, stop, r.writer, r.spec.Writer.Rate, 1, 1<<32))
+	}
+
+	progress := time.NewTicker(interval)
+	defer progress.Stop()
+	var lastCompleted, lastFailed int64
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-progress.C:
+			completed, failed := r.completed.Load(), r.failed.Load()
+			log.Printf("%s: %.1f ops/s, %d errors", time.Since(r.started).Round(time.Second),
+				float64(completed-lastCompleted)/interval.Seconds(), failed-lastFailed)
+			lastCompleted, lastFailed = completed, failed
+		}
+	}
+	for _, wg := range groups {
+		wg.Wait()
+	}
+	elapsed := time.Since(r.started)
+
+	if importDir != "" {
+		log.Printf("Waiting for the import to finish")
+		<-importDone
+	}
+	report := r.report(elapsed, interval)
+	if importDir != "" {
+		for _, phase := range importPhases {
+			report.Import = append(report.Import, loadtestPhase{Name: phase.Name, Ms: milliseconds(phase.Duration)})
+		}
+		if importErr != nil {
+			report.ImportError = importErr.Error()
+		}
+	}
+	return report
+}
+
+// latencySummary describes a set of latencies in milliseconds
+type latencySummary struct {
+	MinMs  float64 `json:"minMs"`
+	MeanMs float64 `json:"meanMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P95Ms  float64 `json:"p95Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+// summarizeLatencies sorts latencies and summarizes them
+func summarizeLatencies(latencies []float64) latencySummary {
+	if len(latencies) == 0 {
+		return latencySummary{}
+	}
+	slices.Sort(latencies)
+	mean, _ := meanStddev(latencies)
+	return latencySummary{
+		MinMs:  latencies[0],
+		MeanMs: mean,
+		P50Ms:  percentile(latencies, 0.50),
+		P95Ms:  percentile(latencies, 0.95),
+		P99Ms:  percentile(latencies, 0.99),
+		MaxMs:  latencies[len(latencies)-1],
+	}
+}
+
+// histogramBounds are the upper bounds of the latency histogram buckets in
+// milliseconds, from 100µs to 50s in 1-2-5 steps
+var histogramBounds = func() []float64 {
+	var bounds []float64
+	for scale := 0.1; scale < 1e5; scale *= 10 {
+		bounds = append(bounds, scale, 2*scale, 5*scale)
+	}
+	return bounds
+}()
+
+// histogramBucket counts the latencies up to Le and above the previous bound
+type histogramBucket struct {
+	Le    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+// latencyHistogram returns the non-empty buckets of the latencies
+func latencyHistogram(latencies []float64) []histogramBucket {
+	counts := make([]int64, len(histogramBounds)+1)
+	for _, ms := range latencies {
+		counts[sort.SearchFloat64s(histogramBounds, ms)]++
+	}
+	var buckets []histogramBucket
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		le := "+Inf"
+		if i < len(histogramBounds) {
+			le = formatMillis(histogramBounds[i])
+		}
+		buckets = append(buckets, histogramBucket{Le: le, Count: count})
+	}
+	return buckets
+}
+
+// loadtestTaskReport summarizes one entry of the mix
+type loadtestTaskReport struct {
+	Name       string         `json:"name"`
+	Task       string         `json:"task"`
+	Writer     bool           `json:"writer,omitempty"`
+	Operations int64          `json:"operations"`
+	Errors     int64          `json:"errors"`
+	Latency    latencySummary `json:"latency"`
+}
+
+// loadtestInterval summarizes the operations that finished in one interval
+type loadtestInterval struct {
+	StartSec   float64           `json:"startSec"`
+	Operations int64             `json:"operations"`
+	Errors     int64             `json:"errors"`
+	Throughput float64           `json:"throughput"`
+	Latency    latencySummary    `json:"latency"`
+	Histogram  []histogramBucket `json:"histogram"`
+}
+
+// loadtestPhase is an import phase timed during the test
+type loadtestPhase struct {
+	Name string  `json:"name"`
+	Ms   float64 `json:"ms"`
+}
+
+// loadtestReport is the outcome of a load test. Latencies only cover
+// successful operations.
+type loadtestReport struct {
+	Engine        string               `json:"engine"`
+	Started       time.Time            `json:"started"`
+	ElapsedSec    float64              `json:"elapsedSec"`
+	Concurrency   int                  `json:"concurrency"`
+	Rate          float64              `json:"rate"`
+	WriterRate    float64              `json:"writerRate,omitempty"`
+	Operations    int64                `json:"operations"`
+	Errors        int64                `json:"errors"`
+	ErrorRate     float64              `json:"errorRate"`
+	Throughput    float64              `json:"throughput"`
+	Latency       latencySummary       `json:"latency"`
+	Histogram     []histogramBucket    `json:"histogram"`
+	Tasks         []loadtestTaskReport `json:"tasks"`
+	Timeline      []loadtestInterval   `json:"timeline"`
+	ErrorMessages map[string]int64     `json:"errorMessages,omitempty"`
+	Import        []loadtestPhase      `json:"import,omitempty"`
+	ImportError   string               `json:"importError,omitempty"`
+	Cache         *cache.Stats         `json:"cache,omitempty"`
+}
+
+// report merges the recorders into a report
+func (r *loadtestRun) report(elapsed, interval time.Duration) loadtestReport {
+	report := loadtestReport{
+		Engine:      engineName,
+		Started:     r.started,
+		ElapsedSec:  elapsed.Seconds(),
+		Concurrency: r.spec.Concurrency,
+		Rate:        r.spec.Rate,
+	}
+	if resultCache != nil {
+		stats := resultCache.Stats()
+		report.Cache = &stats
+	}
+	tasks := slices.Clone(r.readers.tasks)
+	if r.writer != nil {
+		report.WriterRate = r.spec.Writer.Rate
+		tasks = append(tasks, r.writer.tasks...)
+	}
+
+	var all []float64
+	perTask := make([][]float64, len(tasks))
+	report.Tasks = make([]loadtestTaskReport, len(tasks))
+	for i, task := range tasks {
+		report.Tasks[i] = loadtestTaskReport{Name: task.Name, Task: task.Task, Writer: i >= len(r.readers.tasks)}
+	}
+	// a short tail after the last full interval is folded into it
+	intervals := max(1, int(math.Round(float64(elapsed)/float64(interval))))
+	perInterval := make([][]float64, intervals)
+	report.Timeline = make([]loadtestInterval, intervals)
+	for _, recorder := range r.recorders {
+		for _, op := range recorder.operations {
+			slot := min(int(op.done/interval), intervals-1)
+			report.Operations++
+			report.Tasks[op.task].Operations++
+			report.Timeline[slot].Operations++
+			if op.failed {
+				report.Errors++
+				report.Tasks[op.task].Errors++
+				report.Timeline[slot].Errors++
+				continue
+			}
+			ms := milliseconds(op.latency)
+			all = append(all, ms)
+			perTask[op.task] = append(perTask[op.task], ms)
+			perInterval[slot] = append(perInterval[slot], ms)
+		}
+		for message, count := range recorder.errors {
+			if report.ErrorMessages == nil {
+				report.ErrorMessages = make(map[string]int64)
+			}
+			report.ErrorMessages[message] += count
+		}
+	}
+
+	if report.Operations > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(report.Operations)
+	}
+	report.Throughput = float64(report.Operations) / elapsed.Seconds()
+	report.Histogram = latencyHistogram(all)
+	report.Latency = summarizeLatencies(all)
+	for i := range report.Tasks {
+		report.Tasks[i].Latency = summarizeLatencies(perTask[i])
+	}
+	for i := range report.Timeline {
+		width := interval
+		if i == intervals-1 {
+			// the last interval is stretched or cut short by the end of the test
+			width = elapsed - time.Duration(i)*interval
+		}
+		report.Timeline[i].StartSec = (time.Duration(i) * interval).Seconds()
+		report.Timeline[i].Throughput = float64(report.Timeline[i].Operations) / width.Seconds()
+		report.Timeline[i].Histogram = latencyHistogram(perInterval[i])
+		report.Timeline[i].Latency = summarizeLatencies(perInterval[i])
+	}
+	return report
+}
+
+// writeLoadtestReport prints the report as JSON, or as a series of tables in
+// table or Markdown format
+func writeLoadtestReport(report loadtestReport) error {
+	switch outputFormat {
+	case utils.FormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case utils.FormatTable, utils.FormatMarkdown:
+	default:
+		return fmt.Errorf("unknown output format %q, expected %s, %s or %s", outputFormat, utils.FormatTable, utils.FormatJSON, utils.FormatMarkdown)
+	}
+
+	summary := [][2]interface{}{
+		{"Engine", report.Engine},
+		{"Elapsed", formatMillis(report.ElapsedSec * 1000)},
+		{"Concurrency", report.Concurrency},
+		{"Operations", report.Operations},
+		{"Throughput (ops/s)", report.Throughput},
+		{"Errors", report.Errors},
+		{"Error rate", fmt.Sprintf("%.2f%%", report.ErrorRate*100)},
+		{"Latency p50", formatMillis(report.Latency.P50Ms)},
+		{"Latency p95", formatMillis(report.Latency.P95Ms)},
+		{"Latency p99", formatMillis(report.Latency.P99Ms)},
+		{"Latency max", formatMillis(report.Latency.MaxMs)},
+	}
+	summaryRows := utils.ResultSet{}
+	for _, row := range summary {
+		summaryRows.Result = append(summaryRows.Result, map[string]interface{}{"metric": row[0], "value": row[1]})
+	}
+
+	latencyRow := func(row map[string]interface{}, latency latencySummary) map[string]interface{} {
+		row["p50"] = formatMillis(latency.P50Ms)
+		row["p95"] = formatMillis(latency.P95Ms)
+		row["p99"] = formatMillis(latency.P99Ms)
+		row["max"] = formatMillis(latency.MaxMs)
+		return row
+	}
+	taskRows := utils.ResultSet{}
+	for _, task := range report.Tasks {
+		name := task.Name
+		if task.Writer {
+			name += " (writer)"
+		}
+		taskRows.Result = append(taskRows.Result, latencyRow(map[string]interface{}{
+			"name":   name,
+			"task":   task.Task,
+			"ops":    task.Operations,
+			"errors": task.Errors,
+		}, task.Latency))
+	}
+	timelineRows := utils.ResultSet{}
+	for _, slot := range report.Timeline {
+		timelineRows.Result = append(timelineRows.Result, latencyRow(map[string]interface{}{
+			"time":   formatMillis(slot.StartSec * 1000),
+			"ops/s":  slot.Throughput,
+			"errors": slot.Errors,
+		}, slot.Latency))
+	}
+	histogramRows := utils.ResultSet{}
+	for _, bucket := range report.Histogram {
+		histogramRows.Result = append(histogramRows.Result, map[string]interface{}{"le": bucket.Le, "operations": bucket.Count})
+	}
+
+	sections := []reportSection{
+		{"Summary", summaryRows, []string{"metric", "value"}},
+		{"Tasks", taskRows, []string{"name", "task", "ops", "errors", "p50", "p95", "p99", "max"}},
+		{"Timeline", timelineRows, []string{"time", "ops/s", "errors", "p50", "p95", "p99", "max"}},
+		{"Latency histogram", histogramRows, []string{"le", "operations"}},
+	}
+	if len(report.ErrorMessages) > 0 {
+		errorRows := utils.ResultSet{}
+		for message, count := range report.ErrorMessages {
+			errorRows.Result = append(errorRows.Result, map[string]interface{}{"error": message, "count": count})
+		}
+		sort.Slice(errorRows.Result, func(i, j int) bool {
+			return errorRows.Result[i]["count"].(int64) > errorRows.Result[j]["count"].(int64)
+		})
+		sections = append(sections, reportSection{"Errors", errorRows, []string{"error", "count"}})
+	}
+	if len(report.Import) > 0 || report.ImportError != "" {
+		importRows := utils.ResultSet{}
+		for _, phase := range report.Import {
+			importRows.Result = append(importRows.Result, map[string]interface{}{"phase": phase.Name, "time": formatMillis(phase.Ms)})
+		}
+		if report.ImportError != "" {
+			importRows.Result = append(importRows.Result, map[string]interface{}{"phase": "failed", "time": report.ImportError})
+		}
+		sections = append(sections, reportSection{"Concurrent import", importRows, []string{"phase", "time"}})
+	}
+	return writeSections(sections)
+}
+
+// writeJSONFile writes v as indented JSON to path
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
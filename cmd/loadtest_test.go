@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestWorkloadDraw(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	pools, err := resolvePools(newFixtureEngine(), map[string]argumentPool{
+		"name":  {Values: []string{"a", "b"}},
+		"depth": {Range: []int{2, 3}},
+	}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newWorkload([]workloadTask{
+		{Task: "1", Args: []string{"$name"}, Weight: 3},
+		{Name: "paths", Task: "17", Args: []string{"$name", "target", "$depth"}},
+	}, pools, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.tasks[0].Name != "task1" || w.tasks[1].Weight != 1 {
+		t.Errorf("defaults not applied: %+v", w.tasks)
+	}
+
+	counts := make([]int, 2)
+	for i := 0; i < 4000; i++ {
+		task, args := w.draw(rng)
+		counts[task]++
+		if task == 1 && (args[1] != "target" || (args[2] != "2" && args[2] != "3")) {
+			t.Fatalf("unexpected arguments %v", args)
+		}
+	}
+	if counts[0] < 2800 || counts[0] > 3200 {
+		t.Errorf("task1 drawn %d of 4000 times, want about 3000", counts[0])
+	}
+
+	if _, err := newWorkload([]workloadTask{{Task: "1", Args: []string{"$missing"}}}, pools, 0); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected an unknown pool error, got %v", err)
+	}
+	// a typo in the mix fails up front, not on every request
+	for _, task := range []workloadTask{
+		{Task: "19"},
+		{Task: "1"},
+		{Task: "14", Args: []string{"$name", "target", "deep"}},
+	} {
+		if _, err := newWorkload([]workloadTask{task}, pools, 0); err == nil {
+			t.Errorf("task %s %v accepted", task.Task, task.Args)
+		}
+	}
+}
+
+func TestLoadtestRequests(t *testing.T) {
+	spec := loadtestSpec{
+		Concurrency: 3,
+		Duration:    "0s",
+		Requests:    200,
+		Pools:       map[string]argumentPool{"name": {Sample: 10}},
+		Mix: []workloadTask{
+			{Task: "1", Args: []string{"$name"}},
+			{Task: "13", Args: []string{"$name", "5"}},
+			// b exists, so renaming a to it fails every time
+			{Task: "12", Args: []string{"a", "b"}},
+		},
+	}
+	run, err := newLoadtestRun(newFixtureEngine(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := run.execute("", 1e9)
+	if report.Operations != 200 {
+		t.Errorf("ran %d operations, want 200", report.Operations)
+	}
+	if report.Errors != report.Tasks[2].Errors || report.Errors == 0 {
+		t.Errorf("errors %d, want all %d from the failing rename", report.Errors, report.Tasks[2].Errors)
+	}
+	total := int64(0)
+	for _, slot := range report.Timeline {
+		total += slot.Operations
+	}
+	if total != report.Operations {
+		t.Errorf("timeline holds %d operations, want %d", total, report.Operations)
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// The aggregates are stored in these Vertex properties. A record in the Meta
+// class named aggregatesMarker tells whether they are up to date.
+const (
+	levelProperty                = "level"
+	descendantsProperty          = "descendants"
+	descendantPopularityProperty = "descendantPopularity"
+
+	metaClass        = "Meta"
+	aggregatesMarker = "aggregates"
+)
+
+var (
+	materializeStatus bool
+	materializeDryRun bool
+	materializeTop    int
+)
+
+// materializeCmd precomputes hierarchy aggregates and stores them on Vertex
+var materializeCmd = &cobra.Command{
+	Use:   "materialize",
+	Short: "Precompute level, descendant count and descendant popularity for every category",
+	Long: `Compute for every category its level (fewest edges from a root), the number of
+descendants and their summed popularity, and store them in the indexed Vertex
+properties level, descendants and descendantPopularity. Cycles are handled:
+every descendant is counted once and a category never counts itself.
+
+A Meta record named "aggregates" records when they were computed and the sum
+of the Vertex record versions at that time. Every write to a Vertex, such as
+task 12 or 13 or another import, raises the sum, so --status reports the
+aggregates as stale until materialize runs again. Writes pay nothing for
+this; the check only runs with --status.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if materializeStatus {
+			printAggregatesStatus()
+			return
+		}
+
+		g, err := loadGraph()
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+		startCompute := time.Now()
+		agg := g.ComputeAggregates()
+		log.Printf("Computed aggregates in %s", time.Since(startCompute))
+
+		if !materializeDryRun {
+			startWrite := time.Now()
+			err := writeVertexProperties(g, []vertexProperty{
+				{Name: levelProperty, Type: "INTEGER", Value: func(v int32) string { return strconv.Itoa(int(agg.Level[v])) }},
+				{Name: descendantsProperty, Type: "LONG", Value: func(v int32) string { return strconv.FormatInt(agg.Descendants[v], 10) }},
+				{Name: descendantPopularityProperty, Type: "LONG", Value: func(v int32) string { return strconv.FormatInt(agg.DescendantPopularity[v], 10) }},
+			})
+			if err != nil {
+				log.Fatalf("Failed to store aggregates: %v", err)
+			}
+			if err := writeAggregatesMarker(g); err != nil {
+				log.Fatalf("Failed to record aggregates: %v", err)
+			}
+			log.Printf("Stored aggregates in %s", time.Since(startWrite))
+		}
+
+		printTopAggregates(g, agg, materializeTop)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(materializeCmd)
+	addGraphFlags(materializeCmd)
+	addOutputFlag(materializeCmd)
+	materializeCmd.Flags().BoolVar(&materializeStatus, "status", false, "only show when the aggregates were computed and whether they are stale")
+	materializeCmd.Flags().BoolVar(&materializeDryRun, "dry-run", false, "compute and print the aggregates without storing them")
+	materializeCmd.Flags().IntVar(&materializeTop, "top", 10, "categories with the most descendants to print")
+}
+
+// writeAggregatesMarker upserts the freshness record. It must run after the
+// aggregates are stored, since storing them changes the record versions.
+func writeAggregatesMarker(g *graph.Graph) error {
+	if err := createClassIfNotExists(metaClass, ""); err != nil {
+		return err
+	}
+	versions, err := vertexVersions()
+	if err != nil {
+		return err
+	}
+	return executeSQLCommand(fmt.Sprintf(
+		"UPDATE `%s` SET name = \"%s\", computedAt = \"%s\", vertices = %d, edges = %d, versions = %d, stale = false UPSERT WHERE name = \"%s\"",
+		metaClass, aggregatesMarker, time.Now().UTC().Format(time.RFC3339), g.NumVertices(), g.NumEdges(), versions, aggregatesMarker))
+}
+
+// markAggregatesStale flags the stored aggregates as out of date. Errors are
+// ignored: without a Meta class there is nothing to invalidate.
+func markAggregatesStale() {
+	_ = executeSQLCommand(fmt.Sprintf("UPDATE `%s` SET stale = true WHERE name = \"%s\"", metaClass, aggregatesMarker))
+}
+
+// vertexVersions returns the sum of the Vertex record versions, which grows
+// with every write to a vertex or its edges
+func vertexVersions() (int64, error) {
+	result, err := utils.ExecuteQuery("SELECT sum(@version) FROM `Vertex`")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read vertex versions: %w", err)
+	}
+	var versions float64
+	if len(result.Result) > 0 {
+		versions, _ = result.Result[0]["sum(@version)"].(float64)
+	}
+	return int64(versions), nil
+}
+
+// printAggregatesStatus prints the freshness record. The aggregates are
+// stale if marked so or if a vertex was written since they were computed.
+func printAggregatesStatus() {
+	result, err := utils.ExecuteQuery(fmt.Sprintf("SELECT name, computedAt, vertices, edges, versions, stale FROM `%s` WHERE name = \"%s\"", metaClass, aggregatesMarker))
+	if err != nil || len(result.Result) == 0 {
+		log.Fatalf("Aggregates have not been materialized")
+	}
+	versions, err := vertexVersions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	marker := result.Result[0]
+	if stored, _ := marker["versions"].(float64); int64(stored) != versions {
+		marker["stale"] = true
+	}
+	if err := utils.WriteResultSet(os.Stdout, outputFormat, result, []string{"computedAt", "vertices", "edges", "stale"}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printTopAggregates prints the categories with the most descendants
+func printTopAggregates(g *graph.Graph, agg graph.Aggregates, top int) {
+	ranked := make([]int32, g.NumVertices())
+	for v := range ranked {
+		ranked[v] = int32(v)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return agg.Descendants[ranked[i]] > agg.Descendants[ranked[j]]
+	})
+	if len(ranked) > top {
+		ranked = ranked[:top]
+	}
+
+	result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(ranked))}
+	for _, v := range ranked {
+		result.Result = append(result.Result, map[string]interface{}{
+			"name":                       g.Name(v),
+			levelProperty:                agg.Level[v],
+			descendantsProperty:          agg.Descendants[v],
+			descendantPopularityProperty: agg.DescendantPopularity[v],
+		})
+	}
+	columns := []string{"name", levelProperty, descendantsProperty, descendantPopularityProperty}
+	if err := utils.WriteResultSet(os.Stdout, outputFormat, result, columns); err != nil {
+		log.Fatal(err)
+	}
+}
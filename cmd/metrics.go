@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"dbcli/metrics"
+	"dbcli/utils"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	metricsOutPath      string
+	metricsTextfilePath string
+)
+
+// batchDurationBuckets are the bucket bounds of the batch latency histogram
+// in seconds
+var batchDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// heapSampleInterval is how often the peak heap is sampled
+const heapSampleInterval = 100 * time.Millisecond
+
+// addMetricsFlags registers the flags that export the metrics of a run
+func addMetricsFlags(c *cobra.Command) {
+	c.Flags().StringVar(&metricsOutPath, "metrics-out", "", "write the metrics of the run as JSON to this file")
+	c.Flags().StringVar(&metricsTextfilePath, "metrics-textfile", "", "write the metrics of the run in Prometheus text format to this file (*.prom for the node exporter)")
+}
+
+// startMetrics starts sampling the heap for command. The returned function
+// records the totals of the run and writes the metrics files; it is called
+// on failure too, so a failed run is visible in monitoring.
+func startMetrics(command string) func(success bool) {
+	peakHeap := metrics.Default.Gauge("dbcli_peak_heap_bytes", "Largest heap size seen during the run.", "command", command)
+	stopHeap := metrics.SampleHeap(peakHeap, heapSampleInterval)
+	started := time.Now()
+
+	return func(success bool) {
+		stopHeap()
+		transfer := utils.Transfer()
+		labels := []string{"command", command}
+		metrics.Default.Counter("dbcli_http_requests_total", "Requests sent to OrientDB.", labels...).Add(float64(transfer.Requests))
+		metrics.Default.Counter("dbcli_http_sent_bytes_total", "Request bytes sent to OrientDB.", labels...).Add(float64(transfer.BytesSent))
+		metrics.Default.Counter("dbcli_http_received_bytes_total", "Response bytes received from OrientDB.", labels...).Add(float64(transfer.BytesReceived))
+		metrics.Default.Counter("dbcli_http_server_seconds_total", "Time spent waiting for OrientDB to respond.", labels...).Add(transfer.ServerTime.Seconds())
+		metrics.Default.Gauge("dbcli_run_duration_seconds", "Wall-clock time of the run.", labels...).Set(time.Since(started).Seconds())
+		metrics.Default.Gauge("dbcli_run_timestamp_seconds", "Unix time the run finished.", labels...).Set(float64(time.Now().Unix()))
+		successValue := 0.0
+		if success {
+			successValue = 1
+		}
+		metrics.Default.Gauge("dbcli_run_success", "1 if the run succeeded, 0 if it failed.", labels...).Set(successValue)
+
+		if metricsOutPath != "" {
+			if err := metrics.WriteFile(metricsOutPath, metrics.Default.WriteJSON); err != nil {
+				log.Printf("Warning: could not write metrics: %v", err)
+			}
+		}
+		if metricsTextfilePath != "" {
+			if err := metrics.WriteFile(metricsTextfilePath, metrics.Default.WriteText); err != nil {
+				log.Printf("Warning: could not write metrics: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"dbcli/importer"
+	"dbcli/orientdbtest"
+	"dbcli/utils"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newOrientServer starts an OrientDB stand-in and points dbcli at it
+func newOrientServer(t testing.TB) *orientdbtest.Server {
+	t.Helper()
+	srv := orientdbtest.NewServer()
+	t.Cleanup(srv.Close)
+	savedConnection, savedDelay, savedCacheDir := utils.OrientDB, batchRetryDelay, cacheDir
+	utils.OrientDB = srv.Connection()
+	batchRetryDelay = 0
+	// writes invalidate the caches in cacheDir
+	cacheDir = t.TempDir()
+	t.Cleanup(func() {
+		utils.OrientDB, batchRetryDelay, cacheDir = savedConnection, savedDelay, savedCacheDir
+	})
+	return srv
+}
+
+// writeFixtureFiles writes the fixture graph as import files and returns
+// their directory
+func writeFixtureFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	names := make([]string, 0, len(fixturePopularity))
+	for name := range fixturePopularity {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	popularity := make([]int64, len(names))
+	for i, name := range names {
+		popularity[i] = int64(fixturePopularity[name])
+	}
+	if err := importer.WritePopularity(filepath.Join(dir, "popularity_iw.csv"), names, popularity); err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.WriteEdges(filepath.Join(dir, "taxonomy_iw.csv"), fixtureEdges); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// checkImported fails unless the server holds exactly the fixture graph
+func checkImported(t *testing.T, srv *orientdbtest.Server) {
+	t.Helper()
+	vertices := srv.Vertices()
+	if len(vertices) != len(fixturePopularity) {
+		t.Errorf("%d vertices imported, want %d", len(vertices), len(fixturePopularity))
+	}
+	for name, popularity := range fixturePopularity {
+		if vertices[name] != int64(popularity) {
+			t.Errorf("popularity of %s = %d, want %d", name, vertices[name], popularity)
+		}
+	}
+	want := slices.Clone(fixtureEdges)
+	slices.SortFunc(want, func(a, b [2]string) int { return strings.Compare(a[0]+"\x00"+a[1], b[0]+"\x00"+b[1]) })
+	if got := srv.Edges(); !slices.Equal(got, want) {
+		t.Errorf("edges = %v, want %v", got, want)
+	}
+}
+
+func TestImport(t *testing.T) {
+	srv := newOrientServer(t)
+	phases, err := runImport(writeFixtureFiles(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImported(t, srv)
+	if last := phases[len(phases)-1]; last.Name != "Insert edges" || last.Records != len(fixtureEdges) {
+		t.Errorf("last phase = %+v, want %d edges inserted", last, len(fixtureEdges))
+	}
+
+	// names are unique, so importing again fails instead of duplicating
+	if _, err := runImport(writeFixtureFiles(t)); err == nil || !strings.Contains(err.Error(), "ORecordDuplicatedException") {
+		t.Errorf("second import: %v, want a duplicate key error", err)
+	}
+}
+
+func TestImportRetriesTransientFailures(t *testing.T) {
+	srv := newOrientServer(t)
+	srv.Inject(orientdbtest.Fault{Path: "/batch/", ConcurrentModification: true, Times: batchAttempts - 1})
+	if _, err := runImport(writeFixtureFiles(t)); err != nil {
+		t.Fatal(err)
+	}
+	checkImported(t, srv)
+}
+
+func TestImportGivesUp(t *testing.T) {
+	srv := newOrientServer(t)
+	srv.Inject(orientdbtest.Fault{Path: "/batch/", ConcurrentModification: true})
+	_, err := runImport(writeFixtureFiles(t))
+	if err == nil || !strings.Contains(err.Error(), "status: 409") {
+		t.Fatalf("import: %v, want status 409", err)
+	}
+	// every batch was sent batchAttempts times
+	if n := srv.Requests("/batch/"); n == 0 || n%batchAttempts != 0 {
+		t.Errorf("%d batch requests, want a multiple of %d", n, batchAttempts)
+	}
+	if vertices := srv.Vertices(); len(vertices) != 0 {
+		t.Errorf("%d vertices stored by failed batches", len(vertices))
+	}
+}
+
+func TestImportDoesNotRetryServerErrors(t *testing.T) {
+	srv := newOrientServer(t)
+	// the batch may have committed before the server failed, so a retry
+	// could insert it twice
+	srv.Inject(orientdbtest.Fault{Path: "/batch/", Status: http.StatusInternalServerError, Times: 1})
+	if _, err := runImport(writeFixtureFiles(t)); err == nil || !strings.Contains(err.Error(), "status: 500") {
+		t.Fatalf("import: %v, want status 500", err)
+	}
+	// the fault fails one request, so a retry would have let the import
+	// succeed; each worker sends its batch at most once
+	if n := srv.Requests("/batch/"); n > workers {
+		t.Errorf("%d batch requests, want at most %d", n, workers)
+	}
+}
+
+func TestBenchmarkImportIterations(t *testing.T) {
+	srv := newOrientServer(t)
+	results, err := benchmarkImport(writeFixtureFiles(t), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImported(t, srv)
+	for _, result := range results {
+		if len(result.SamplesMs) != 2 {
+			t.Errorf("%s: %d samples, want 2", result.Name, len(result.SamplesMs))
+		}
+	}
+}
+
+// seedFixture stores the fixture graph on the server
+func seedFixture(t testing.TB, srv *orientdbtest.Server) {
+	t.Helper()
+	for name, popularity := range fixturePopularity {
+		if err := srv.AddVertex(name, popularity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range fixtureEdges {
+		if err := srv.AddEdge(e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// canonical renders the rows of a result the same for both engines: record
+// metadata is dropped, numbers are formatted alike and rows are sorted.
+// Task 18 search statistics depend on the searched graph and are dropped.
+func canonical(t *testing.T, result utils.ResultSet) []string {
+	t.Helper()
+	var rows []string
+	for _, row := range result.Result {
+		fields := make(map[string]interface{}, len(row))
+		for key, value := range row {
+			if !strings.HasPrefix(key, "@") && key != "paths" && key != "expanded" {
+				fields[key] = value
+			}
+		}
+		data, err := json.Marshal(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, string(data))
+	}
+	slices.Sort(rows)
+	return rows
+}
+
+// engineComparisonTasks are the read tasks both engines must answer alike
+var engineComparisonTasks = [][]string{
+	{"1", "root"}, {"1", "missing"},
+	{"2", "a"}, {"2", "missing"},
+	{"3", "root"},
+	{"4", "c"},
+	{"5", "c"},
+	{"6", "root"},
+	{"7"}, {"8"}, {"9"}, {"10"}, {"11"},
+	{"14", "root", "target", "3"}, {"14", "root", "c", "3"}, {"14", "target", "target", "3"},
+	{"15", "root", "missing", "2"},
+	{"16", "a", "1", "2"}, {"16", "root", "3", "2"}, {"16", "root", "3", "3"},
+	{"17", "root", "e", "5"}, {"17", "c", "e", "5"},
+	{"18", "root", "e", "4"}, {"18", "root", "orphan", "4"},
+}
+
+func TestOrientTasksMatchMemoryEngine(t *testing.T) {
+	srv := newOrientServer(t)
+	seedFixture(t, srv)
+	memory := newFixtureEngine()
+	for _, args := range engineComparisonTasks {
+		want, err := runTask(memory, args[0], args[1:])
+		if err != nil {
+			t.Fatalf("memory task %v: %v", args, err)
+		}
+		got, err := runTask(orientEngine{}, args[0], args[1:])
+		if err != nil {
+			t.Errorf("orientdb task %v: %v", args, err)
+			continue
+		}
+		if g, w := canonical(t, got), canonical(t, want); !slices.Equal(g, w) {
+			t.Errorf("task %v = %v, want %v", args, g, w)
+		}
+	}
+}
+
+func TestOrientWriteTasks(t *testing.T) {
+	srv := newOrientServer(t)
+	seedFixture(t, srv)
+	engine := orientEngine{}
+
+	if result, err := engine.task12("b", "bee"); err != nil || result.Result[0]["count"] != 1.0 {
+		t.Fatalf("task12 = %v, %v, want one record updated", result, err)
+	}
+	if _, err := engine.task12("a", "bee"); err == nil {
+		t.Error("task12 renamed onto an existing name")
+	}
+	if result, err := engine.task13("bee", 42); err != nil || result.Result[0]["count"] != 1.0 {
+		t.Fatalf("task13 = %v, %v, want one record updated", result, err)
+	}
+	vertices := srv.Vertices()
+	if _, ok := vertices["b"]; ok || vertices["bee"] != 42 {
+		t.Errorf("vertices = %v, want b renamed to bee with popularity 42", vertices)
+	}
+	if result, err := engine.task1("bee"); err != nil || len(result.Result) != 1 || result.Result[0]["name"] != "c" {
+		t.Errorf("children of bee = %v, %v, want c", result, err)
+	}
+}
+
+func TestOrientQuotesNames(t *testing.T) {
+	srv := newOrientServer(t)
+	seedFixture(t, srv)
+	engine := orientEngine{}
+
+	// quotes and backslashes are escaped, so the name cannot end the literal
+	name := `it's "quoted" \ here`
+	if result, err := engine.task12("b", name); err != nil || result.Result[0]["count"] != 1.0 {
+		t.Fatalf("task12 = %v, %v, want one record updated", result, err)
+	}
+	if _, ok := srv.Vertices()[name]; !ok {
+		t.Fatalf("vertices = %v, want %s", srv.Vertices(), name)
+	}
+	if result, err := engine.task1(name); err != nil || len(result.Result) != 1 || result.Result[0]["name"] != "c" {
+		t.Errorf("children of %s = %v, %v, want c", name, result, err)
+	}
+	if result, err := engine.task1(`x" OR name <> "`); err != nil || len(result.Result) != 0 {
+		t.Errorf("children of an injected condition = %v, %v, want none", result, err)
+	}
+}
+
+// BenchmarkEngines times the read tasks on both engines over the fixture.
+// Against the stand-in server this measures query building and REST round
+// trips; "dbcli bench --engine" times a real database.
+func BenchmarkEngines(b *testing.B) {
+	srv := newOrientServer(b)
+	seedFixture(b, srv)
+	engines := []struct {
+		name   string
+		engine taskEngine
+	}{
+		{engineMemory, newFixtureEngine()},
+		{engineOrientDB, orientEngine{}},
+	}
+	for _, args := range engineComparisonTasks {
+		for _, e := range engines {
+			b.Run(e.name+"/task"+strings.Join(args, "_"), func(b *testing.B) {
+				for range b.N {
+					if _, err := runTask(e.engine, args[0], args[1:]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestAggregatesMarkerSeesWrites(t *testing.T) {
+	srv := newOrientServer(t)
+	seedFixture(t, srv)
+	if err := writeAggregatesMarker(newFixtureEngine().g); err != nil {
+		t.Fatal(err)
+	}
+	stored := func() int64 {
+		result, err := utils.ExecuteQuery("SELECT versions FROM `Meta` WHERE name = \"aggregates\"")
+		if err != nil || len(result.Result) != 1 {
+			t.Fatalf("marker = %v, %v", result, err)
+		}
+		versions, _ := result.Result[0]["versions"].(float64)
+		return int64(versions)
+	}()
+	if versions, err := vertexVersions(); err != nil || versions != stored {
+		t.Fatalf("vertex versions = %d, %v, want the stored %d", versions, err, stored)
+	}
+
+	if _, err := (orientEngine{}).task13("c", 9); err != nil {
+		t.Fatal(err)
+	}
+	if versions, err := vertexVersions(); err != nil || versions == stored {
+		t.Errorf("vertex versions = %d, %v after task13, want a change from %d", versions, err, stored)
+	}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pathWeight     string
+	pathUndirected bool
+	pathMaxDepth   int
+	pathK          int
+	outputFormat   string
+)
+
+// pathCmd groups the weighted path searches
+var pathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Popularity-weighted path searches between two categories",
+	Long: `Path searches where every edge costs a weight derived from the popularity of the vertex it enters.
+
+--weight accepts a preset (` + strings.Join(graph.WeightPresets(), ", ") + `) or an expression over p,
+the popularity of the entered vertex, e.g. "1/(1+p)" to favor popular categories or "1+log(1+p)" to avoid them.`,
+}
+
+// pathShortestCmd finds the cheapest path
+var pathShortestCmd = &cobra.Command{
+	Use:   "shortest [sourceName] [targetName]",
+	Short: "Find the cheapest path with Dijkstra's algorithm",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runPathSearch(args[0], args[1], 1)
+	},
+}
+
+// pathKShortestCmd enumerates the k cheapest loopless paths
+var pathKShortestCmd = &cobra.Command{
+	Use:   "k-shortest [sourceName] [targetName]",
+	Short: "Find the k cheapest loopless paths with Yen's algorithm",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runPathSearch(args[0], args[1], pathK)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pathCmd)
+	pathCmd.AddCommand(pathShortestCmd)
+	pathCmd.AddCommand(pathKShortestCmd)
+
+	for _, c := range []*cobra.Command{pathShortestCmd, pathKShortestCmd} {
+		addGraphFlags(c)
+		addOutputFlag(c)
+		c.Flags().StringVar(&pathWeight, "weight", "inverse", "weight preset or expression over p (popularity)")
+		c.Flags().BoolVar(&pathUndirected, "undirected", false, "ignore edge direction")
+		c.Flags().IntVar(&pathMaxDepth, "max-depth", 0, "maximum number of edges on a path, 0 for no limit (required with the orientdb engine)")
+	}
+	pathKShortestCmd.Flags().IntVarP(&pathK, "k", "k", 3, "number of paths to find")
+}
+
+// addOutputFlag registers --output for commands that print result tables
+func addOutputFlag(c *cobra.Command) {
+	c.Flags().StringVarP(&outputFormat, "output", "O", utils.FormatTable, "output format: table, json or markdown")
+}
+
+// loadPathGraph loads the graph a path search runs on. With the orientdb
+// engine only the neighborhood of the source within --max-depth is fetched.
+func loadPathGraph(sourceName string, dir graph.Direction) (*graph.Graph, error) {
+	if engineName != engineOrientDB {
+		return loadGraph()
+	}
+	if pathMaxDepth <= 0 {
+		return nil, fmt.Errorf("--max-depth is required with the %s engine", engineOrientDB)
+	}
+	return fetchSubgraph(sourceName, dir, pathMaxDepth)
+}
+
+// runPathSearch finds up to k paths and prints them
+func runPathSearch(sourceName, targetName string, k int) {
+	weight, err := graph.ParseWeight(pathWeight)
+	if err != nil {
+		log.Fatal(err)
+	}
+	query := graph.PathQuery{Dir: graph.Out, MaxDepth: pathMaxDepth, Weight: weight}
+	if pathUndirected {
+		query.Dir = graph.Both
+	}
+
+	g, err := loadPathGraph(sourceName, query.Dir)
+	if err != nil {
+		log.Fatalf("Failed to load graph: %v", err)
+	}
+	src, ok := g.Lookup(sourceName)
+	if !ok {
+		log.Fatalf("Vertex %q not found", sourceName)
+	}
+	dst, ok := g.Lookup(targetName)
+	if !ok {
+		log.Fatalf("Vertex %q not found", targetName)
+	}
+
+	var paths []graph.WeightedPath
+	if k == 1 {
+		path, found, err := g.CheapestPath(src, dst, query)
+		if err != nil {
+			log.Fatalf("Path search failed: %v", err)
+		}
+		if found {
+			paths = append(paths, path)
+		}
+	} else if paths, err = g.KShortestPaths(src, dst, k, query); err != nil {
+		log.Fatalf("Path search failed: %v", err)
+	}
+
+	if len(paths) == 0 {
+		log.Printf("No path from %s to %s", sourceName, targetName)
+	}
+	if err := utils.WriteResultSet(os.Stdout, outputFormat, pathsResult(g, paths), []string{"rank", "hops", "cost", "popularity", "path"}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pathsResult converts weighted paths to result rows
+func pathsResult(g *graph.Graph, paths []graph.WeightedPath) utils.ResultSet {
+	result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(paths))}
+	for i, path := range paths {
+		names := make(utils.Path, len(path.Vertices))
+		for j, v := range path.Vertices {
+			names[j] = g.Name(v)
+		}
+		result.Result = append(result.Result, map[string]interface{}{
+			"rank":       i + 1,
+			"hops":       len(path.Vertices) - 1,
+			"cost":       path.Cost,
+			"popularity": path.Popularity,
+			"path":       names,
+		})
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"dbcli/metrics"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	sampleInterval   time.Duration
+	samplePIDs       []int
+	resourcesOutPath string
+	cpuProfilePath   string
+	memProfilePath   string
+)
+
+// resourceSeries is a sampled process with its peaks and totals
+type resourceSeries struct {
+	metrics.ResourceSeries
+	Summary metrics.Summary `json:"summary"`
+}
+
+// addResourceFlags registers the resource sampling and profiling flags
+func addResourceFlags(c *cobra.Command) {
+	c.Flags().DurationVar(&sampleInterval, "sample-interval", 200*time.Millisecond, "how often to sample CPU, memory, goroutines and GC, 0 to turn sampling off")
+	c.Flags().IntSliceVar(&samplePIDs, "sample-pid", nil, "also sample this process from /proc, e.g. the OrientDB server (repeatable)")
+	c.Flags().StringVar(&resourcesOutPath, "resources-out", "", "write the resource samples as a JSON time series to this file")
+	c.Flags().StringVar(&cpuProfilePath, "cpuprofile", "", "write a pprof CPU profile of the run to this file")
+	c.Flags().StringVar(&memProfilePath, "memprofile", "", "write a pprof heap profile at the end of the run to this file")
+}
+
+// startResources starts the profiles and the resource sampler selected by
+// the flags. The returned function stops them, logs a summary, writes the
+// files and returns the sampled series.
+func startResources() func() []resourceSeries {
+	var cpuProfile *os.File
+	if cpuProfilePath != "" {
+		file, err := os.Create(cpuProfilePath)
+		if err != nil {
+			log.Fatalf("Failed to create CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			log.Fatalf("Failed to start CPU profile: %v", err)
+		}
+		cpuProfile = file
+	}
+	var sampler *metrics.ResourceSampler
+	if sampleInterval > 0 {
+		sampler = metrics.StartResourceSampler(sampleInterval, samplePIDs...)
+	}
+
+	return func() []resourceSeries {
+		if cpuProfile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuProfile.Close(); err != nil {
+				log.Printf("Warning: could not write CPU profile: %v", err)
+			}
+		}
+		if memProfilePath != "" {
+			if err := writeHeapProfile(memProfilePath); err != nil {
+				log.Printf("Warning: could not write heap profile: %v", err)
+			}
+		}
+		if sampler == nil {
+			return nil
+		}
+
+		var series []resourceSeries
+		for _, s := range sampler.Stop() {
+			one := resourceSeries{ResourceSeries: s, Summary: s.Summarize()}
+			series = append(series, one)
+			if s.Error != "" {
+				log.Printf("Warning: could not sample process %d: %s", s.PID, s.Error)
+				continue
+			}
+			log.Printf("Resources of %s: %s", processLabel(one.PID, one.Self), formatResourceSummary(one.Summary))
+		}
+		if resourcesOutPath != "" {
+			report := struct {
+				Interval string           `json:"interval"`
+				Series   []resourceSeries `json:"series"`
+			}{sampleInterval.String(), series}
+			if err := writeJSONFile(resourcesOutPath, report); err != nil {
+				log.Printf("Warning: could not write resource samples: %v", err)
+			}
+		}
+		return series
+	}
+}
+
+// writeHeapProfile writes a heap profile after a garbage collection, so it
+// shows live memory only
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// processLabel names a sampled process
+func processLabel(pid int, self bool) string {
+	if self {
+		return fmt.Sprintf("dbcli (pid %d)", pid)
+	}
+	return fmt.Sprintf("pid %d", pid)
+}
+
+// formatResourceSummary renders a summary on one line
+func formatResourceSummary(summary metrics.Summary) string {
+	line := fmt.Sprintf("CPU %.2fs (peak %.0f%%), peak RSS %s", summary.CPUSec, summary.PeakCPUPercent, formatBytes(float64(summary.PeakRSSBytes)))
+	if summary.PeakHeapBytes > 0 {
+		line += fmt.Sprintf(", peak heap %s, %d goroutines, %d GC cycles pausing %s (max %s)",
+			formatBytes(float64(summary.PeakHeapBytes)), summary.PeakGoroutines, summary.GCCycles,
+			formatMillis(summary.GCPauseSec*1000), formatMillis(summary.GCMaxPauseSec*1000))
+	}
+	return line
+}
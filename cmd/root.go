@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"dbcli/utils"
 	"fmt"
 	"os"
 
@@ -21,3 +22,19 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&utils.OrientDB.BaseURL, "orientdb-url", envOr("DBCLI_ORIENTDB_URL", utils.OrientDB.BaseURL), "OrientDB REST endpoint ($DBCLI_ORIENTDB_URL)")
+	flags.StringVar(&utils.OrientDB.Database, "orientdb-database", envOr("DBCLI_ORIENTDB_DATABASE", utils.OrientDB.Database), "OrientDB database ($DBCLI_ORIENTDB_DATABASE)")
+	flags.StringVar(&utils.OrientDB.Username, "orientdb-user", envOr("DBCLI_ORIENTDB_USER", utils.OrientDB.Username), "OrientDB user ($DBCLI_ORIENTDB_USER)")
+	flags.StringVar(&utils.OrientDB.Password, "orientdb-password", envOr("DBCLI_ORIENTDB_PASSWORD", utils.OrientDB.Password), "OrientDB password ($DBCLI_ORIENTDB_PASSWORD)")
+}
+
+// envOr returns the environment variable key, or fallback when it is unset
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// nameSearchIndex is the Lucene full-text index on Vertex.name
+const nameSearchIndex = "Vertex.name_search"
+
+// luceneCandidates caps how many index hits are re-ranked locally
+const luceneCandidates = 1000
+
+var (
+	searchMode          string
+	searchCaseSensitive bool
+	searchMaxDistance   int
+	searchLimit         int
+)
+
+// searchCmd finds categories whose names resemble the given text
+var searchCmd = &cobra.Command{
+	Use:   "search [text]",
+	Short: "Find categories by prefix, substring or approximate name",
+	Long: `Find categories by prefix, substring or approximate (edit distance) name.
+Spaces in the text are treated as underscores. Matches are ranked by quality
+(exact, prefix, substring, fuzzy) and then by popularity.
+
+With the orientdb engine the full-text index created by import narrows the
+candidates; without it every name is fetched and matched locally.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := graph.SearchOptions{
+			Mode:          searchMode,
+			CaseSensitive: searchCaseSensitive,
+			MaxDistance:   searchMaxDistance,
+			Limit:         searchLimit,
+		}
+		text := strings.Join(args, " ")
+
+		var g *graph.Graph
+		var err error
+		if engineName == engineOrientDB {
+			g, err = fetchSearchCandidates(text, opts)
+		} else {
+			g, err = loadGraph()
+		}
+		if err != nil {
+			log.Fatalf("Failed to load names: %v", err)
+		}
+
+		matches, err := g.Search(text, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(matches) == 0 {
+			log.Printf("No category matches %q", text)
+		}
+
+		result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(matches))}
+		for _, match := range matches {
+			result.Result = append(result.Result, map[string]interface{}{
+				"name":       g.Name(match.Vertex),
+				"popularity": g.Popularity(match.Vertex),
+				"match":      match.Kind,
+				"distance":   match.Distance,
+			})
+		}
+		if err := utils.WriteResultSet(os.Stdout, outputFormat, result, []string{"name", "popularity", "match", "distance"}); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	addGraphFlags(searchCmd)
+	addOutputFlag(searchCmd)
+	searchCmd.Flags().StringVar(&searchMode, "mode", graph.SearchAuto, "auto, prefix, substring or fuzzy")
+	searchCmd.Flags().BoolVar(&searchCaseSensitive, "case-sensitive", false, "match capitalization exactly")
+	searchCmd.Flags().IntVar(&searchMaxDistance, "max-distance", 2, "largest edit distance for fuzzy matches")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "maximum number of matches, 0 for all")
+}
+
+// fetchSearchCandidates loads the names that may match text from OrientDB.
+// It asks the full-text index first and falls back to fetching every name
+// when the index is missing or rejects the query.
+func fetchSearchCandidates(text string, opts graph.SearchOptions) (*graph.Graph, error) {
+	query := fmt.Sprintf(
+		"SELECT name, popularity FROM `Vertex` WHERE SEARCH_INDEX(\"%s\", \"%s\", {\"allowLeadingWildcard\": true}) = true LIMIT %d",
+		nameSearchIndex, escapeSQLString(luceneQuery(text, opts)), luceneCandidates)
+	result, err := utils.ExecuteQuery(query)
+	if err == nil {
+		return graphFromRecords(result.Result), nil
+	}
+	log.Printf("Warning: full-text search failed, matching all names locally: %v", err)
+
+	result, err = utils.ExecuteQuery("SELECT name, popularity FROM `Vertex` LIMIT -1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch names: %w", err)
+	}
+	return graphFromRecords(result.Result), nil
+}
+
+// luceneQuery translates a search into Lucene query syntax. The index
+// lowercases terms, so capitalization is checked when re-ranking locally.
+func luceneQuery(text string, opts graph.SearchOptions) string {
+	term := luceneEscape(strings.ToLower(graph.NormalizeSearch(text)))
+	// Lucene only supports edit distances up to 2
+	distance := min(max(opts.MaxDistance, 0), 2)
+	switch opts.Mode {
+	case graph.SearchPrefix:
+		return term + "*"
+	case graph.SearchSubstring:
+		return "*" + term + "*"
+	case graph.SearchFuzzy:
+		return fmt.Sprintf("%s~%d", term, distance)
+	default:
+		return fmt.Sprintf("*%s* OR %s~%d", term, term, distance)
+	}
+}
+
+// luceneEscape backslash-escapes the characters Lucene treats as operators
+func luceneEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`+-&|!(){}[]^"~*?:\/`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeSQLString escapes s for use inside a double-quoted SQL string
+func escapeSQLString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// quoteSQLString returns s as a double-quoted SQL string literal
+func quoteSQLString(s string) string {
+	return `"` + escapeSQLString(s) + `"`
+}
+
+// suggestNames returns up to limit names close to name from g, never nil
+func suggestNames(g *graph.Graph, name string, limit int) []string {
+	matches, err := g.Search(name, graph.SearchOptions{Mode: graph.SearchAuto, MaxDistance: 2, Limit: limit})
+	if err != nil {
+		return []string{}
+	}
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = g.Name(match.Vertex)
+	}
+	return names
+}
@@ -0,0 +1,499 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"dbcli/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen          string
+	serveToken           string
+	serveShutdownTimeout time.Duration
+	serveJobsFile        string
+	serveImportRoot      string
+	serveJobWorkers      int
+	serveJobQueue        int
+	serveJobHistory      int
+)
+
+// serveCmd exposes the tasks over HTTP
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the tasks as a JSON HTTP API",
+	Long: `Serve every task as a JSON endpoint. Responses have the same shape as the
+OrientDB REST API ({"result": [...]}); errors are {"error": "..."} with a 4xx
+or 5xx status. A name that matches no vertex is reported with 404.
+
+The server listens on localhost only by default. With --token every request
+but /healthz needs the header "Authorization: Bearer <token>"; set one
+before listening on other addresses, since the API changes data.
+
+  GET  /healthz
+  GET  /v1/cache                                         result cache statistics
+  DELETE /v1/cache                                       drop all cached results
+  GET  /v1/categories/count                              task 7
+  GET  /v1/categories/roots                              task 8
+  GET  /v1/categories/roots/count                        task 9
+  GET  /v1/categories/most-children                      task 10
+  GET  /v1/categories/fewest-children                    task 11
+  GET  /v1/categories/{name}/children                    task 1
+  GET  /v1/categories/{name}/children/count              task 2
+  GET  /v1/categories/{name}/grandchildren               task 3
+  GET  /v1/categories/{name}/parents                     task 4
+  GET  /v1/categories/{name}/parents/count               task 5
+  GET  /v1/categories/{name}/grandparents                task 6
+  PUT  /v1/categories/{name}/name        {"name": ""}    task 12
+  PUT  /v1/categories/{name}/popularity  {"popularity": 0}  task 13
+  GET  /v1/categories/{name}/reachable?avoid=&maxDepth=        task 14
+  GET  /v1/categories/{name}/reachable/count?avoid=&maxDepth=  task 15
+  GET  /v1/categories/{name}/neighborhood/popularity?radius=&depth=  task 16
+  GET  /v1/paths/shortest?from=&to=&maxDepth=            task 17
+  GET  /v1/paths/most-popular?from=&to=&maxDepth=        task 18
+
+Imports, deep traversals and PageRank can run as jobs on a pool of
+--job-workers. A job is queued with POST /v1/jobs and one of
+
+  {"kind": "import", "dataDir": "subdirectory"}
+  {"kind": "task", "task": "18", "args": ["A", "B", "10"]}
+  {"kind": "pagerank", "direction": "up", "damping": 0.85, "tolerance": 1e-9,
+   "maxIterations": 100, "top": 20}
+
+which returns the job with its id. An import reads dataDir within
+--import-root, or the root itself without one, and only one import is
+queued or running at a time. GET /v1/jobs/{id} returns its status
+(queued, running, canceling, succeeded, failed or canceled), progress and
+result, GET /v1/jobs lists all jobs without results and DELETE /v1/jobs/{id}
+cancels a job. A running job is canceling until its work stops: imports stop
+between phases and PageRank between iterations; a task cannot be interrupted,
+so a canceled task finishes in the background and its result is dropped. Jobs are kept in --jobs-file and their results in the
+directory next to it with ".results" appended, so finished results and queued
+jobs survive restarts.
+
+SIGINT or SIGTERM stop accepting connections and wait up to
+--shutdown-timeout for requests in flight.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		engine, err := newTaskEngine()
+		if err != nil {
+			log.Fatalf("Failed to initialize %s engine: %v", engineName, err)
+		}
+		api := newAPIServer(engine)
+		jobs, err := newJobManager(serveJobsFile, serveJobWorkers, serveJobQueue, serveJobHistory, api.runJob)
+		if err != nil {
+			log.Fatalf("Failed to load jobs: %v", err)
+		}
+		api.handleJobs(jobs)
+		var handler http.Handler = api
+		if serveToken != "" {
+			handler = requireToken(serveToken, handler)
+		}
+		server := &http.Server{
+			Addr:              serveListen,
+			Handler:           logRequests(handler),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- server.ListenAndServe()
+		}()
+		log.Printf("Serving %s engine on %s", engineName, serveListen)
+
+		select {
+		case err := <-serveErr:
+			log.Fatalf("Failed to serve: %v", err)
+		case <-ctx.Done():
+		}
+		log.Printf("Shutting down, waiting up to %s for requests and jobs in flight", serveShutdownTimeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: shutdown: %v", err)
+		}
+		if err := jobs.close(shutdownCtx); err != nil {
+			log.Printf("Warning: shutdown: %v", err)
+		}
+		log.Printf("Server stopped")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	addGraphFlags(serveCmd)
+	addCacheFlags(serveCmd)
+	serveCmd.Flags().StringVar(&serveListen, "listen", "localhost:8080", "address to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", envOr("DBCLI_SERVE_TOKEN", ""), "bearer token required by every request but /healthz, empty for none ($DBCLI_SERVE_TOKEN)")
+	serveCmd.Flags().DurationVar(&serveShutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for requests and jobs in flight on shutdown")
+	serveCmd.Flags().DurationVar(&searchTimeout, "timeout", 30*time.Second, "time limit for path searches (task 18)")
+	serveCmd.Flags().Int64Var(&searchMaxExpanded, "max-expanded", 10_000_000, "partial paths a path search may expand before giving up, 0 for no limit (task 18)")
+	serveCmd.Flags().StringVar(&serveJobsFile, "jobs-file", "jobs.json", "file that keeps the job state across restarts, empty to keep it in memory only")
+	serveCmd.Flags().StringVar(&serveImportRoot, "import-root", "", "directory import jobs may read data from, including its subdirectories (default --data)")
+	serveCmd.Flags().IntVar(&serveJobWorkers, "job-workers", 2, "number of jobs run at the same time")
+	serveCmd.Flags().IntVar(&serveJobQueue, "job-queue", 100, "number of jobs that may wait for a worker")
+	serveCmd.Flags().IntVar(&serveJobHistory, "job-history", 1000, "number of finished jobs kept, 0 for no limit")
+}
+
+// apiError is an error reported with a specific HTTP status
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// badRequest returns a 400 error
+func badRequest(format string, args ...interface{}) error {
+	return &apiError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// notFound returns a 404 error
+func notFound(format string, args ...interface{}) error {
+	return &apiError{http.StatusNotFound, fmt.Sprintf(format, args...)}
+}
+
+// apiServer routes API requests to a task engine
+type apiServer struct {
+	*http.ServeMux
+	engine taskEngine
+	// lock serializes mutating tasks against the others on the memory
+	// engine, whose graph is not safe for concurrent writes
+	lock *sync.RWMutex
+}
+
+// newAPIServer returns the API handler for engine
+func newAPIServer(engine taskEngine) *apiServer {
+	s := &apiServer{ServeMux: http.NewServeMux(), engine: engine}
+	if _, ok := asMemoryEngine(engine); ok {
+		s.lock = &sync.RWMutex{}
+	}
+
+	s.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "engine": engineName})
+	})
+
+	byName := func(task func(name string) (utils.ResultSet, error)) http.HandlerFunc {
+		return s.read(func(r *http.Request) (utils.ResultSet, error) {
+			name := r.PathValue("name")
+			result, err := task(name)
+			return s.found(result, err, name)
+		})
+	}
+	noArgs := func(task func() (utils.ResultSet, error)) http.HandlerFunc {
+		return s.read(func(r *http.Request) (utils.ResultSet, error) {
+			return task()
+		})
+	}
+
+	s.HandleFunc("GET /v1/cache", func(w http.ResponseWriter, r *http.Request) {
+		if resultCache == nil {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"enabled": false})
+			return
+		}
+		writeJSON(w, http.StatusOK, resultCache.Stats())
+	})
+	s.HandleFunc("DELETE /v1/cache", func(w http.ResponseWriter, r *http.Request) {
+		invalidateCaches()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	s.HandleFunc("GET /v1/categories/count", noArgs(engine.task7))
+	s.HandleFunc("GET /v1/categories/roots", noArgs(engine.task8))
+	s.HandleFunc("GET /v1/categories/roots/count", noArgs(engine.task9))
+	s.HandleFunc("GET /v1/categories/most-children", noArgs(engine.task10))
+	s.HandleFunc("GET /v1/categories/fewest-children", noArgs(engine.task11))
+
+	s.HandleFunc("GET /v1/categories/{name}/children", byName(engine.task1))
+	s.HandleFunc("GET /v1/categories/{name}/children/count", byName(engine.task2))
+	s.HandleFunc("GET /v1/categories/{name}/grandchildren", byName(engine.task3))
+	s.HandleFunc("GET /v1/categories/{name}/parents", byName(engine.task4))
+	s.HandleFunc("GET /v1/categories/{name}/parents/count", byName(engine.task5))
+	s.HandleFunc("GET /v1/categories/{name}/grandparents", byName(engine.task6))
+
+	s.HandleFunc("PUT /v1/categories/{name}/name", s.write(func(r *http.Request) (utils.ResultSet, error) {
+		var body struct {
+			Name *string `json:"name"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return utils.ResultSet{}, err
+		}
+		if body.Name == nil || *body.Name == "" {
+			return utils.ResultSet{}, badRequest("name is required")
+		}
+		name := r.PathValue("name")
+		result, err := engine.task12(name, *body.Name)
+		return s.found(result, err, name)
+	}))
+	s.HandleFunc("PUT /v1/categories/{name}/popularity", s.write(func(r *http.Request) (utils.ResultSet, error) {
+		var body struct {
+			Popularity *int `json:"popularity"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return utils.ResultSet{}, err
+		}
+		if body.Popularity == nil {
+			return utils.ResultSet{}, badRequest("popularity is required")
+		}
+		name := r.PathValue("name")
+		result, err := engine.task13(name, *body.Popularity)
+		return s.found(result, err, name)
+	}))
+
+	reachable := func(task func(sourceName, targetName string, depth int) (utils.ResultSet, error)) http.HandlerFunc {
+		return s.read(func(r *http.Request) (utils.ResultSet, error) {
+			depth, err := intParam(r, "maxDepth")
+			if err != nil {
+				return utils.ResultSet{}, err
+			}
+			name := r.PathValue("name")
+			result, err := task(name, r.URL.Query().Get("avoid"), depth)
+			return s.found(result, err, name)
+		})
+	}
+	s.HandleFunc("GET /v1/categories/{name}/reachable", reachable(engine.task14))
+	s.HandleFunc("GET /v1/categories/{name}/reachable/count", reachable(engine.task15))
+
+	s.HandleFunc("GET /v1/categories/{name}/neighborhood/popularity", s.read(func(r *http.Request) (utils.ResultSet, error) {
+		radius, err := intParam(r, "radius")
+		if err != nil {
+			return utils.ResultSet{}, err
+		}
+		depth, err := intParam(r, "depth")
+		if err != nil {
+			return utils.ResultSet{}, err
+		}
+		name := r.PathValue("name")
+		result, err := engine.task16(name, radius, depth)
+		return s.found(result, err, name)
+	}))
+
+	path := func(task func(sourceName, targetName string, depth int) (utils.ResultSet, error)) http.HandlerFunc {
+		return s.read(func(r *http.Request) (utils.ResultSet, error) {
+			from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+			if from == "" || to == "" {
+				return utils.ResultSet{}, badRequest("from and to are required")
+			}
+			depth, err := intParam(r, "maxDepth")
+			if err != nil {
+				return utils.ResultSet{}, err
+			}
+			result, err := task(from, to, depth)
+			return s.found(result, err, from, to)
+		})
+	}
+	s.HandleFunc("GET /v1/paths/shortest", path(engine.task17))
+	s.HandleFunc("GET /v1/paths/most-popular", path(engine.task18))
+	return s
+}
+
+// handleJobs adds the job endpoints
+func (s *apiServer) handleJobs(jobs *jobManager) {
+	s.HandleFunc("POST /v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var spec jobSpec
+		if err := decodeBody(r, &spec); err != nil {
+			writeError(w, err)
+			return
+		}
+		j, err := jobs.submit(spec)
+		switch {
+		case errors.Is(err, errQueueFull):
+			err = &apiError{http.StatusServiceUnavailable, err.Error()}
+		case errors.Is(err, errImportRunning):
+			err = &apiError{http.StatusConflict, err.Error()}
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/v1/jobs/"+j.ID)
+		writeJSON(w, http.StatusAccepted, j)
+	})
+	s.HandleFunc("GET /v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": jobs.list()})
+	})
+	s.HandleFunc("GET /v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := jobs.get(r.PathValue("id"))
+		if !ok {
+			writeError(w, &apiError{http.StatusNotFound, errJobNotFound.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	})
+	s.HandleFunc("DELETE /v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, err := jobs.cancelJob(r.PathValue("id"))
+		switch {
+		case errors.Is(err, errJobNotFound):
+			writeError(w, &apiError{http.StatusNotFound, err.Error()})
+		case errors.Is(err, errJobFinished):
+			writeError(w, &apiError{http.StatusConflict, fmt.Sprintf("job already %s", j.Status)})
+		default:
+			writeJSON(w, http.StatusOK, j)
+		}
+	})
+}
+
+// found passes on the result and error of a task, unless the result is
+// empty because one of names matches no vertex, which is a 404. Like
+// warnUnknownNames, it only looks the names up after an empty result.
+func (s *apiServer) found(result utils.ResultSet, err error, names ...string) (utils.ResultSet, error) {
+	if err != nil || !emptyResult(result) {
+		return result, err
+	}
+	for _, name := range names {
+		suggestions, err := s.engine.suggest(name)
+		if err != nil {
+			return utils.ResultSet{}, fmt.Errorf("failed to check name %q: %w", name, err)
+		}
+		switch {
+		case suggestions == nil:
+		case len(suggestions) == 0:
+			return utils.ResultSet{}, notFound("vertex %q not found", name)
+		default:
+			return utils.ResultSet{}, notFound("vertex %q not found, did you mean: %s?", name, strings.Join(suggestions, ", "))
+		}
+	}
+	return result, nil
+}
+
+// read wraps a query that does not change data
+func (s *apiServer) read(query func(r *http.Request) (utils.ResultSet, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.lock != nil {
+			s.lock.RLock()
+			defer s.lock.RUnlock()
+		}
+		respond(w, r, query)
+	}
+}
+
+// write wraps a mutating task
+func (s *apiServer) write(mutation func(r *http.Request) (utils.ResultSet, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.lock != nil {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+		}
+		respond(w, r, mutation)
+	}
+}
+
+// respond runs query and writes its result set or error
+func respond(w http.ResponseWriter, r *http.Request, query func(r *http.Request) (utils.ResultSet, error)) {
+	result, err := query(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if result.Result == nil {
+		result.Result = []map[string]interface{}{}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// writeError writes err with the status of an apiError, otherwise 500
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		status = apiErr.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Warning: could not write response: %v", err)
+	}
+}
+
+// intParam reads a required integer query parameter
+func intParam(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, badRequest("%s is required", name)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, badRequest("%s must be an integer", name)
+	}
+	return n, nil
+}
+
+// decodeBody decodes a JSON request body into v
+func decodeBody(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// statusRecorder remembers the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += n
+	return n, err
+}
+
+// requireToken rejects requests without the bearer token, except health
+// checks
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, &apiError{http.StatusUnauthorized, "missing or invalid token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequests logs every request with its status, size and duration
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		log.Printf("%s %s %d %dB %s", r.Method, r.URL.RequestURI(), recorder.status, recorder.bytes, time.Since(start).Round(time.Microsecond))
+	})
+}
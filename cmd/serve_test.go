@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"dbcli/cache"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// request sends a request to the API and decodes its JSON response
+func request(t *testing.T, handler http.Handler, method, target, body string) (int, map[string]interface{}) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	var response map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%s %s: invalid JSON %q: %v", method, target, recorder.Body.String(), err)
+	}
+	return recorder.Code, response
+}
+
+// resultNames returns the sorted names in a decoded result set
+func resultNames(response map[string]interface{}) []string {
+	var names []string
+	for _, row := range response["result"].([]interface{}) {
+		names = append(names, row.(map[string]interface{})["name"].(string))
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestServeRoutes(t *testing.T) {
+	server := newAPIServer(newFixtureEngine())
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/v1/categories/root/children", []string{"a", "b"}},
+		{"/v1/categories/root/grandchildren", []string{"c", "c", "target"}},
+		{"/v1/categories/c/parents", []string{"a", "b"}},
+		{"/v1/categories/root/reachable?avoid=target&maxDepth=1", []string{"a", "b", "root"}},
+	}
+	for _, tt := range tests {
+		status, response := request(t, server, http.MethodGet, tt.target, "")
+		if status != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %v", tt.target, status, response)
+		}
+		if got := resultNames(response); !slices.Equal(got, tt.want) {
+			t.Errorf("GET %s = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	status, response := request(t, server, http.MethodGet, "/v1/categories/root/children/count", "")
+	if status != http.StatusOK || response["result"].([]interface{})[0].(map[string]interface{})["out().size()"] != 2.0 {
+		t.Errorf("children count = %d %v, want 2", status, response)
+	}
+	if status, _ := request(t, server, http.MethodGet, "/v1/paths/shortest?from=root&to=e&maxDepth=5", ""); status != http.StatusOK {
+		t.Errorf("shortest path status = %d, want 200", status)
+	}
+	// a vertex without children is found, a name without a vertex is not
+	if status, response := request(t, server, http.MethodGet, "/v1/categories/e/children", ""); status != http.StatusOK || len(response["result"].([]interface{})) != 0 {
+		t.Errorf("children of e = %d %v, want 200 and none", status, response)
+	}
+	for _, target := range []string{"/v1/categories/missing/children", "/v1/categories/missing/children/count", "/v1/paths/shortest?from=root&to=missing&maxDepth=5"} {
+		if status, response := request(t, server, http.MethodGet, target, ""); status != http.StatusNotFound || !strings.Contains(response["error"].(string), `"missing" not found`) {
+			t.Errorf("GET %s = %d %v, want 404", target, status, response)
+		}
+	}
+	if status, _ := request(t, server, http.MethodPut, "/v1/categories/missing/popularity", `{"popularity": 1}`); status != http.StatusNotFound {
+		t.Errorf("set popularity of missing = %d, want 404", status)
+	}
+	if status, response := request(t, server, http.MethodGet, "/healthz", ""); status != http.StatusOK || response["status"] != "ok" {
+		t.Errorf("healthz = %d %v", status, response)
+	}
+}
+
+func TestServeBadRequests(t *testing.T) {
+	server := newAPIServer(newFixtureEngine())
+	tests := []struct {
+		method, target, body string
+		want                 string
+	}{
+		{http.MethodGet, "/v1/paths/shortest?from=root&to=e", "", "maxDepth is required"},
+		{http.MethodGet, "/v1/paths/shortest?from=root&to=e&maxDepth=two", "", "maxDepth must be an integer"},
+		{http.MethodGet, "/v1/paths/most-popular?to=e&maxDepth=2", "", "from and to are required"},
+		{http.MethodGet, "/v1/categories/root/neighborhood/popularity?radius=1", "", "depth is required"},
+		{http.MethodPut, "/v1/categories/a/name", `{}`, "name is required"},
+		{http.MethodPut, "/v1/categories/a/popularity", `{"popularity": "high"}`, "invalid JSON body"},
+		{http.MethodPut, "/v1/categories/a/popularity", `{"rank": 1}`, "invalid JSON body"},
+	}
+	for _, tt := range tests {
+		status, response := request(t, server, tt.method, tt.target, tt.body)
+		if status != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want 400", tt.method, tt.target, status)
+		}
+		if message, _ := response["error"].(string); !strings.Contains(message, tt.want) {
+			t.Errorf("%s %s: error %q, want %q", tt.method, tt.target, message, tt.want)
+		}
+	}
+}
+
+func TestServeToken(t *testing.T) {
+	handler := requireToken("secret", newAPIServer(newFixtureEngine()))
+	if status, _ := request(t, handler, http.MethodGet, "/v1/categories/count", ""); status != http.StatusUnauthorized {
+		t.Errorf("without token: status %d, want 401", status)
+	}
+	if status, _ := request(t, handler, http.MethodGet, "/healthz", ""); status != http.StatusOK {
+		t.Errorf("healthz without token: status %d, want 200", status)
+	}
+
+	recorder := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/categories/count", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(recorder, r)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("with token: status %d, want 200", recorder.Code)
+	}
+}
+
+func TestServeWrites(t *testing.T) {
+	server := newAPIServer(newFixtureEngine())
+	if status, response := request(t, server, http.MethodPut, "/v1/categories/a/popularity", `{"popularity": 42}`); status != http.StatusOK {
+		t.Fatalf("set popularity: status %d: %v", status, response)
+	}
+	if status, response := request(t, server, http.MethodPut, "/v1/categories/a/name", `{"name": "renamed"}`); status != http.StatusOK {
+		t.Fatalf("rename: status %d: %v", status, response)
+	}
+
+	_, response := request(t, server, http.MethodGet, "/v1/categories/root/children", "")
+	found := false
+	for _, row := range response["result"].([]interface{}) {
+		if row := row.(map[string]interface{}); row["name"] == "renamed" {
+			found = true
+			if row["popularity"] != 42.0 {
+				t.Errorf("popularity of renamed = %v, want 42", row["popularity"])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("children of root = %v, want renamed among them", response)
+	}
+}
+
+func TestServeCachedNotFound(t *testing.T) {
+	c, err := cache.Open(cache.Options{Backend: cache.Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newAPIServer(&cachedEngine{taskEngine: newFixtureEngine(), cache: c, source: "fixture", scope: "fixture"})
+	// the second lookup is answered from the cache
+	for i := 0; i < 2; i++ {
+		if status, response := request(t, server, http.MethodGet, "/v1/categories/missing/reachable/count?maxDepth=2", ""); status != http.StatusNotFound {
+			t.Errorf("lookup %d: status %d %v, want 404", i+1, status, response)
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 1 {
+		t.Errorf("stats = %+v, want 1 hit", stats)
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	similarTop      int
+	similarMetric   string
+	similarBy       string
+	similarWeighted bool
+)
+
+// similarNeighborhoods maps --by to the neighborhood that is compared
+var similarNeighborhoods = map[string]graph.Direction{
+	"parents":  graph.In,
+	"children": graph.Out,
+	"both":     graph.Both,
+}
+
+// similarCmd recommends categories related to a given one
+var similarCmd = &cobra.Command{
+	Use:   "similar [name]",
+	Short: "Find categories sharing parents or children with a given category",
+	Long: `Score other categories by the overlap of their parents, children or both with
+the given category, using Jaccard, cosine or Adamic-Adar similarity.
+
+With the orientdb engine only the categories sharing a neighbor are fetched,
+together with their neighbor lists, so the query stays small. The memory
+engine scores against the whole loaded graph.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		dir, ok := similarNeighborhoods[similarBy]
+		if !ok {
+			log.Fatalf("Unknown neighborhood %q, expected parents, children or both", similarBy)
+		}
+
+		var g *graph.Graph
+		var err error
+		if engineName == engineOrientDB {
+			g, err = fetchSimilarityNeighborhood(name, dir)
+		} else {
+			g, err = loadGraph()
+		}
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+		v, ok := g.Lookup(name)
+		if !ok {
+			log.Fatalf("Vertex %q not found", name)
+		}
+
+		similar, err := g.Similar(v, graph.SimilarityOptions{Dir: dir, Metric: similarMetric, Weighted: similarWeighted, Limit: similarTop})
+		if err != nil {
+			log.Fatal(err)
+		}
+		result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(similar))}
+		for i, s := range similar {
+			result.Result = append(result.Result, map[string]interface{}{
+				"rank":       i + 1,
+				"name":       g.Name(s.Vertex),
+				"score":      s.Score,
+				"shared":     s.Shared,
+				"popularity": g.Popularity(s.Vertex),
+			})
+		}
+		if err := utils.WriteResultSet(os.Stdout, outputFormat, result, []string{"rank", "name", "score", "shared", "popularity"}); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(similarCmd)
+	addGraphFlags(similarCmd)
+	addOutputFlag(similarCmd)
+	similarCmd.Flags().IntVar(&similarTop, "top", 10, "number of similar categories to print, 0 for all")
+	similarCmd.Flags().StringVar(&similarMetric, "metric", graph.Jaccard, "jaccard, cosine or adamic-adar")
+	similarCmd.Flags().StringVar(&similarBy, "by", "parents", "neighborhood to compare: parents, children or both")
+	similarCmd.Flags().BoolVar(&similarWeighted, "weighted", false, "weigh shared neighbors by their popularity")
+}
+
+// fetchSimilarityNeighborhood loads from OrientDB every vertex sharing a
+// neighbor with name (name included), each with its complete neighbor list.
+// That is all Similar needs: the neighborhoods of both sides of every pair
+// and, for Adamic-Adar, how many fetched vertices share each neighbor.
+func fetchSimilarityNeighborhood(name string, dir graph.Direction) (*graph.Graph, error) {
+	step := traverseFunctions[dir]
+	back := traverseFunctions[graph.Out]
+	switch dir {
+	case graph.Out:
+		back = traverseFunctions[graph.In]
+	case graph.Both:
+		back = traverseFunctions[graph.Both]
+	}
+	query := fmt.Sprintf(
+		"SELECT name, popularity, %s.name AS neighbors, %s.popularity AS neighborPopularity FROM (SELECT expand(%s.%s) FROM `Vertex` WHERE name = %s) LIMIT -1",
+		step, step, step, back, quoteSQLString(name))
+	result, err := utils.ExecuteQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch neighborhood of %s: %w", name, err)
+	}
+
+	popularityMap := make(map[string]int)
+	vertices := make(map[string]struct{})
+	fetched := make(map[string]struct{})
+	var edgePairs [][2]string
+	for _, record := range result.Result {
+		from, _ := record["name"].(string)
+		if _, ok := fetched[from]; ok {
+			// expand() repeats a vertex once per shared neighbor
+			continue
+		}
+		fetched[from] = struct{}{}
+		vertices[from] = struct{}{}
+		if popularity, ok := record["popularity"].(float64); ok {
+			popularityMap[from] = int(popularity)
+		}
+		neighbors, _ := record["neighbors"].([]interface{})
+		neighborPopularity, _ := record["neighborPopularity"].([]interface{})
+		for i, neighbor := range neighbors {
+			to, ok := neighbor.(string)
+			if !ok {
+				continue
+			}
+			vertices[to] = struct{}{}
+			if _, known := popularityMap[to]; !known && i < len(neighborPopularity) {
+				if popularity, ok := neighborPopularity[i].(float64); ok {
+					popularityMap[to] = int(popularity)
+				}
+			}
+			// keep real edge directions; for both the direction does not matter
+			if dir == graph.In {
+				edgePairs = append(edgePairs, [2]string{to, from})
+			} else {
+				edgePairs = append(edgePairs, [2]string{from, to})
+			}
+		}
+	}
+	return graph.New(popularityMap, vertices, edgePairs), nil
+}
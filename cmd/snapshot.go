@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotOutput string
+	snapshotVerify bool
+)
+
+// snapshotCmd groups the snapshot subcommands
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Build and inspect binary graph snapshots for the memory engine",
+}
+
+// snapshotBuildCmd parses the CSV files once and writes a snapshot
+var snapshotBuildCmd = &cobra.Command{
+	Use:   "build [data directory]",
+	Short: "Build a graph snapshot from popularity and taxonomy files",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		startLoad := time.Now()
+		g, err := graph.LoadCSV(args[0])
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+		elapsedLoad := time.Since(startLoad)
+
+		startWrite := time.Now()
+		if err := g.WriteSnapshot(snapshotOutput); err != nil {
+			log.Fatalf("Failed to write snapshot: %v", err)
+		}
+		elapsedWrite := time.Since(startWrite)
+
+		fmt.Printf("Snapshot written to %s (%d vertices, %d edges)\n", snapshotOutput, g.NumVertices(), g.NumEdges())
+		log.Printf("Load CSV: %s", elapsedLoad)
+		log.Printf("Write snapshot: %s", elapsedWrite)
+	},
+}
+
+// snapshotInfoCmd prints the header of a snapshot
+var snapshotInfoCmd = &cobra.Command{
+	Use:   "info [snapshot file]",
+	Short: "Print snapshot header stats",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		header, err := graph.ReadSnapshotHeader(path)
+		if err != nil {
+			log.Fatalf("Failed to read snapshot: %v", err)
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			log.Fatalf("Failed to stat snapshot: %v", err)
+		}
+
+		fmt.Printf("File:         %s\n", path)
+		fmt.Printf("Size:         %d bytes\n", stat.Size())
+		fmt.Printf("Version:      %d\n", header.Version)
+		fmt.Printf("Created:      %s\n", header.CreatedAt.Format(time.RFC3339))
+		fmt.Printf("Vertices:     %d\n", header.Vertices)
+		fmt.Printf("Edges:        %d\n", header.Edges)
+		fmt.Printf("String table: %d bytes\n", header.StringBytes)
+		fmt.Printf("Checksum:     %016x\n", header.Checksum)
+
+		if snapshotVerify {
+			startLoad := time.Now()
+			if _, err := graph.LoadSnapshot(path); err != nil {
+				log.Fatalf("Snapshot verification failed: %v", err)
+			}
+			fmt.Printf("Verified:     ok (loaded in %s)\n", time.Since(startLoad))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotBuildCmd)
+	snapshotCmd.AddCommand(snapshotInfoCmd)
+
+	snapshotBuildCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "graph.snap", "snapshot file to write")
+	snapshotInfoCmd.Flags().BoolVar(&snapshotVerify, "verify", false, "load the whole snapshot and verify its checksum")
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"cmp"
+	"dbcli/graph"
+	"dbcli/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsSample int
+	statsSweeps int
+)
+
+// popularityQuantiles are the quantiles reported by stats
+var popularityQuantiles = []float64{0, 0.25, 0.5, 0.75, 0.9, 0.99, 1}
+
+// statsReport summarizes the shape of the taxonomy
+type statsReport struct {
+	Vertices               int               `json:"vertices"`
+	Edges                  int               `json:"edges"`
+	Roots                  int               `json:"roots"`
+	Leaves                 int               `json:"leaves"`
+	Isolated               int               `json:"isolated"`
+	SelfLoops              int               `json:"selfLoops"`
+	OutDegree              []graph.Bucket    `json:"outDegreeHistogram"`
+	InDegree               []graph.Bucket    `json:"inDegreeHistogram"`
+	MaxOutDegree           degreeExtreme     `json:"maxOutDegree"`
+	MinPositiveOutDegree   degreeExtreme     `json:"minPositiveOutDegree"`
+	MaxInDegree            degreeExtreme     `json:"maxInDegree"`
+	Popularity             popularitySummary `json:"popularity"`
+	WeakComponents         int               `json:"weakComponents"`
+	LargestWeakComponent   int               `json:"largestWeakComponent"`
+	StrongComponents       int               `json:"strongComponents"`
+	LargestStrongComponent int               `json:"largestStrongComponent"`
+	EstimatedDiameter      int               `json:"estimatedDiameter"`
+	DiameterSweeps         int               `json:"diameterSweeps"`
+}
+
+// degreeExtreme lists the vertices sharing an extreme degree
+type degreeExtreme struct {
+	Degree int      `json:"degree"`
+	Count  int      `json:"count"`
+	Sample []string `json:"sample"`
+}
+
+// popularitySummary describes the popularity distribution
+type popularitySummary struct {
+	Total     int64              `json:"total"`
+	Mean      float64            `json:"mean"`
+	Quantiles []quantileSnapshot `json:"quantiles"`
+}
+
+// quantileSnapshot is the popularity at one quantile
+type quantileSnapshot struct {
+	Quantile float64 `json:"quantile"`
+	Value    int64   `json:"value"`
+}
+
+// statsCmd reports statistics about the data itself
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Report vertex, degree, popularity and connectivity statistics",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		g, err := loadGraph()
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+		report := collectStats(g, statsSample, statsSweeps)
+		if err := writeStatsReport(report); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	addGraphFlags(statsCmd)
+	addOutputFlag(statsCmd)
+	statsCmd.Flags().IntVar(&statsSample, "sample", 5, "vertices to list for each extreme degree, most popular first")
+	statsCmd.Flags().IntVar(&statsSweeps, "sweeps", 4, "BFS sweeps used to estimate the diameter")
+}
+
+// collectStats computes the report; degree statistics take a single pass
+func collectStats(g *graph.Graph, sample, sweeps int) statsReport {
+	n := g.NumVertices()
+	report := statsReport{Vertices: n, Edges: g.NumEdges(), DiameterSweeps: sweeps}
+
+	outDegrees := make([]int, n)
+	inDegrees := make([]int, n)
+	popularity := make([]int64, n)
+	var maxOut, minOut, maxIn []int32
+	hub := int32(-1)
+	for v := int32(0); v < int32(n); v++ {
+		out, in := g.OutDegree(v), g.InDegree(v)
+		outDegrees[v], inDegrees[v] = out, in
+		popularity[v] = g.Popularity(v)
+		report.Popularity.Total += popularity[v]
+		if in == 0 {
+			report.Roots++
+		}
+		if out == 0 {
+			report.Leaves++
+		}
+		if in == 0 && out == 0 {
+			report.Isolated++
+		}
+		if g.HasSelfLoop(v) {
+			report.SelfLoops++
+		}
+		if hub < 0 || out+in > g.OutDegree(hub)+g.InDegree(hub) {
+			hub = v
+		}
+		maxOut = trackExtreme(maxOut, v, out, outDegrees, func(a, b int) bool { return a > b })
+		if out > 0 {
+			minOut = trackExtreme(minOut, v, out, outDegrees, func(a, b int) bool { return a < b })
+		}
+		maxIn = trackExtreme(maxIn, v, in, inDegrees, func(a, b int) bool { return a > b })
+	}
+	report.OutDegree = graph.LogHistogram(outDegrees)
+	report.InDegree = graph.LogHistogram(inDegrees)
+	report.MaxOutDegree = summarizeExtreme(g, maxOut, outDegrees, sample)
+	report.MinPositiveOutDegree = summarizeExtreme(g, minOut, outDegrees, sample)
+	report.MaxInDegree = summarizeExtreme(g, maxIn, inDegrees, sample)
+
+	if n > 0 {
+		report.Popularity.Mean = float64(report.Popularity.Total) / float64(n)
+		slices.Sort(popularity)
+		for _, q := range popularityQuantiles {
+			report.Popularity.Quantiles = append(report.Popularity.Quantiles, quantileSnapshot{
+				Quantile: q,
+				Value:    popularity[int(q*float64(n-1)+0.5)],
+			})
+		}
+	}
+
+	weak, weakCount := g.WeaklyConnectedComponents()
+	report.WeakComponents = weakCount
+	report.LargestWeakComponent = largestComponent(weak, weakCount)
+	strong, strongCount := g.StronglyConnectedComponents()
+	report.StrongComponents = strongCount
+	report.LargestStrongComponent = largestComponent(strong, strongCount)
+
+	if hub >= 0 {
+		report.EstimatedDiameter = g.EstimateDiameter(hub, sweeps)
+	}
+	return report
+}
+
+// trackExtreme keeps the vertices whose degree is the best seen so far
+func trackExtreme(current []int32, v int32, degree int, degrees []int, better func(a, b int) bool) []int32 {
+	if len(current) == 0 || better(degree, degrees[current[0]]) {
+		return append(current[:0], v)
+	}
+	if degree == degrees[current[0]] {
+		return append(current, v)
+	}
+	return current
+}
+
+// summarizeExtreme reports the degree, count and most popular vertices
+func summarizeExtreme(g *graph.Graph, vertices []int32, degrees []int, sample int) degreeExtreme {
+	if len(vertices) == 0 {
+		return degreeExtreme{}
+	}
+	summary := degreeExtreme{Degree: degrees[vertices[0]], Count: len(vertices)}
+	sorted := slices.Clone(vertices)
+	slices.SortFunc(sorted, func(a, b int32) int {
+		return cmp.Compare(g.Popularity(b), g.Popularity(a))
+	})
+	for _, v := range sorted[:min(sample, len(sorted))] {
+		summary.Sample = append(summary.Sample, g.Name(v))
+	}
+	return summary
+}
+
+// largestComponent returns the size of the largest component
+func largestComponent(comp []int32, count int) int {
+	sizes := make([]int, count)
+	largest := 0
+	for _, c := range comp {
+		sizes[c]++
+		largest = max(largest, sizes[c])
+	}
+	return largest
+}
+
+// writeStatsReport prints the report as JSON, or as a series of tables in
+// table or Markdown format
+func writeStatsReport(report statsReport) error {
+	switch outputFormat {
+	case utils.FormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case utils.FormatTable, utils.FormatMarkdown:
+	default:
+		return fmt.Errorf("unknown output format %q, expected %s, %s or %s", outputFormat, utils.FormatTable, utils.FormatJSON, utils.FormatMarkdown)
+	}
+
+	overview := [][2]interface{}{
+		{"Vertices", report.Vertices},
+		{"Edges", report.Edges},
+		{"Roots (no parents)", report.Roots},
+		{"Leaves (no children)", report.Leaves},
+		{"Isolated", report.Isolated},
+		{"Self-loops", report.SelfLoops},
+		{"Weakly connected components", report.WeakComponents},
+		{"Largest weak component", report.LargestWeakComponent},
+		{"Strongly connected components", report.StrongComponents},
+		{"Largest strong component", report.LargestStrongComponent},
+		{"Estimated diameter (undirected)", report.EstimatedDiameter},
+		{"Total popularity", report.Popularity.Total},
+		{"Mean popularity", report.Popularity.Mean},
+	}
+	overviewRows := utils.ResultSet{}
+	for _, row := range overview {
+		overviewRows.Result = append(overviewRows.Result, map[string]interface{}{"metric": row[0], "value": row[1]})
+	}
+
+	degreeRows := func(buckets []graph.Bucket) utils.ResultSet {
+		rows := utils.ResultSet{}
+		for _, bucket := range buckets {
+			rows.Result = append(rows.Result, map[string]interface{}{"degree": bucketLabel(bucket), "vertices": bucket.Count})
+		}
+		return rows
+	}
+
+	extremeRows := utils.ResultSet{}
+	for _, extreme := range []struct {
+		label string
+		value degreeExtreme
+	}{
+		{"Max out-degree (task 10)", report.MaxOutDegree},
+		{"Min positive out-degree (task 11)", report.MinPositiveOutDegree},
+		{"Max in-degree", report.MaxInDegree},
+	} {
+		extremeRows.Result = append(extremeRows.Result, map[string]interface{}{
+			"kind":     extreme.label,
+			"degree":   extreme.value.Degree,
+			"vertices": extreme.value.Count,
+			"sample":   strings.Join(extreme.value.Sample, ", "),
+		})
+	}
+
+	quantileRows := utils.ResultSet{}
+	for _, q := range report.Popularity.Quantiles {
+		quantileRows.Result = append(quantileRows.Result, map[string]interface{}{
+			"quantile":   fmt.Sprintf("p%g", q.Quantile*100),
+			"popularity": q.Value,
+		})
+	}
+
+	return writeSections([]reportSection{
+		{"Overview", overviewRows, []string{"metric", "value"}},
+		{"Out-degree distribution", degreeRows(report.OutDegree), []string{"degree", "vertices"}},
+		{"In-degree distribution", degreeRows(report.InDegree), []string{"degree", "vertices"}},
+		{"Extreme degrees", extremeRows, []string{"kind", "degree", "vertices", "sample"}},
+		{"Popularity quantiles", quantileRows, []string{"quantile", "popularity"}},
+	})
+}
+
+// reportSection is a titled table of a multi-table report
+type reportSection struct {
+	title   string
+	rows    utils.ResultSet
+	columns []string
+}
+
+// writeSections prints the sections one after another in table or Markdown
+// format, each under its title
+func writeSections(sections []reportSection) error {
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Println()
+		}
+		if outputFormat == utils.FormatMarkdown {
+			fmt.Printf("### %s\n\n", section.title)
+		} else {
+			fmt.Printf("%s\n", strings.ToUpper(section.title))
+		}
+		if err := utils.WriteResultSet(os.Stdout, outputFormat, section.rows, section.columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
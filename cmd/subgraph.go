@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"strings"
+)
+
+// traverseFunctions maps a direction to the OrientDB traversal function
+var traverseFunctions = map[graph.Direction]string{
+	graph.Out:  "out()",
+	graph.In:   "in()",
+	graph.Both: "both()",
+}
+
+// fetchSubgraph loads the neighborhood of name (up to depth hops in the given
+// direction, or everything reachable if depth is negative) from OrientDB into
+// an in-memory graph, so that searches which cannot be expressed in SQL can
+// run locally on just the part they need. Edges between two fetched vertices
+// are kept in their original direction.
+func fetchSubgraph(name string, dir graph.Direction, depth int) (*graph.Graph, error) {
+	return fetchSubgraphs([]string{name}, dir, depth)
+}
+
+// fetchSubgraphs is like fetchSubgraph but starts from several vertices and
+// returns the union of their neighborhoods
+func fetchSubgraphs(names []string, dir graph.Direction, depth int) (*graph.Graph, error) {
+	while := ""
+	if depth >= 0 {
+		while = fmt.Sprintf(" WHILE $depth <= %d", depth)
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteSQLString(name)
+	}
+	query := fmt.Sprintf(
+		"SELECT name, popularity, out().name AS children FROM (TRAVERSE %s FROM (SELECT FROM `Vertex` WHERE name IN [%s])%s STRATEGY BREADTH_FIRST) LIMIT -1",
+		traverseFunctions[dir], strings.Join(quoted, ", "), while)
+	result, err := utils.ExecuteQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subgraph around %s: %w", strings.Join(names, ", "), err)
+	}
+
+	return graphFromRecords(result.Result), nil
+}
+
+// fetchGraph loads the whole taxonomy from OrientDB into an in-memory graph
+func fetchGraph() (*graph.Graph, error) {
+	result, err := utils.ExecuteQuery("SELECT name, popularity, out().name AS children FROM `Vertex` LIMIT -1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch graph: %w", err)
+	}
+	return graphFromRecords(result.Result), nil
+}
+
+// graphFromRecords builds a graph from records with name, popularity and
+// children fields. Edges to vertices outside the records are dropped.
+func graphFromRecords(records []map[string]interface{}) *graph.Graph {
+	popularityMap := make(map[string]int, len(records))
+	vertices := make(map[string]struct{}, len(records))
+	var edgePairs [][2]string
+	for _, record := range records {
+		from, _ := record["name"].(string)
+		vertices[from] = struct{}{}
+		if popularity, ok := record["popularity"].(float64); ok {
+			popularityMap[from] = int(popularity)
+		}
+		children, _ := record["children"].([]interface{})
+		for _, child := range children {
+			if to, ok := child.(string); ok {
+				edgePairs = append(edgePairs, [2]string{from, to})
+			}
+		}
+	}
+	return graph.New(popularityMap, vertices, edgePairs)
+}
@@ -1,11 +1,18 @@
 package cmd
 
 import (
+	"dbcli/graph"
+	"dbcli/metrics"
 	"dbcli/utils"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"log"
+	"math/rand/v2"
 	"strconv" // only needed if you want to parse integers (for popularity or radius)
+	"strings"
+	"time"
 )
 
 var taskCmd = &cobra.Command{
@@ -16,301 +23,481 @@ var taskCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		taskNumberStr := args[0]
+		// checked first so bad arguments fail before the graph is loaded
+		call, err := parseTask(taskNumberStr, args[1:])
+		if err != nil {
+			log.Fatalf("Failed to execute Task%s: %v", taskNumberStr, err)
+		}
+		// started before the engine so the peak heap covers loading the graph
+		finishMetrics := startMetrics("task")
 
-		var result utils.ResultSet
-		var err error
+		engine, err := newTaskEngine()
+		if err != nil {
+			finishMetrics(false)
+			log.Fatalf("Failed to initialize %s engine: %v", engineName, err)
+		}
 
-		switch taskNumberStr {
-		case "1":
-			// 1 argument: [1 nodeName]
-			if len(args) < 2 {
-				log.Fatal("Task1 requires [nodeName]")
-			}
-			name := args[1]
-			result, err = task1(name)
+		startTask := time.Now()
+		result, err := call(engine)
+		elapsedTask := time.Since(startTask)
+		metrics.Default.Gauge("dbcli_task_duration_seconds", "Time spent executing the task.", "task", taskNumberStr, "engine", engineName).Set(elapsedTask.Seconds())
+		metrics.Default.Gauge("dbcli_task_records", "Records returned by the task.", "task", taskNumberStr, "engine", engineName).Set(float64(len(result.Result)))
+		finishMetrics(err == nil)
+		if err != nil {
+			log.Fatalf("Failed to execute Task%s: %v", taskNumberStr, err)
+		}
 
-		case "2":
-			// 1 argument: [2 nodeName]
-			if len(args) < 2 {
-				log.Fatal("Task2 requires [nodeName]")
-			}
-			name := args[1]
-			result, err = task2(name)
+		// looked up only now so the check costs nothing when the names exist
+		if emptyResult(result) {
+			warnUnknownNames(engine, taskNumberStr, args[1:])
+		}
+		log.Printf("Response Body for Task%s: %v", taskNumberStr, result)
+		log.Printf("Task%s completed in %s (engine: %s)", taskNumberStr, elapsedTask, engineName)
+		logCacheStats()
+	},
+}
 
-		case "3":
-			// 1 argument: [3 nodeName]
-			if len(args) < 2 {
-				log.Fatal("Task3 requires [nodeName]")
-			}
-			name := args[1]
-			result, err = task3(name)
+func init() {
+	rootCmd.AddCommand(taskCmd)
+	addGraphFlags(taskCmd)
+	addMetricsFlags(taskCmd)
+	addCacheFlags(taskCmd)
+	taskCmd.Flags().DurationVar(&searchTimeout, "timeout", 30*time.Second, "time limit for path searches (task 18)")
+	taskCmd.Flags().Int64Var(&searchMaxExpanded, "max-expanded", 10_000_000, "partial paths a path search may expand before giving up, 0 for no limit (task 18)")
+}
 
-		case "4":
-			// 1 argument: [4 nodeName]
-			if len(args) < 2 {
-				log.Fatal("Task4 requires [nodeName]")
-			}
-			name := args[1]
-			result, err = task4(name)
+// taskCall is a task with parsed arguments, ready to run on an engine
+type taskCall func(engine taskEngine) (utils.ResultSet, error)
+
+// runTask dispatches a task by number, args being the task arguments without
+// the number
+func runTask(engine taskEngine, taskNumber string, args []string) (utils.ResultSet, error) {
+	call, err := parseTask(taskNumber, args)
+	if err != nil {
+		return utils.ResultSet{}, err
+	}
+	return call(engine)
+}
 
-		case "5":
-			// 1 argument: [5 nodeName]
-			if len(args) < 2 {
-				log.Fatal("Task5 requires [nodeName]")
-			}
-			name := args[1]
-			result, err = task5(name)
+// parseTask checks the arguments of a task, so they can be rejected before an
+// engine is set up
+func parseTask(taskNumber string, args []string) (taskCall, error) {
+	switch taskNumber {
+	case "1":
+		// 1 argument: [1 nodeName]
+		if len(args) < 1 {
+			return nil, errors.New("Task1 requires [nodeName]")
+		}
+		name := args[0]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task1(name) }, nil
 
-		case "6":
-			// 1 argument: [6 nodeName]
-			if len(args) < 2 {
-				log.Fatal("Task6 requires [nodeName]")
-			}
-			name := args[1]
-			result, err = task6(name)
+	case "2":
+		// 1 argument: [2 nodeName]
+		if len(args) < 1 {
+			return nil, errors.New("Task2 requires [nodeName]")
+		}
+		name := args[0]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task2(name) }, nil
 
-		case "7":
-			// no arguments needed: [7]
-			result, err = task7()
+	case "3":
+		// 1 argument: [3 nodeName]
+		if len(args) < 1 {
+			return nil, errors.New("Task3 requires [nodeName]")
+		}
+		name := args[0]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task3(name) }, nil
 
-		case "8":
-			// no arguments needed: [8]
-			result, err = task8()
+	case "4":
+		// 1 argument: [4 nodeName]
+		if len(args) < 1 {
+			return nil, errors.New("Task4 requires [nodeName]")
+		}
+		name := args[0]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task4(name) }, nil
 
-		case "9":
-			// no arguments needed: [9]
-			result, err = task9()
+	case "5":
+		// 1 argument: [5 nodeName]
+		if len(args) < 1 {
+			return nil, errors.New("Task5 requires [nodeName]")
+		}
+		name := args[0]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task5(name) }, nil
 
-		case "10":
-			// no arguments needed: [10]
-			result, err = task10()
+	case "6":
+		// 1 argument: [6 nodeName]
+		if len(args) < 1 {
+			return nil, errors.New("Task6 requires [nodeName]")
+		}
+		name := args[0]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task6(name) }, nil
 
-		case "11":
-			// no arguments needed: [11]
-			result, err = task11()
+	case "7":
+		// no arguments needed: [7]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task7() }, nil
 
-		case "12":
-			// 2 arguments: [12 oldName newName]
-			if len(args) < 3 {
-				log.Fatal("Task12 requires [oldName newName]")
-			}
-			oldName := args[1]
-			newName := args[2]
-			result, err = task12(oldName, newName)
-
-		case "13":
-			// 2 arguments: [13 name newPopularity]
-			if len(args) < 3 {
-				log.Fatal("Task13 requires [name newPopularity]")
-			}
-			name := args[1]
-			// parse popularity if you want an integer
-			popularity, parseErr := strconv.Atoi(args[2])
-			if parseErr != nil {
-				log.Fatalf("popularity must be an integer: %v", parseErr)
-			}
-			result, err = task13(name, popularity)
+	case "8":
+		// no arguments needed: [8]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task8() }, nil
 
-		case "14":
-			// 3 arguments: [14 sourceName targetName depth]
-			if len(args) < 3 {
-				log.Fatal("Task14 requires [sourceName targetName depth]")
-			}
-			sourceName := args[1]
-			depthStr := args[2]
-			depth, parseErr := strconv.Atoi(depthStr)
-			if parseErr != nil {
-				log.Fatalf("depth must be an integer: %v", parseErr)
-			}
-			result, err = task14(sourceName, depth)
+	case "9":
+		// no arguments needed: [9]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task9() }, nil
 
-		case "15":
-			// 3 arguments: [15 sourceName targetName depth]
-			if len(args) < 4 {
-				log.Fatal("Task15 requires [sourceName targetName depth]")
-			}
-			sourceName := args[1]
-			targetName := args[2]
-			depthStr := args[3]
-			depth, parseErr := strconv.Atoi(depthStr)
-			if parseErr != nil {
-				log.Fatalf("depth must be an integer: %v", parseErr)
-			}
-			result, err = task15(sourceName, targetName, depth)
+	case "10":
+		// no arguments needed: [10]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task10() }, nil
 
-		case "16":
-			// 3 arguments: [16 name radius depth]
-			if len(args) < 4 {
-				log.Fatal("Task16 requires [name radius depth]")
-			}
-			name := args[1]
-			radiusStr := args[2]
-			depthStr := args[3]
-			radius, parseErr := strconv.Atoi(radiusStr)
-			if parseErr != nil {
-				log.Fatalf("radius must be an integer: %v", parseErr)
-			}
-			depth, parseErr := strconv.Atoi(depthStr)
-			if parseErr != nil {
-				log.Fatalf("depth must be an integer: %v", parseErr)
-			}
-			result, err = task16(name, radius, depth)
+	case "11":
+		// no arguments needed: [11]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task11() }, nil
 
-		case "17":
-			// 3 arguments: [17 sourceName targetName depth]
-			if len(args) < 4 {
-				log.Fatal("Task17 requires [sourceName targetName depth]")
-			}
-			sourceName := args[1]
-			targetName := args[2]
-			depthStr := args[3]
-			depth, parseErr := strconv.Atoi(depthStr)
-			if parseErr != nil {
-				log.Fatalf("depth must be an integer: %v", parseErr)
-			}
-			result, err = task17(sourceName, targetName, depth)
+	case "12":
+		// 2 arguments: [12 oldName newName]
+		if len(args) < 2 {
+			return nil, errors.New("Task12 requires [oldName newName]")
+		}
+		oldName := args[0]
+		newName := args[1]
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task12(oldName, newName) }, nil
+
+	case "13":
+		// 2 arguments: [13 name newPopularity]
+		if len(args) < 2 {
+			return nil, errors.New("Task13 requires [name newPopularity]")
+		}
+		name := args[0]
+		// parse popularity if you want an integer
+		popularity, parseErr := strconv.Atoi(args[1])
+		if parseErr != nil {
+			return nil, fmt.Errorf("popularity must be an integer: %w", parseErr)
+		}
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task13(name, popularity) }, nil
 
-		case "18":
-			// Expecting 2 arguments: [18 sourceName targetName]
-			if len(args) < 3 {
-				log.Fatal("Task18 requires [sourceName targetName depth]")
-			}
-			sourceName := args[1]
-			targetName := args[2]
-			result, err = task18(sourceName, targetName)
-			// Note: If you want to add depth to Task18 as well, follow similar steps
+	case "14":
+		// 3 arguments: [14 sourceName targetName depth]
+		if len(args) < 3 {
+			return nil, errors.New("Task14 requires [sourceName targetName depth]")
+		}
+		sourceName := args[0]
+		targetName := args[1]
+		depthStr := args[2]
+		depth, parseErr := strconv.Atoi(depthStr)
+		if parseErr != nil {
+			return nil, fmt.Errorf("depth must be an integer: %w", parseErr)
+		}
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task14(sourceName, targetName, depth) }, nil
+
+	case "15":
+		// 3 arguments: [15 sourceName targetName depth]
+		if len(args) < 3 {
+			return nil, errors.New("Task15 requires [sourceName targetName depth]")
+		}
+		sourceName := args[0]
+		targetName := args[1]
+		depthStr := args[2]
+		depth, parseErr := strconv.Atoi(depthStr)
+		if parseErr != nil {
+			return nil, fmt.Errorf("depth must be an integer: %w", parseErr)
+		}
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task15(sourceName, targetName, depth) }, nil
+
+	case "16":
+		// 3 arguments: [16 name radius depth]
+		if len(args) < 3 {
+			return nil, errors.New("Task16 requires [name radius depth]")
+		}
+		name := args[0]
+		radiusStr := args[1]
+		depthStr := args[2]
+		radius, parseErr := strconv.Atoi(radiusStr)
+		if parseErr != nil {
+			return nil, fmt.Errorf("radius must be an integer: %w", parseErr)
+		}
+		depth, parseErr := strconv.Atoi(depthStr)
+		if parseErr != nil {
+			return nil, fmt.Errorf("depth must be an integer: %w", parseErr)
+		}
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task16(name, radius, depth) }, nil
+
+	case "17":
+		// 3 arguments: [17 sourceName targetName depth]
+		if len(args) < 3 {
+			return nil, errors.New("Task17 requires [sourceName targetName depth]")
+		}
+		sourceName := args[0]
+		targetName := args[1]
+		depthStr := args[2]
+		depth, parseErr := strconv.Atoi(depthStr)
+		if parseErr != nil {
+			return nil, fmt.Errorf("depth must be an integer: %w", parseErr)
+		}
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task17(sourceName, targetName, depth) }, nil
+
+	case "18":
+		// 3 arguments: [18 sourceName targetName depth]
+		if len(args) < 3 {
+			return nil, errors.New("Task18 requires [sourceName targetName depth]")
+		}
+		sourceName := args[0]
+		targetName := args[1]
+		depthStr := args[2]
+		depth, parseErr := strconv.Atoi(depthStr)
+		if parseErr != nil {
+			return nil, fmt.Errorf("depth must be an integer: %w", parseErr)
+		}
+		return func(engine taskEngine) (utils.ResultSet, error) { return engine.task18(sourceName, targetName, depth) }, nil
+
+	default:
+		return nil, fmt.Errorf("invalid task number: %s. Please provide a number between 1 and 18", taskNumber)
+	}
+}
 
-		default:
-			log.Fatalf("Invalid task number: %s. Please provide a number between 1 and 18.", taskNumberStr)
+// taskNameArgs is the number of leading task arguments that name a vertex
+var taskNameArgs = map[string]int{
+	"1": 1, "2": 1, "3": 1, "4": 1, "5": 1, "6": 1,
+	"12": 1, "13": 1, "14": 2, "15": 2, "16": 1, "17": 2, "18": 2,
+}
+
+// emptyResult reports whether a result has no rows or only rows whose values
+// are null or zero, as counts and sums over an unknown name are
+func emptyResult(result utils.ResultSet) bool {
+	for _, row := range result.Result {
+		for key, value := range row {
+			if strings.HasPrefix(key, "@") {
+				continue
+			}
+			switch value := value.(type) {
+			case nil:
+			case float64:
+				if value != 0 {
+					return false
+				}
+			case int:
+				if value != 0 {
+					return false
+				}
+			case int64:
+				if value != 0 {
+					return false
+				}
+			case json.Number:
+				// cache hits keep numbers as they were returned
+				if f, err := value.Float64(); err != nil || f != 0 {
+					return false
+				}
+			default:
+				return false
+			}
 		}
+	}
+	return true
+}
 
+// warnUnknownNames logs a "did you mean" hint for every name argument that
+// does not match a vertex, since the tasks then quietly return nothing. It
+// costs a query per name, so it is only called after an empty result.
+func warnUnknownNames(engine taskEngine, taskNumber string, args []string) {
+	n := min(taskNameArgs[taskNumber], len(args))
+	for _, name := range args[:n] {
+		suggestions, err := engine.suggest(name)
 		if err != nil {
-			log.Fatalf("Failed to execute Task%s: %v", taskNumberStr, err)
+			log.Printf("Warning: could not check name %q: %v", name, err)
+			continue
+		}
+		if suggestions == nil {
+			continue
+		}
+		if len(suggestions) == 0 {
+			log.Printf("Vertex %q not found", name)
+		} else {
+			log.Printf("Vertex %q not found, did you mean: %s?", name, strings.Join(suggestions, ", "))
 		}
+	}
+}
 
-		log.Printf("Response Body for Task%s: %s", taskNumberStr, result)
-	},
+// orientEngine answers tasks with SQL queries against OrientDB
+type orientEngine struct{}
+
+func (orientEngine) suggest(name string) ([]string, error) {
+	query := fmt.Sprintf("SELECT count(*) FROM `Vertex` WHERE name = %s", quoteSQLString(name))
+	result, err := utils.ExecuteQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Result) > 0 {
+		if count, _ := result.Result[0]["count(*)"].(float64); count > 0 {
+			return nil, nil
+		}
+	}
+	candidates, err := fetchSearchCandidates(name, graph.SearchOptions{Mode: graph.SearchAuto, MaxDistance: 2})
+	if err != nil {
+		return nil, err
+	}
+	return suggestNames(candidates, name, suggestionLimit), nil
 }
 
-func init() {
-	rootCmd.AddCommand(taskCmd)
+// sampleNames reads windows of consecutive vertices at random offsets, since
+// OrientDB has no cheap way to order by a random value
+func (orientEngine) sampleNames(n int, rng *rand.Rand) ([]string, error) {
+	result, err := utils.ExecuteQuery("SELECT count(*) FROM `Vertex`")
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	if len(result.Result) > 0 {
+		count, _ := result.Result[0]["count(*)"].(float64)
+		total = int(count)
+	}
+	if total == 0 || n <= 0 {
+		return nil, nil
+	}
+
+	windows := min(10, n)
+	size := (n + windows - 1) / windows
+	names := make([]string, 0, n)
+	for len(names) < n {
+		skip := rng.IntN(max(total-size, 1))
+		query := fmt.Sprintf("SELECT name FROM `Vertex` SKIP %d LIMIT %d", skip, min(size, n-len(names)))
+		result, err := utils.ExecuteQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Result) == 0 {
+			break
+		}
+		for _, record := range result.Result {
+			if name, ok := record["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
 }
 
 // 1. finds all children of a given node
-func task1(name string) (utils.ResultSet, error) {
-	query := fmt.Sprintf("SELECT expand(out()) FROM `Vertex` WHERE name = \"%s\"", name)
+func (orientEngine) task1(name string) (utils.ResultSet, error) {
+	query := fmt.Sprintf("SELECT expand(out()) FROM `Vertex` WHERE name = %s", quoteSQLString(name))
 	return utils.ExecuteQuery(query)
 }
 
 // 2. counts all children of a given node
-func task2(name string) (utils.ResultSet, error) {
-	query := fmt.Sprintf("SELECT out().size() FROM `Vertex` WHERE name = \"%s\"", name)
+func (orientEngine) task2(name string) (utils.ResultSet, error) {
+	query := fmt.Sprintf("SELECT out().size() FROM `Vertex` WHERE name = %s", quoteSQLString(name))
 	return utils.ExecuteQuery(query)
 }
 
 // 3. finds all grandchildren of a given node
-func task3(name string) (utils.ResultSet, error) {
-	query := fmt.Sprintf("SELECT expand(out()).out() FROM `Vertex` WHERE name = \"%s\"", name)
+func (orientEngine) task3(name string) (utils.ResultSet, error) {
+	query := fmt.Sprintf("SELECT expand(out()).out() FROM `Vertex` WHERE name = %s", quoteSQLString(name))
 	return utils.ExecuteQuery(query)
 }
 
 // 4. finds all parents of a given node
-func task4(name string) (utils.ResultSet, error) {
-	query := fmt.Sprintf("SELECT expand(in()) FROM `Vertex` WHERE name = \"%s\"", name)
+func (orientEngine) task4(name string) (utils.ResultSet, error) {
+	query := fmt.Sprintf("SELECT expand(in()) FROM `Vertex` WHERE name = %s", quoteSQLString(name))
 	return utils.ExecuteQuery(query)
 }
 
 // 5. counts all parents of a given node
-func task5(name string) (utils.ResultSet, error) {
-	query := fmt.Sprintf("SELECT in().size() FROM `Vertex` WHERE name = \"%s\"", name)
+func (orientEngine) task5(name string) (utils.ResultSet, error) {
+	query := fmt.Sprintf("SELECT in().size() FROM `Vertex` WHERE name = %s", quoteSQLString(name))
 	return utils.ExecuteQuery(query)
 }
 
 // 6. finds all grandparents of a given node
-func task6(name string) (utils.ResultSet, error) {
-	query := fmt.Sprintf("SELECT expand(in().in()) FROM `Vertex` WHERE name = \"%s\"", name)
+func (orientEngine) task6(name string) (utils.ResultSet, error) {
+	query := fmt.Sprintf("SELECT expand(in().in()) FROM `Vertex` WHERE name = %s", quoteSQLString(name))
 	return utils.ExecuteQuery(query)
 }
 
 // 7. counts how many distinct node names exist
-func task7() (utils.ResultSet, error) {
+func (orientEngine) task7() (utils.ResultSet, error) {
 	query := "SELECT count(distinct(name)) FROM `Vertex`"
 	return utils.ExecuteQuery(query)
 }
 
 // 8. finds nodes that are not a subcategory of any other node
-func task8() (utils.ResultSet, error) {
+func (orientEngine) task8() (utils.ResultSet, error) {
 	query := "SELECT * FROM `Vertex` WHERE in().size() = 0"
 	return utils.ExecuteQuery(query)
 }
 
 // 9. counts how many nodes satisfy task8()
-func task9() (utils.ResultSet, error) {
+func (orientEngine) task9() (utils.ResultSet, error) {
 	query := "SELECT count(*) FROM `Vertex` WHERE in().size() = 0"
 	return utils.ExecuteQuery(query)
 }
 
 // 10. finds nodes with the largest number of children
-func task10() (utils.ResultSet, error) {
+func (orientEngine) task10() (utils.ResultSet, error) {
 	query := "SELECT FROM `Vertex` WHERE out().size() = (SELECT max(out().size()) FROM `Vertex`)"
 	return utils.ExecuteQuery(query)
 }
 
 // 11. finds nodes with the smallest number of children (greater than zero)
-func task11() (utils.ResultSet, error) {
+func (orientEngine) task11() (utils.ResultSet, error) {
 	query := "SELECT FROM `Vertex` WHERE out().size() = (SELECT min(out().size()) FROM `Vertex` WHERE out().size() > 0)"
 	return utils.ExecuteQuery(query)
 }
 
 // 12. changes the name of a given node (oldName -> newName)
-func task12(oldName, newName string) (utils.ResultSet, error) {
-	query := fmt.Sprintf("UPDATE `Vertex` SET name = '%s' WHERE name = '%s'", newName, oldName)
+func (orientEngine) task12(oldName, newName string) (utils.ResultSet, error) {
+	defer invalidateCaches()
+	query := fmt.Sprintf("UPDATE `Vertex` SET name = %s WHERE name = %s", quoteSQLString(newName), quoteSQLString(oldName))
 	return utils.ExecuteQuery(query)
 }
 
-// 13. changes the popularity of a given node
-func task13(name string, popularity int) (utils.ResultSet, error) {
+// 13. changes the popularity of a given node. Materialized aggregates are not
+// touched; materialize --status sees the new record version and reports them stale.
+func (orientEngine) task13(name string, popularity int) (utils.ResultSet, error) {
+	defer invalidateCaches()
 	// If popularity should remain a string, adjust to %%s instead of %%d
-	query := fmt.Sprintf("UPDATE `Vertex` SET popularity = %d WHERE name = '%s'", popularity, name)
+	query := fmt.Sprintf("UPDATE `Vertex` SET popularity = %d WHERE name = %s", popularity, quoteSQLString(name))
 	return utils.ExecuteQuery(query)
 }
 
-// 14. finds all paths (up to depth) from sourceName to anything except targetName
-func task14(sourceName string, depth int) (utils.ResultSet, error) {
+// 14. finds all nodes reachable (up to depth) from sourceName without passing through targetName.
+// WHILE stops the traversal at targetName, so it is neither returned nor expanded;
+// BREADTH_FIRST makes $depth the shortest distance from the source.
+func (orientEngine) task14(sourceName, targetName string, depth int) (utils.ResultSet, error) {
 	query := fmt.Sprintf(
-		"TRAVERSE out() FROM (SELECT FROM `Vertex` WHERE name = \"%s\") WHILE $depth <= %d",
-		sourceName, depth)
+		"TRAVERSE out() FROM (SELECT FROM `Vertex` WHERE name = %s) WHILE $depth <= %d AND name <> %s STRATEGY BREADTH_FIRST",
+		quoteSQLString(sourceName), depth, quoteSQLString(targetName))
 	return utils.ExecuteQuery(query)
 }
 
-// 15. counts nodes on all paths (up to depth) from sourceName to anything except targetName
-func task15(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+// 15. counts the nodes found by task14
+func (orientEngine) task15(sourceName, targetName string, depth int) (utils.ResultSet, error) {
 	query := fmt.Sprintf(
-		"SELECT count(*) FROM (TRAVERSE out() FROM (SELECT FROM `Vertex` WHERE name = \"%s\") WHILE $depth <= %d AND @rid != (SELECT @rid FROM `Vertex` WHERE name = \"%s\"))",
-		sourceName, depth, targetName)
+		"SELECT count(*) FROM (TRAVERSE out() FROM (SELECT FROM `Vertex` WHERE name = %s) WHILE $depth <= %d AND name <> %s STRATEGY BREADTH_FIRST)",
+		quoteSQLString(sourceName), depth, quoteSQLString(targetName))
 	return utils.ExecuteQuery(query)
 }
 
-// 16. calculates popularity in the neighborhood (up to 'radius' and 'depth') of the given node
-func task16(name string, radius int, depth int) (utils.ResultSet, error) {
+// 16. calculates popularity in the neighborhood (up to 'radius' and 'depth') of the given node.
+// BREADTH_FIRST reaches every vertex at its shortest distance; depth first could
+// cut a vertex off at the limit and never expand it along a shorter path.
+func (orientEngine) task16(name string, radius int, depth int) (utils.ResultSet, error) {
 	query := fmt.Sprintf(
-		"SELECT sum(popularity) FROM (TRAVERSE both() FROM (SELECT FROM `Vertex` WHERE name = \"%s\") WHILE $depth <= %d AND $depth <= %d)",
-		name, radius, depth)
+		"SELECT sum(popularity) FROM (TRAVERSE both() FROM (SELECT FROM `Vertex` WHERE name = %s) WHILE $depth <= %d AND $depth <= %d STRATEGY BREADTH_FIRST)",
+		quoteSQLString(name), radius, depth)
 	return utils.ExecuteQuery(query)
 }
 
 // 17. calculates popularity on the shortest path between two given nodes with a maximum depth
-func task17(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+func (orientEngine) task17(sourceName, targetName string, depth int) (utils.ResultSet, error) {
 	query := fmt.Sprintf(
-		"SELECT sum(popularity) FROM (SELECT expand(path) FROM (SELECT shortestPath((SELECT FROM `Vertex` WHERE name = '%s'), (SELECT FROM `Vertex` WHERE name = '%s'), {maxDepth: %d}) AS path) UNWIND path)",
-		sourceName, targetName, depth)
+		"SELECT sum(popularity) FROM (SELECT expand(path) FROM (SELECT shortestPath((SELECT FROM `Vertex` WHERE name = %s), (SELECT FROM `Vertex` WHERE name = %s), {maxDepth: %d}) AS path) UNWIND path)",
+		quoteSQLString(sourceName), quoteSQLString(targetName), depth)
 	return utils.ExecuteQuery(query)
 }
 
-// 18. finds the directed path with the greatest total popularity between two given nodes (sourceName -> targetName)
-func task18(sourceName, targetName string) (utils.ResultSet, error) {
-	query := fmt.Sprintf(
-		"SELECT expand(path) FROM (SELECT shortestPath((SELECT FROM `Vertex` WHERE name = \"%s\"), (SELECT FROM `Vertex` WHERE name = \"%s\")) AS path) ORDER BY popularity DESC UNWIND path",
-		sourceName, targetName)
-	return utils.ExecuteQuery(query)
+// 18. finds the directed path with the greatest total popularity between two given nodes (sourceName -> targetName).
+// SQL has no way to search for it, so the neighborhood of sourceName is fetched and searched locally.
+func (orientEngine) task18(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	sub, err := fetchSubgraph(sourceName, graph.Out, depth)
+	if err != nil {
+		return utils.ResultSet{}, err
+	}
+	return (&memoryEngine{g: sub}).task18(sourceName, targetName, depth)
 }
@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"context"
+	"dbcli/graph"
+	"dbcli/utils"
+	"fmt"
+	"math/rand/v2"
+)
+
+// memoryEngine answers tasks natively in Go over an in-memory graph.
+// Mutating tasks (12, 13) only change the loaded graph, not the database.
+type memoryEngine struct {
+	g *graph.Graph
+}
+
+// vertexRecord mirrors the fields of a Vertex document returned by OrientDB
+func (e *memoryEngine) vertexRecord(v int32) map[string]interface{} {
+	return map[string]interface{}{
+		"@class":     "Vertex",
+		"name":       e.g.Name(v),
+		"popularity": e.g.Popularity(v),
+	}
+}
+
+// vertexResult wraps a list of vertices as a result set
+func (e *memoryEngine) vertexResult(vertices []int32) utils.ResultSet {
+	result := utils.ResultSet{Result: make([]map[string]interface{}, 0, len(vertices))}
+	for _, v := range vertices {
+		result.Result = append(result.Result, e.vertexRecord(v))
+	}
+	return result
+}
+
+// valueResult wraps a single projection as a result set
+func valueResult(field string, value interface{}) utils.ResultSet {
+	return utils.ResultSet{Result: []map[string]interface{}{{field: value}}}
+}
+
+// twoHops collects the neighbors of all neighbors of v, keeping duplicates
+// the same way OrientDB flattens in().in()
+func (e *memoryEngine) twoHops(v int32, dir graph.Direction) []int32 {
+	var result []int32
+	e.g.Neighbors(v, dir, func(u int32) {
+		e.g.Neighbors(u, dir, func(w int32) {
+			result = append(result, w)
+		})
+	})
+	return result
+}
+
+func (e *memoryEngine) suggest(name string) ([]string, error) {
+	if _, ok := e.g.Lookup(name); ok {
+		return nil, nil
+	}
+	return suggestNames(e.g, name, suggestionLimit), nil
+}
+
+func (e *memoryEngine) sampleNames(n int, rng *rand.Rand) ([]string, error) {
+	if e.g.NumVertices() == 0 {
+		return nil, nil
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = e.g.Name(int32(rng.IntN(e.g.NumVertices())))
+	}
+	return names, nil
+}
+
+func (e *memoryEngine) task1(name string) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return utils.ResultSet{}, nil
+	}
+	return e.vertexResult(e.g.Out(v)), nil
+}
+
+func (e *memoryEngine) task2(name string) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return utils.ResultSet{}, nil
+	}
+	return valueResult("out().size()", e.g.OutDegree(v)), nil
+}
+
+func (e *memoryEngine) task3(name string) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return utils.ResultSet{}, nil
+	}
+	return e.vertexResult(e.twoHops(v, graph.Out)), nil
+}
+
+func (e *memoryEngine) task4(name string) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return utils.ResultSet{}, nil
+	}
+	return e.vertexResult(e.g.In(v)), nil
+}
+
+func (e *memoryEngine) task5(name string) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return utils.ResultSet{}, nil
+	}
+	return valueResult("in().size()", e.g.InDegree(v)), nil
+}
+
+func (e *memoryEngine) task6(name string) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return utils.ResultSet{}, nil
+	}
+	return e.vertexResult(e.twoHops(v, graph.In)), nil
+}
+
+func (e *memoryEngine) task7() (utils.ResultSet, error) {
+	// Names are unique, so every vertex has a distinct name
+	return valueResult("count(distinct(name))", e.g.NumVertices()), nil
+}
+
+// roots returns vertices without parents
+func (e *memoryEngine) roots() []int32 {
+	var roots []int32
+	for v := int32(0); v < int32(e.g.NumVertices()); v++ {
+		if e.g.InDegree(v) == 0 {
+			roots = append(roots, v)
+		}
+	}
+	return roots
+}
+
+func (e *memoryEngine) task8() (utils.ResultSet, error) {
+	return e.vertexResult(e.roots()), nil
+}
+
+func (e *memoryEngine) task9() (utils.ResultSet, error) {
+	return valueResult("count(*)", len(e.roots())), nil
+}
+
+// verticesWithOutDegree returns vertices whose out-degree equals degree
+func (e *memoryEngine) verticesWithOutDegree(degree int) []int32 {
+	var result []int32
+	for v := int32(0); v < int32(e.g.NumVertices()); v++ {
+		if e.g.OutDegree(v) == degree {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (e *memoryEngine) task10() (utils.ResultSet, error) {
+	if e.g.NumVertices() == 0 {
+		return utils.ResultSet{}, nil
+	}
+	maxDegree := 0
+	for v := int32(0); v < int32(e.g.NumVertices()); v++ {
+		if d := e.g.OutDegree(v); d > maxDegree {
+			maxDegree = d
+		}
+	}
+	return e.vertexResult(e.verticesWithOutDegree(maxDegree)), nil
+}
+
+func (e *memoryEngine) task11() (utils.ResultSet, error) {
+	minDegree := 0
+	for v := int32(0); v < int32(e.g.NumVertices()); v++ {
+		if d := e.g.OutDegree(v); d > 0 && (minDegree == 0 || d < minDegree) {
+			minDegree = d
+		}
+	}
+	if minDegree == 0 {
+		return utils.ResultSet{}, nil
+	}
+	return e.vertexResult(e.verticesWithOutDegree(minDegree)), nil
+}
+
+func (e *memoryEngine) task12(oldName, newName string) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(oldName)
+	if !ok {
+		return valueResult("count", 0), nil
+	}
+	if err := e.g.Rename(v, newName); err != nil {
+		return utils.ResultSet{}, err
+	}
+	return valueResult("count", 1), nil
+}
+
+func (e *memoryEngine) task13(name string, popularity int) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return valueResult("count", 0), nil
+	}
+	e.g.SetPopularity(v, int64(popularity))
+	return valueResult("count", 1), nil
+}
+
+// reached returns the vertices of a traversal, in visiting order
+func reached(visits []graph.Visit) []int32 {
+	result := make([]int32, len(visits))
+	for i, visit := range visits {
+		result[i] = visit.Vertex
+	}
+	return result
+}
+
+// reachableAvoiding runs the traversal shared by tasks 14 and 15. An unknown
+// target excludes nothing, like the name <> condition in the SQL version.
+func (e *memoryEngine) reachableAvoiding(sourceName, targetName string, depth int) []int32 {
+	src, ok := e.g.Lookup(sourceName)
+	if !ok {
+		return nil
+	}
+	target, ok := e.g.Lookup(targetName)
+	if !ok {
+		target = -1
+	}
+	return reached(e.g.ReachableAvoiding(src, graph.Out, depth, target))
+}
+
+func (e *memoryEngine) task14(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	return e.vertexResult(e.reachableAvoiding(sourceName, targetName, depth)), nil
+}
+
+func (e *memoryEngine) task15(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	return valueResult("count(*)", len(e.reachableAvoiding(sourceName, targetName, depth))), nil
+}
+
+// popularitySum sums the popularity of the given vertices
+func (e *memoryEngine) popularitySum(vertices []int32) int64 {
+	var sum int64
+	for _, v := range vertices {
+		sum += e.g.Popularity(v)
+	}
+	return sum
+}
+
+func (e *memoryEngine) task16(name string, radius int, depth int) (utils.ResultSet, error) {
+	v, ok := e.g.Lookup(name)
+	if !ok {
+		return utils.ResultSet{}, nil
+	}
+	limit := radius
+	if depth < limit {
+		limit = depth
+	}
+	return valueResult("sum(popularity)", e.popularitySum(reached(e.g.BFS(v, graph.Both, limit)))), nil
+}
+
+func (e *memoryEngine) task17(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	src, okSrc := e.g.Lookup(sourceName)
+	dst, okDst := e.g.Lookup(targetName)
+	if !okSrc || !okDst {
+		return utils.ResultSet{}, nil
+	}
+	path := e.g.ShortestPath(src, dst, graph.Both, depth)
+	if path == nil {
+		return utils.ResultSet{}, nil
+	}
+	return valueResult("sum(popularity)", e.popularitySum(path)), nil
+}
+
+func (e *memoryEngine) task18(sourceName, targetName string, depth int) (utils.ResultSet, error) {
+	src, okSrc := e.g.Lookup(sourceName)
+	dst, okDst := e.g.Lookup(targetName)
+	if !okSrc || !okDst {
+		return utils.ResultSet{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+	search, err := e.g.MaxPopularityPath(ctx, src, dst, depth, searchMaxExpanded)
+	if err != nil {
+		return utils.ResultSet{}, fmt.Errorf("%w (best popularity so far: %d over %d paths)", err, search.Popularity, search.Paths)
+	}
+	if search.Path == nil {
+		return utils.ResultSet{}, nil
+	}
+
+	return utils.ResultSet{Result: []map[string]interface{}{{
+		"path":       e.vertexResult(search.Path).Result,
+		"popularity": search.Popularity,
+		"paths":      search.Paths,
+		"expanded":   search.Expanded,
+	}}}, nil
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fixtureEdges is a small graph where e can only be reached through target.
+// A depth-first walk from root meets d at depth 3 through a and c before
+// its edge to root, so with a depth limit of 3 it never expands d to f.
+var fixtureEdges = [][2]string{
+	{"root", "a"}, {"root", "b"},
+	{"a", "c"}, {"a", "target"},
+	{"b", "c"},
+	{"c", "d"},
+	{"d", "root"}, {"d", "f"},
+	{"target", "e"},
+}
+
+// fixturePopularity gives every vertex of fixtureEdges a distinct
+// popularity and adds orphan, which has no edges
+var fixturePopularity = map[string]int{
+	"root": 1, "a": 2, "b": 3, "c": 4, "d": 5, "target": 6, "e": 7, "orphan": 8, "f": 9,
+}
+
+// newFixtureEngine returns a memory engine over the fixture graph. Every cmd
+// test builds the fixture here, so the engines and the OrientDB stand-in
+// seeded by seedFixture hold the same graph.
+func newFixtureEngine() *memoryEngine {
+	vertices := make(map[string]struct{}, len(fixturePopularity))
+	for name := range fixturePopularity {
+		vertices[name] = struct{}{}
+	}
+	return &memoryEngine{g: graph.New(fixturePopularity, vertices, fixtureEdges)}
+}
+
+func TestMemoryTask14And15(t *testing.T) {
+	tests := []struct {
+		source, target string
+		depth          int
+		want           []string
+	}{
+		{"root", "target", 1, []string{"a", "b", "root"}},
+		{"root", "target", 3, []string{"a", "b", "c", "d", "root"}},
+		{"root", "c", 3, []string{"a", "b", "e", "root", "target"}},
+		{"root", "missing", 2, []string{"a", "b", "c", "root", "target"}},
+		{"target", "target", 3, nil},
+		{"missing", "target", 3, nil},
+	}
+
+	e := newFixtureEngine()
+	for _, tt := range tests {
+		result, err := e.task14(tt.source, tt.target, tt.depth)
+		if err != nil {
+			t.Fatalf("task14(%s, %s, %d): %v", tt.source, tt.target, tt.depth, err)
+		}
+		var got []string
+		for _, record := range result.Result {
+			got = append(got, record["name"].(string))
+		}
+		sort.Strings(got)
+		if len(got) != len(tt.want) {
+			t.Errorf("task14(%s, %s, %d) = %v, want %v", tt.source, tt.target, tt.depth, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("task14(%s, %s, %d) = %v, want %v", tt.source, tt.target, tt.depth, got, tt.want)
+				break
+			}
+		}
+
+		result, err = e.task15(tt.source, tt.target, tt.depth)
+		if err != nil {
+			t.Fatalf("task15(%s, %s, %d): %v", tt.source, tt.target, tt.depth, err)
+		}
+		if count := result.Result[0]["count(*)"]; count != len(tt.want) {
+			t.Errorf("task15(%s, %s, %d) = %v, want %d", tt.source, tt.target, tt.depth, count, len(tt.want))
+		}
+	}
+}
+
+func TestEmptyResult(t *testing.T) {
+	e := newFixtureEngine()
+	for args, want := range map[string]bool{
+		"1 root": false, "1 missing": true,
+		"2 root": false, "2 missing": true,
+		"15 root target 2": false, "15 missing target 2": true,
+		"16 root 1 1": false, "16 missing 1 1": true,
+	} {
+		fields := strings.Fields(args)
+		result, err := runTask(e, fields[0], fields[1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := emptyResult(result); got != want {
+			t.Errorf("emptyResult(task %s) = %v, want %v", args, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	treeDepth       int
+	treeDirection   string
+	treeMaxChildren int
+	treeASCII       bool
+	treeExport      string
+)
+
+// treeBranches are the prefixes drawn in front of a node: branch, last
+// branch, continuation line and blank
+type treeBranches struct {
+	branch, last, line, blank string
+}
+
+var (
+	unicodeBranches = treeBranches{"├── ", "└── ", "│   ", "    "}
+	asciiBranches   = treeBranches{"|-- ", "`-- ", "|   ", "    "}
+)
+
+// treeCmd renders the neighborhood of a category as a tree
+var treeCmd = &cobra.Command{
+	Use:   "tree [name]",
+	Short: "Render the subcategories (or parent categories) of a category as a tree",
+	Long: `Render the categories under (--direction down) or above (--direction up) a
+category as an indented tree with popularity, most popular first. A category
+already on the path is marked as a cycle, and one already expanded elsewhere
+is shown once more without its children. --export writes the drawn subgraph
+as DOT (.dot) or GraphML (.graphml).`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		var dir graph.Direction
+		switch treeDirection {
+		case "down":
+			dir = graph.Out
+		case "up":
+			dir = graph.In
+		default:
+			log.Fatalf("Unknown direction %q, expected down or up", treeDirection)
+		}
+
+		var g *graph.Graph
+		var err error
+		if engineName == engineOrientDB {
+			g, err = fetchSubgraph(name, dir, treeDepth)
+		} else {
+			g, err = loadGraph()
+		}
+		if err != nil {
+			log.Fatalf("Failed to load graph: %v", err)
+		}
+		root, ok := g.Lookup(name)
+		if !ok {
+			log.Fatalf("Vertex %q not found", name)
+		}
+
+		tree := g.Tree(root, dir, treeDepth, treeMaxChildren)
+		branches := unicodeBranches
+		if treeASCII {
+			branches = asciiBranches
+		}
+		writeTree(os.Stdout, g, tree, branches)
+
+		if treeExport != "" {
+			if err := exportGraph(treeSubgraph(g, tree, dir), treeExport); err != nil {
+				log.Fatalf("Failed to export tree: %v", err)
+			}
+			log.Printf("Wrote tree to %s", treeExport)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+	addGraphFlags(treeCmd)
+	treeCmd.Flags().IntVar(&treeDepth, "depth", 2, "levels to show, -1 for no limit")
+	treeCmd.Flags().StringVar(&treeDirection, "direction", "down", "down to subcategories or up to parent categories")
+	treeCmd.Flags().IntVar(&treeMaxChildren, "max-children", 10, "children shown per node before \"+N more\", 0 for all")
+	treeCmd.Flags().BoolVar(&treeASCII, "ascii", false, "draw with ASCII instead of Unicode box characters")
+	treeCmd.Flags().StringVar(&treeExport, "export", "", "also write the drawn subgraph to a .dot or .graphml file")
+}
+
+// writeTree prints the tree with one node per line
+func writeTree(w io.Writer, g *graph.Graph, root *graph.TreeNode, branches treeBranches) {
+	fmt.Fprintln(w, treeLabel(g, root))
+	var walk func(node *graph.TreeNode, prefix string)
+	walk = func(node *graph.TreeNode, prefix string) {
+		for i, child := range node.Children {
+			last := i == len(node.Children)-1 && node.Hidden == 0
+			branch, next := branches.branch, branches.line
+			if last {
+				branch, next = branches.last, branches.blank
+			}
+			fmt.Fprintf(w, "%s%s%s\n", prefix, branch, treeLabel(g, child))
+			walk(child, prefix+next)
+		}
+		if node.Hidden > 0 {
+			fmt.Fprintf(w, "%s%s+%d more\n", prefix, branches.last, node.Hidden)
+		}
+	}
+	walk(root, "")
+}
+
+// treeLabel renders a node as "name (popularity)" with its marks
+func treeLabel(g *graph.Graph, node *graph.TreeNode) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d)", g.Name(node.Vertex), g.Popularity(node.Vertex))
+	switch {
+	case node.Cycle:
+		b.WriteString(" [cycle]")
+	case node.Repeated:
+		b.WriteString(" [seen above]")
+	}
+	return b.String()
+}
+
+// treeSubgraph returns the vertices and edges drawn in the tree, with edges
+// in their original direction
+func treeSubgraph(g *graph.Graph, root *graph.TreeNode, dir graph.Direction) *graph.Graph {
+	popularityMap := map[string]int{g.Name(root.Vertex): int(g.Popularity(root.Vertex))}
+	vertices := map[string]struct{}{g.Name(root.Vertex): {}}
+	var edgePairs [][2]string
+	root.Walk(func(parent, child *graph.TreeNode) {
+		from, to := g.Name(parent.Vertex), g.Name(child.Vertex)
+		vertices[to] = struct{}{}
+		popularityMap[to] = int(g.Popularity(child.Vertex))
+		if dir == graph.In {
+			from, to = to, from
+		}
+		edgePairs = append(edgePairs, [2]string{from, to})
+	})
+	return graph.New(popularityMap, vertices, edgePairs)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"dbcli/graph"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// propertyNamePattern restricts written property names to plain identifiers
+var propertyNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedProperties may not be overwritten by computed values
+var reservedProperties = map[string]bool{"name": true, "popularity": true, "in": true, "out": true}
+
+// vertexProperty is a computed value to store on every Vertex record
+type vertexProperty struct {
+	Name string
+	// Type is the OrientDB property type, e.g. INTEGER or DOUBLE
+	Type string
+	// Value returns the SQL literal for a vertex
+	Value func(v int32) string
+}
+
+// writeVertexProperty stores a single computed value on every Vertex record
+func writeVertexProperty(g *graph.Graph, property, propertyType string, value func(v int32) string) error {
+	return writeVertexProperties(g, []vertexProperty{{Name: property, Type: propertyType, Value: value}})
+}
+
+// writeVertexProperties stores computed values on every Vertex record. It
+// declares the properties, indexes them so tasks can sort and filter on them,
+// and sends one UPDATE per vertex in batched SQL scripts the same way the
+// import inserts edges.
+func writeVertexProperties(g *graph.Graph, properties []vertexProperty) error {
+	props := make(map[string]map[string]string, len(properties))
+	for _, property := range properties {
+		if !propertyNamePattern.MatchString(property.Name) || reservedProperties[property.Name] {
+			return fmt.Errorf("invalid property name %q", property.Name)
+		}
+		props[property.Name] = map[string]string{"propertyType": property.Type}
+	}
+	if err := createProperties("Vertex", props); err != nil {
+		log.Printf("Warning: could not create properties on Vertex: %v", err)
+	}
+	for _, property := range properties {
+		if err := executeSQLCommand(fmt.Sprintf("CREATE INDEX `Vertex.%s` NOTUNIQUE", property.Name)); err != nil {
+			log.Printf("Warning: could not create index on Vertex.%s: %v", property.Name, err)
+		}
+	}
+
+	vertexRIDMap, err := fetchAllVertexRIDs()
+	if err != nil {
+		return err
+	}
+
+	updateChan := make(chan string)
+	errChan := make(chan error, workers)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scriptLines := []string{"BEGIN;"}
+			for update := range updateChan {
+				scriptLines = append(scriptLines, update)
+				if len(scriptLines) > 10000 {
+					scriptLines = append(scriptLines, "COMMIT;")
+					op := BatchOperation{Type: "script", Language: "sql", Script: scriptLines}
+					if err := sendBatchRequest([]BatchOperation{op}, false, "writeback"); err != nil {
+						errChan <- err
+						// keep draining so the producer does not block
+						for range updateChan {
+						}
+						return
+					}
+					scriptLines = []string{"BEGIN;"}
+				}
+			}
+			if len(scriptLines) > 1 {
+				scriptLines = append(scriptLines, "COMMIT;")
+				op := BatchOperation{Type: "script", Language: "sql", Script: scriptLines}
+				if err := sendBatchRequest([]BatchOperation{op}, false, "writeback"); err != nil {
+					errChan <- err
+				}
+			}
+		}()
+	}
+
+	skipped := 0
+	for v := 0; v < g.NumVertices(); v++ {
+		rid, ok := vertexRIDMap[g.Name(int32(v))]
+		if !ok {
+			skipped++
+			continue
+		}
+		assignments := make([]string, len(properties))
+		for i, property := range properties {
+			assignments[i] = fmt.Sprintf("%s = %s", property.Name, property.Value(int32(v)))
+		}
+		updateChan <- fmt.Sprintf("UPDATE %s SET %s;", rid, strings.Join(assignments, ", "))
+	}
+	close(updateChan)
+	wg.Wait()
+	close(errChan)
+	// even a partial write makes cached results stale
+	invalidateCaches()
+
+	for err := range errChan {
+		if err != nil {
+			return fmt.Errorf("failed to update vertices: %w", err)
+		}
+	}
+	if skipped > 0 {
+		log.Printf("Warning: %d vertices are not in the database and were skipped", skipped)
+	}
+	log.Printf("Updated %d vertices", g.NumVertices()-skipped)
+	return nil
+}
@@ -0,0 +1,324 @@
+// Package datagen generates synthetic category taxonomies with popularity
+// values, written in the format of popularity_iw.csv and taxonomy_iw.csv.
+// The output depends only on the options, so a seed reproduces a dataset.
+package datagen
+
+import (
+	"dbcli/importer"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Popularity distributions
+const (
+	Zipf    = "zipf"
+	Uniform = "uniform"
+)
+
+// Options describe the dataset to generate
+type Options struct {
+	Vertices int
+	// AvgOutDegree is the number of edges per vertex
+	AvgOutDegree float64
+	// Roots is the number of categories without parents; every other
+	// category can be reached from a root
+	Roots int
+	// CycleRate is the fraction of edges that point from a category back to
+	// one of its ancestors, each closing a cycle
+	CycleRate float64
+
+	// Popularity is Zipf or Uniform. With Zipf the popularity of the k-th
+	// most popular category is MaxPopularity / k^ZipfExponent; with Uniform
+	// it is drawn from 0 to MaxPopularity.
+	Popularity    string
+	ZipfExponent  float64
+	MaxPopularity int64
+
+	// RealisticNames makes up names like Rivers_of_Peru instead of
+	// numbering the categories
+	RealisticNames bool
+	// EdgeCaseRate is the fraction of names given quotes, an apostrophe,
+	// non-ASCII characters or a comma
+	EdgeCaseRate float64
+
+	Seed uint64
+}
+
+// Dataset is a generated taxonomy
+type Dataset struct {
+	Names      []string
+	Popularity []int64
+	// Listed tells which names are written to the popularity file. Names
+	// with a comma are left out, as the loader splits lines at the first
+	// comma; they only occur as the target of edges and load with
+	// popularity 0.
+	Listed []bool
+	// Edges are pairs of indexes into Names
+	Edges [][2]int32
+	// CycleEdges counts the edges pointing back to an ancestor
+	CycleEdges int
+	// EdgeCases counts the names with injected edge cases
+	EdgeCases int
+}
+
+// ParsePopularity parses a distribution given as "zipf:<exponent>" or
+// "uniform" and returns the distribution and the exponent
+func ParsePopularity(spec string) (string, float64, error) {
+	kind, param, hasParam := strings.Cut(spec, ":")
+	switch {
+	case kind == Uniform && !hasParam:
+		return Uniform, 0, nil
+	case kind == Zipf && hasParam:
+		exponent, err := strconv.ParseFloat(param, 64)
+		if err != nil || exponent <= 0 {
+			return "", 0, fmt.Errorf("invalid zipf exponent %q, expected a positive number", param)
+		}
+		return Zipf, exponent, nil
+	}
+	return "", 0, fmt.Errorf("invalid popularity distribution %q, expected zipf:<exponent> or uniform", spec)
+}
+
+// Generate builds a dataset. Categories 0 to Roots-1 are the roots; every
+// other category gets a parent among the categories before it, so the tree
+// edges reach it from a root. The remaining edges also point forward,
+// except for the cycle edges, which point back to an ancestor.
+func Generate(opts Options) (*Dataset, error) {
+	n := opts.Vertices
+	if n < 1 {
+		return nil, errors.New("at least one vertex is needed")
+	}
+	if opts.Roots < 1 || opts.Roots > n {
+		return nil, fmt.Errorf("roots must be between 1 and the number of vertices (%d)", n)
+	}
+	if opts.CycleRate < 0 || opts.CycleRate > 1 || opts.EdgeCaseRate < 0 || opts.EdgeCaseRate > 1 {
+		return nil, errors.New("cycle and edge case rates must be between 0 and 1")
+	}
+	if opts.MaxPopularity < 0 {
+		return nil, errors.New("max popularity must not be negative")
+	}
+	edges := int(math.Round(float64(n) * opts.AvgOutDegree))
+	treeEdges := n - opts.Roots
+	if edges < treeEdges {
+		return nil, fmt.Errorf("an average out-degree of at least %.4f is needed to give every category except the %d roots a parent", float64(treeEdges)/float64(n), opts.Roots)
+	}
+	cycleEdges := int(math.Round(float64(edges) * opts.CycleRate))
+	if cycleEdges > edges-treeEdges {
+		return nil, fmt.Errorf("cycle rate too high: only %d of the %d edges are not needed to connect the categories", edges-treeEdges, edges)
+	}
+	if edges > 0 && treeEdges == 0 {
+		return nil, errors.New("every category is a root, so there can be no edges")
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	d := &Dataset{Edges: make([][2]int32, 0, edges)}
+	seen := make(map[[2]int32]struct{}, edges)
+	add := func(from, to int32) bool {
+		if from == to {
+			return false
+		}
+		if _, ok := seen[[2]int32{from, to}]; ok {
+			return false
+		}
+		seen[[2]int32{from, to}] = struct{}{}
+		d.Edges = append(d.Edges, [2]int32{from, to})
+		return true
+	}
+
+	parent := make([]int32, n)
+	for v := range parent {
+		parent[v] = -1
+	}
+	for v := opts.Roots; v < n; v++ {
+		parent[v] = int32(rng.IntN(v))
+		add(parent[v], int32(v))
+	}
+
+	// random edges may repeat existing ones, so give up after many attempts
+	maxAttempts := 100*edges + 1000
+	var ancestors []int32
+	for attempts := 0; d.CycleEdges < cycleEdges; attempts++ {
+		if attempts == maxAttempts {
+			return nil, fmt.Errorf("could only place %d of %d cycle edges, the tree is too shallow", d.CycleEdges, cycleEdges)
+		}
+		v := int32(opts.Roots + rng.IntN(treeEdges))
+		// an edge to a root would give it a parent
+		ancestors = ancestors[:0]
+		for a := parent[v]; a >= int32(opts.Roots); a = parent[a] {
+			ancestors = append(ancestors, a)
+		}
+		if len(ancestors) > 0 && add(v, ancestors[rng.IntN(len(ancestors))]) {
+			d.CycleEdges++
+		}
+	}
+	for attempts := 0; len(d.Edges) < edges; attempts++ {
+		if attempts == maxAttempts {
+			return nil, fmt.Errorf("could only place %d of %d edges, there are too few vertices", len(d.Edges), edges)
+		}
+		to := opts.Roots + rng.IntN(treeEdges)
+		add(int32(rng.IntN(to)), int32(to))
+	}
+
+	d.Popularity = popularity(rng, n, opts)
+	d.names(rng, opts)
+	return d, nil
+}
+
+// popularity draws the popularity of n categories
+func popularity(rng *rand.Rand, n int, opts Options) []int64 {
+	values := make([]int64, n)
+	if opts.Popularity == Uniform {
+		for v := range values {
+			values[v] = rng.Int64N(opts.MaxPopularity + 1)
+		}
+		return values
+	}
+	for v, rank := range rng.Perm(n) {
+		values[v] = int64(float64(opts.MaxPopularity) / math.Pow(float64(rank+1), opts.ZipfExponent))
+	}
+	return values
+}
+
+// names names every category, making sure names are unique
+func (d *Dataset) names(rng *rand.Rand, opts Options) {
+	n := len(d.Popularity)
+	outDegree := make([]int, n)
+	for _, e := range d.Edges {
+		outDegree[e[0]]++
+	}
+
+	d.Names = make([]string, n)
+	d.Listed = make([]bool, n)
+	used := make(map[string]int, n)
+	for v := range d.Names {
+		name := fmt.Sprintf("Category_%d", v)
+		if opts.RealisticNames {
+			name = realisticName(rng)
+		}
+		if used[name]++; used[name] > 1 {
+			// made up names repeat; a number in parentheses tells them apart
+			name = fmt.Sprintf("%s_(%d)", name, used[name])
+		}
+		d.Listed[v] = true
+		if rng.Float64() < opts.EdgeCaseRate {
+			// a comma is only possible in a name never written first on a
+			// line: a category without children and not a root
+			leaf := outDegree[v] == 0 && v >= opts.Roots
+			var comma bool
+			name, comma = edgeCase(rng, name, leaf)
+			d.Listed[v] = !comma
+			d.EdgeCases++
+		}
+		d.Names[v] = name
+	}
+}
+
+// Words of the made up names
+var (
+	plurals = []string{
+		"cities", "rivers", "albums", "songs", "films", "novels", "bridges", "museums",
+		"universities", "companies", "churches", "mountains", "lakes", "airports",
+		"festivals", "newspapers", "political_parties", "football_clubs", "video_games",
+		"television_series", "buildings", "islands", "villages", "railway_stations", "species",
+	}
+	adjectives = []string{
+		"planned", "former", "defunct", "ancient", "medieval", "fictional", "historic",
+		"modern", "protected", "abandoned", "independent", "populated",
+	}
+	places = []string{
+		"Peru", "Poland", "Canada", "Kenya", "Japan", "Norway", "Brazil", "Egypt", "Chile",
+		"India", "Portugal", "Vietnam", "Scotland", "Texas", "Bavaria", "Ontario",
+		"Greece", "Morocco", "Iceland", "Mongolia",
+	}
+	attributes = []string{
+		"country", "city", "year", "century", "genre", "decade", "language", "region",
+		"type", "continent", "period", "location",
+	}
+)
+
+// realisticName makes up a category name in the style of Wikipedia
+func realisticName(rng *rand.Rand) string {
+	pick := func(words []string) string { return words[rng.IntN(len(words))] }
+	var name string
+	switch rng.IntN(7) {
+	case 0:
+		name = pick(plurals) + "_by_" + pick(attributes)
+	case 1:
+		name = pick(plurals) + "_of_" + pick(places)
+	case 2:
+		name = pick(plurals) + "_in_" + pick(places)
+	case 3:
+		name = "people_from_" + pick(places)
+	case 4:
+		name = strconv.Itoa(1800+10*rng.IntN(23)) + "s_" + pick(plurals)
+	case 5:
+		name = pick(adjectives) + "_" + pick(plurals)
+	default:
+		name = pick(adjectives) + "_" + pick(plurals) + "_of_" + pick(places)
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// nonASCII are suffixes with characters outside ASCII
+var nonASCII = []string{"Zürich", "Łódź", "São_Paulo", "東京", "Ελλάδα", "Москва", "🎵_music"}
+
+// edgeCase changes name to contain quotes, an apostrophe, non-ASCII
+// characters or, if comma is allowed, a comma, and reports whether it added
+// a comma. Names never start or end with a double quote, which the loader
+// strips.
+func edgeCase(rng *rand.Rand, name string, comma bool) (string, bool) {
+	kinds := 3
+	if comma {
+		kinds = 4
+	}
+	switch rng.IntN(kinds) {
+	case 0:
+		return `The_"` + name + `"_collection`, false
+	case 1:
+		return name + "'s_works", false
+	case 2:
+		return name + "_" + nonASCII[rng.IntN(len(nonASCII))], false
+	default:
+		return name + ",_miscellaneous", true
+	}
+}
+
+// Unlisted counts the names left out of the popularity file
+func (d *Dataset) Unlisted() int {
+	n := 0
+	for _, listed := range d.Listed {
+		if !listed {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes popularity_iw.csv and taxonomy_iw.csv to dir, creating it if
+// needed
+func (d *Dataset) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	names := make([]string, 0, len(d.Names))
+	popularity := make([]int64, 0, len(d.Names))
+	for v, name := range d.Names {
+		if d.Listed[v] {
+			names = append(names, name)
+			popularity = append(popularity, d.Popularity[v])
+		}
+	}
+	if err := importer.WritePopularity(filepath.Join(dir, "popularity_iw.csv"), names, popularity); err != nil {
+		return err
+	}
+	pairs := make([][2]string, len(d.Edges))
+	for i, e := range d.Edges {
+		pairs[i] = [2]string{d.Names[e[0]], d.Names[e[1]]}
+	}
+	return importer.WriteEdges(filepath.Join(dir, "taxonomy_iw.csv"), pairs)
+}
@@ -0,0 +1,191 @@
+package datagen
+
+import (
+	"dbcli/graph"
+	"dbcli/importer"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// defaults are the options most tests start from
+var defaults = Options{
+	Vertices:      500,
+	AvgOutDegree:  2.5,
+	Roots:         3,
+	Popularity:    Zipf,
+	ZipfExponent:  1,
+	MaxPopularity: 1000,
+	Seed:          1,
+}
+
+// generate runs Generate and fails the test on errors
+func generate(t *testing.T, opts Options) *Dataset {
+	t.Helper()
+	d, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestShape(t *testing.T) {
+	for _, cycleRate := range []float64{0, 0.1} {
+		opts := defaults
+		opts.CycleRate = cycleRate
+		d := generate(t, opts)
+
+		if want := 1250; len(d.Edges) != want {
+			t.Errorf("cycle rate %v: %d edges, want %d", cycleRate, len(d.Edges), want)
+		}
+		if want := int(cycleRate * 1250); d.CycleEdges != want {
+			t.Errorf("cycle rate %v: %d cycle edges, want %d", cycleRate, d.CycleEdges, want)
+		}
+		hasParent := make([]bool, opts.Vertices)
+		backward := 0
+		for i, e := range d.Edges {
+			if e[0] == e[1] || slices.Contains(d.Edges[:i], e) {
+				t.Fatalf("cycle rate %v: edge %v repeated or a self-loop", cycleRate, e)
+			}
+			hasParent[e[1]] = true
+			if e[0] > e[1] {
+				backward++
+			}
+		}
+		if backward != d.CycleEdges {
+			t.Errorf("cycle rate %v: %d backward edges, want %d", cycleRate, backward, d.CycleEdges)
+		}
+		for v, ok := range hasParent {
+			if ok == (v < opts.Roots) {
+				t.Errorf("cycle rate %v: vertex %d has a parent: %v", cycleRate, v, ok)
+			}
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	opts := defaults
+	opts.CycleRate = 0.05
+	opts.RealisticNames = true
+	opts.EdgeCaseRate = 0.2
+	a, b := generate(t, opts), generate(t, opts)
+	if !slices.Equal(a.Names, b.Names) || !slices.Equal(a.Popularity, b.Popularity) || !slices.Equal(a.Edges, b.Edges) {
+		t.Error("the same seed gave different datasets")
+	}
+	opts.Seed = 2
+	if c := generate(t, opts); slices.Equal(a.Edges, c.Edges) {
+		t.Error("different seeds gave the same edges")
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	opts := defaults
+	opts.RealisticNames = true
+	opts.EdgeCaseRate = 0.3
+	d := generate(t, opts)
+	dir := filepath.Join(t.TempDir(), "data")
+	if err := d.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	popularity, _, err := importer.LoadPopularity(filepath.Join(dir, "popularity_iw.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edges, err := importer.LoadEdges(filepath.Join(dir, "taxonomy_iw.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var comma, quote, unicode bool
+	for v, name := range d.Names {
+		comma = comma || strings.Contains(name, ",")
+		quote = quote || strings.Contains(name, `"`)
+		unicode = unicode || strings.ContainsFunc(name, func(r rune) bool { return r > 127 })
+		got, ok := popularity[name]
+		if ok != d.Listed[v] || ok && int64(got) != d.Popularity[v] {
+			t.Errorf("popularity of %q = %d, %v, want %d, %v", name, got, ok, d.Popularity[v], d.Listed[v])
+		}
+	}
+	if !comma || !quote || !unicode {
+		t.Errorf("edge cases with a comma: %v, quotes: %v, unicode: %v, want all", comma, quote, unicode)
+	}
+	if len(popularity) == len(d.Names) {
+		t.Error("names with a comma were written to the popularity file")
+	}
+	if unlisted := d.Unlisted(); unlisted != len(d.Names)-len(popularity) {
+		t.Errorf("%d names unlisted, want %d", unlisted, len(d.Names)-len(popularity))
+	}
+	if len(edges) != len(d.Edges) {
+		t.Fatalf("%d edges loaded, want %d", len(edges), len(d.Edges))
+	}
+	for i, e := range d.Edges {
+		if want := [2]string{d.Names[e[0]], d.Names[e[1]]}; edges[i] != want {
+			t.Errorf("edge %d = %v, want %v", i, edges[i], want)
+		}
+	}
+
+	// without cycle edges the graph is acyclic
+	g, err := graph.LoadCSV(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, count := g.StronglyConnectedComponents(); count != opts.Vertices {
+		t.Errorf("%d strongly connected components, want %d", count, opts.Vertices)
+	}
+}
+
+func TestPopularity(t *testing.T) {
+	d := generate(t, defaults)
+	sorted := slices.Clone(d.Popularity)
+	slices.Sort(sorted)
+	slices.Reverse(sorted)
+	// the k-th most popular category has 1000 / k
+	for k, want := range []int64{1000, 500, 333, 250} {
+		if sorted[k] != want {
+			t.Errorf("popularity of rank %d = %d, want %d", k+1, sorted[k], want)
+		}
+	}
+
+	opts := defaults
+	opts.Popularity = Uniform
+	for _, p := range generate(t, opts).Popularity {
+		if p < 0 || p > opts.MaxPopularity {
+			t.Fatalf("uniform popularity %d out of range", p)
+		}
+	}
+}
+
+func TestParsePopularity(t *testing.T) {
+	if dist, exponent, err := ParsePopularity("zipf:1.2"); err != nil || dist != Zipf || exponent != 1.2 {
+		t.Errorf("zipf:1.2 = %s, %v, %v", dist, exponent, err)
+	}
+	if dist, _, err := ParsePopularity("uniform"); err != nil || dist != Uniform {
+		t.Errorf("uniform = %s, %v", dist, err)
+	}
+	for _, spec := range []string{"zipf", "zipf:0", "zipf:x", "uniform:1", "normal"} {
+		if _, _, err := ParsePopularity(spec); err == nil {
+			t.Errorf("%s parsed", spec)
+		}
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	tests := map[string]func(*Options){
+		"no vertices":       func(o *Options) { o.Vertices = 0 },
+		"no roots":          func(o *Options) { o.Roots = 0 },
+		"too many roots":    func(o *Options) { o.Roots = 501 },
+		"low degree":        func(o *Options) { o.AvgOutDegree = 0.5 },
+		"high cycle rate":   func(o *Options) { o.CycleRate = 0.9 },
+		"negative rate":     func(o *Options) { o.EdgeCaseRate = -0.1 },
+		"dense":             func(o *Options) { o.Vertices, o.Roots, o.AvgOutDegree = 20, 1, 15 },
+		"edges among roots": func(o *Options) { o.Roots = 500 },
+	}
+	for name, change := range tests {
+		opts := defaults
+		change(&opts)
+		if _, err := Generate(opts); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Aggregates holds per-vertex hierarchy values
+type Aggregates struct {
+	// Level is the smallest number of edges from a root. Roots are vertices
+	// without parents and, so that cycles get a level too, every vertex of a
+	// cycle that nothing outside the cycle points to.
+	Level []int32
+	// Descendants counts the vertices reachable from each vertex, the vertex
+	// itself excluded even when it lies on a cycle. Every descendant is
+	// counted once however many paths lead to it.
+	Descendants []int64
+	// DescendantPopularity sums the popularity of those descendants
+	DescendantPopularity []int64
+}
+
+// condensation is the DAG of strongly connected components
+type condensation struct {
+	comp       []int32
+	size       []int64
+	popularity []int64
+	offsets    []int32
+	succ       []int32
+}
+
+// condense builds the component DAG with deduplicated successor lists
+func (g *Graph) condense() condensation {
+	comp, count := g.StronglyConnectedComponents()
+	c := condensation{
+		comp:       comp,
+		size:       make([]int64, count),
+		popularity: make([]int64, count),
+	}
+	var src, dst []int32
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		c.size[comp[v]]++
+		c.popularity[comp[v]] += g.popularity[v]
+		for _, u := range g.Out(v) {
+			if comp[u] != comp[v] {
+				src = append(src, comp[v])
+				dst = append(dst, comp[u])
+			}
+		}
+	}
+	offsets, succ := buildCSR(count, src, dst)
+	// adjacency lists are sorted, so duplicates are adjacent
+	c.offsets = make([]int32, count+1)
+	c.succ = succ[:0]
+	for x := 0; x < count; x++ {
+		c.offsets[x] = int32(len(c.succ))
+		list := succ[offsets[x]:offsets[x+1]]
+		for i, y := range list {
+			if i == 0 || y != list[i-1] {
+				c.succ = append(c.succ, y)
+			}
+		}
+	}
+	c.offsets[count] = int32(len(c.succ))
+	return c
+}
+
+// ComputeAggregates computes levels and descendant totals. Descendant sets
+// are found by a search over the component DAG from every component, run in
+// parallel. To keep that affordable when one giant component is reachable
+// from most of the graph, the set below the largest component is computed
+// once and reused by every component that reaches it.
+func (g *Graph) ComputeAggregates() Aggregates {
+	n := g.NumVertices()
+	c := g.condense()
+	count := len(c.size)
+	agg := Aggregates{
+		Level:                g.levels(c.comp, count),
+		Descendants:          make([]int64, n),
+		DescendantPopularity: make([]int64, n),
+	}
+	if n == 0 {
+		return agg
+	}
+
+	hub := int32(0)
+	for x := range c.size {
+		if c.size[x] > c.size[hub] {
+			hub = int32(x)
+		}
+	}
+	// belowHub marks the components reachable from the hub; the set is closed,
+	// so a search may skip them once it knows the hub is reachable
+	belowHub := make([]bool, count)
+	var hubCount, hubPopularity int64
+	queue := []int32{hub}
+	for head := 0; head < len(queue); head++ {
+		for _, y := range c.succ[c.offsets[queue[head]]:c.offsets[queue[head]+1]] {
+			if !belowHub[y] {
+				belowHub[y] = true
+				hubCount += c.size[y]
+				hubPopularity += c.popularity[y]
+				queue = append(queue, y)
+			}
+		}
+	}
+	// ids are in reverse topological order, so successors are settled first
+	reachesHub := make([]bool, count)
+	for x := int32(0); x < int32(count); x++ {
+		for _, y := range c.succ[c.offsets[x]:c.offsets[x+1]] {
+			if y == hub || reachesHub[y] {
+				reachesHub[x] = true
+				break
+			}
+		}
+	}
+
+	compCount := make([]int64, count)
+	compPopularity := make([]int64, count)
+	compCount[hub], compPopularity[hub] = hubCount, hubPopularity
+
+	jobs := make(chan int32, 1024)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen := make([]int32, count)
+			stamp := int32(0)
+			var queue []int32
+			for x := range jobs {
+				stamp++
+				skip := reachesHub[x]
+				var total, popularity int64
+				if skip {
+					total, popularity = c.size[hub]+hubCount, c.popularity[hub]+hubPopularity
+				}
+				queue = append(queue[:0], x)
+				seen[x] = stamp
+				for head := 0; head < len(queue); head++ {
+					for _, y := range c.succ[c.offsets[queue[head]]:c.offsets[queue[head]+1]] {
+						if seen[y] == stamp || (skip && (y == hub || belowHub[y])) {
+							continue
+						}
+						seen[y] = stamp
+						total += c.size[y]
+						popularity += c.popularity[y]
+						queue = append(queue, y)
+					}
+				}
+				compCount[x], compPopularity[x] = total, popularity
+			}
+		}()
+	}
+	for x := int32(0); x < int32(count); x++ {
+		if x != hub {
+			jobs <- x
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// members of a component reach each other but not themselves
+	for v := 0; v < n; v++ {
+		x := c.comp[v]
+		agg.Descendants[v] = compCount[x] + c.size[x] - 1
+		agg.DescendantPopularity[v] = compPopularity[x] + c.popularity[x] - g.popularity[v]
+	}
+	return agg
+}
+
+// levels runs a multi-source BFS from the roots described on Aggregates.Level
+func (g *Graph) levels(comp []int32, count int) []int32 {
+	n := g.NumVertices()
+	level := make([]int32, n)
+	for v := range level {
+		level[v] = -1
+	}
+	hasEntry := make([]bool, count)
+	for v := int32(0); v < int32(n); v++ {
+		for _, u := range g.Out(v) {
+			if comp[u] != comp[v] {
+				hasEntry[comp[u]] = true
+			}
+		}
+	}
+
+	var queue []int32
+	for v := int32(0); v < int32(n); v++ {
+		if !hasEntry[comp[v]] {
+			level[v] = 0
+			queue = append(queue, v)
+		}
+	}
+	for head := 0; head < len(queue); head++ {
+		v := queue[head]
+		for _, u := range g.Out(v) {
+			if level[u] < 0 {
+				level[u] = level[v] + 1
+				queue = append(queue, u)
+			}
+		}
+	}
+	return level
+}
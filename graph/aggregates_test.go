@@ -0,0 +1,46 @@
+package graph
+
+import "testing"
+
+func TestComputeAggregates(t *testing.T) {
+	// a diamond a -> b, c -> d feeding the cycle d <-> e
+	popularity := map[string]int{"a": 1, "b": 2, "c": 4, "d": 8, "e": 16}
+	vertices := make(map[string]struct{}, len(popularity))
+	for name := range popularity {
+		vertices[name] = struct{}{}
+	}
+	g := New(popularity, vertices, [][2]string{
+		{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}, {"d", "e"}, {"e", "d"},
+	})
+
+	agg := g.ComputeAggregates()
+	want := map[string][3]int64{
+		// level, descendants, descendant popularity
+		"a": {0, 4, 30},
+		"b": {1, 2, 24},
+		"c": {1, 2, 24},
+		"d": {2, 1, 16},
+		"e": {3, 1, 8},
+	}
+	for name, w := range want {
+		v := mustLookup(t, g, name)
+		got := [3]int64{int64(agg.Level[v]), agg.Descendants[v], agg.DescendantPopularity[v]}
+		if got != w {
+			t.Errorf("%s: got %v, want %v", name, got, w)
+		}
+	}
+}
+
+func TestLevelsOfRootlessCycle(t *testing.T) {
+	g := fixtureGraph(t)
+	agg := g.ComputeAggregates()
+	// root, a, b, c and d form a cycle nothing points into
+	for name, level := range map[string]int32{"root": 0, "d": 0, "target": 1, "e": 2, "g": 2} {
+		if got := agg.Level[mustLookup(t, g, name)]; got != level {
+			t.Errorf("level(%s) = %d, want %d", name, got, level)
+		}
+	}
+	if got := agg.Descendants[mustLookup(t, g, "root")]; got != 8 {
+		t.Errorf("descendants(root) = %d, want 8", got)
+	}
+}
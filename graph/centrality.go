@@ -0,0 +1,247 @@
+package graph
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+)
+
+// degree returns the number of neighbors of v in the given direction
+func (g *Graph) degree(v int32, dir Direction) int {
+	switch dir {
+	case Out:
+		return g.OutDegree(v)
+	case In:
+		return g.InDegree(v)
+	default:
+		return g.OutDegree(v) + g.InDegree(v)
+	}
+}
+
+// adjacent returns the neighbors of v in the given direction as up to two
+// slices, avoiding the callback of Neighbors in hot loops
+func (g *Graph) adjacent(v int32, dir Direction) ([]int32, []int32) {
+	switch dir {
+	case Out:
+		return g.Out(v), nil
+	case In:
+		return g.In(v), nil
+	default:
+		return g.Out(v), g.In(v)
+	}
+}
+
+// reverse returns the opposite direction; Both is its own reverse
+func reverse(dir Direction) Direction {
+	switch dir {
+	case Out:
+		return In
+	case In:
+		return Out
+	default:
+		return Both
+	}
+}
+
+// PageRank computes PageRank with rank flowing along dir, so with In every
+// category passes its rank on to its parents. Rank of vertices without
+// neighbors in that direction is spread evenly over all vertices. It stops
+// when the L1 change drops below tolerance or after maxIterations and
+// returns the scores (summing to 1) and the number of iterations run.
+func (g *Graph) PageRank(dir Direction, damping, tolerance float64, maxIterations int) ([]float64, int) {
+	scores, iterations, _ := g.PageRankContext(context.Background(), dir, damping, tolerance, maxIterations, nil)
+	return scores, iterations
+}
+
+// PageRankContext is PageRank that gives up with the context error when ctx
+// is done and calls progress, if not nil, after every iteration
+func (g *Graph) PageRankContext(ctx context.Context, dir Direction, damping, tolerance float64, maxIterations int, progress func(iteration int)) ([]float64, int, error) {
+	n := g.NumVertices()
+	if n == 0 {
+		return nil, 0, nil
+	}
+	rank := make([]float64, n)
+	next := make([]float64, n)
+	contrib := make([]float64, n)
+	for v := range rank {
+		rank[v] = 1 / float64(n)
+	}
+
+	// Pull form: a vertex collects the contributions of the vertices that
+	// point to it along dir, i.e. its neighbors in the reverse direction
+	back := reverse(dir)
+	iterations := 0
+	for iterations < maxIterations {
+		if err := ctx.Err(); err != nil {
+			return nil, iterations, err
+		}
+		iterations++
+		dangling := 0.0
+		for v := range rank {
+			deg := g.degree(int32(v), dir)
+			if deg == 0 {
+				dangling += rank[v]
+				contrib[v] = 0
+				continue
+			}
+			contrib[v] = damping * rank[v] / float64(deg)
+		}
+
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		change := 0.0
+		for u := range next {
+			sum := base
+			first, second := g.adjacent(int32(u), back)
+			for _, v := range first {
+				sum += contrib[v]
+			}
+			for _, v := range second {
+				sum += contrib[v]
+			}
+			next[u] = sum
+			change += math.Abs(sum - rank[u])
+		}
+		rank, next = next, rank
+		if progress != nil {
+			progress(iterations)
+		}
+		if change < tolerance {
+			break
+		}
+	}
+	return rank, iterations, nil
+}
+
+// sampleSources picks k distinct vertices, or all of them if k <= 0 or k >= n
+func sampleSources(n, k int, seed uint64) []int32 {
+	if k <= 0 || k >= n {
+		sources := make([]int32, n)
+		for v := range sources {
+			sources[v] = int32(v)
+		}
+		return sources
+	}
+	rng := rand.New(rand.NewPCG(seed, seed))
+	sources := make([]int32, k)
+	for i, v := range rng.Perm(n)[:k] {
+		sources[i] = int32(v)
+	}
+	return sources
+}
+
+// Betweenness estimates betweenness centrality with Brandes' algorithm run
+// from a random sample of source vertices, following edges along dir. The
+// result is scaled by n/samples so it approximates the exact value; with
+// samples <= 0 every vertex is a source and the result is exact. With Both
+// each undirected path is found from both of its ends, so the scores are
+// halved as in undirected betweenness.
+func (g *Graph) Betweenness(dir Direction, samples int, seed uint64) []float64 {
+	n := g.NumVertices()
+	score := make([]float64, n)
+	sources := sampleSources(n, samples, seed)
+
+	dist := make([]int32, n)
+	sigma := make([]float64, n)
+	delta := make([]float64, n)
+	for v := range dist {
+		dist[v] = -1
+	}
+	var stack []int32
+	back := reverse(dir)
+
+	for _, s := range sources {
+		stack = stack[:0]
+		dist[s] = 0
+		sigma[s] = 1
+		stack = append(stack, s)
+		// stack doubles as the BFS queue since vertices leave it in BFS order
+		for head := 0; head < len(stack); head++ {
+			v := stack[head]
+			first, second := g.adjacent(v, dir)
+			for _, neighbors := range [2][]int32{first, second} {
+				for _, w := range neighbors {
+					if dist[w] < 0 {
+						dist[w] = dist[v] + 1
+						stack = append(stack, w)
+					}
+					if dist[w] == dist[v]+1 {
+						sigma[w] += sigma[v]
+					}
+				}
+			}
+		}
+
+		for i := len(stack) - 1; i > 0; i-- {
+			w := stack[i]
+			coefficient := (1 + delta[w]) / sigma[w]
+			first, second := g.adjacent(w, back)
+			for _, neighbors := range [2][]int32{first, second} {
+				for _, v := range neighbors {
+					if dist[v] >= 0 && dist[v] == dist[w]-1 {
+						delta[v] += sigma[v] * coefficient
+					}
+				}
+			}
+			score[w] += delta[w]
+		}
+
+		for _, v := range stack {
+			dist[v] = -1
+			sigma[v] = 0
+			delta[v] = 0
+		}
+	}
+
+	scale := float64(n) / float64(len(sources))
+	if dir == Both {
+		scale /= 2
+	}
+	for v := range score {
+		score[v] *= scale
+	}
+	return score
+}
+
+// Harmonic estimates harmonic centrality: for every vertex, the sum of
+// 1/d(s, v) over sources s that reach it along dir. Sampling and scaling
+// work as in Betweenness.
+func (g *Graph) Harmonic(dir Direction, samples int, seed uint64) []float64 {
+	n := g.NumVertices()
+	score := make([]float64, n)
+	sources := sampleSources(n, samples, seed)
+
+	dist := make([]int32, n)
+	for v := range dist {
+		dist[v] = -1
+	}
+	var queue []int32
+
+	for _, s := range sources {
+		queue = append(queue[:0], s)
+		dist[s] = 0
+		for head := 0; head < len(queue); head++ {
+			v := queue[head]
+			if v != s {
+				score[v] += 1 / float64(dist[v])
+			}
+			first, second := g.adjacent(v, dir)
+			for _, neighbors := range [2][]int32{first, second} {
+				for _, w := range neighbors {
+					if dist[w] < 0 {
+						dist[w] = dist[v] + 1
+						queue = append(queue, w)
+					}
+				}
+			}
+		}
+		for _, v := range queue {
+			dist[v] = -1
+		}
+	}
+
+	scale := float64(n) / float64(len(sources))
+	for v := range score {
+		score[v] *= scale
+	}
+	return score
+}
@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPageRank(t *testing.T) {
+	g := fixtureGraph(t)
+	scores, iterations := g.PageRank(In, 0.85, 1e-12, 200)
+	if iterations >= 200 {
+		t.Errorf("PageRank did not converge")
+	}
+	sum := 0.0
+	for _, s := range scores {
+		sum += s
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("scores sum to %v, want 1", sum)
+	}
+	// every category below root eventually passes rank up to it
+	root := mustLookup(t, g, "root")
+	for v, s := range scores {
+		if int32(v) != root && s >= scores[root] {
+			t.Errorf("%s scored %v, not below root %v", g.Name(int32(v)), s, scores[root])
+		}
+	}
+}
+
+func TestPageRankContext(t *testing.T) {
+	g := fixtureGraph(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, iterations, err := g.PageRankContext(ctx, In, 0.85, 0, 100, func(iteration int) {
+		calls++
+		if iteration == 3 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if iterations != 3 || calls != 3 {
+		t.Errorf("ran %d iterations with %d progress calls, want 3", iterations, calls)
+	}
+}
+
+func TestBetweennessAndHarmonic(t *testing.T) {
+	// a -> b -> c, a -> d
+	vertices := map[string]struct{}{"a": {}, "b": {}, "c": {}, "d": {}}
+	g := New(map[string]int{}, vertices, [][2]string{{"a", "b"}, {"b", "c"}, {"a", "d"}})
+	a, b, c, d := mustLookup(t, g, "a"), mustLookup(t, g, "b"), mustLookup(t, g, "c"), mustLookup(t, g, "d")
+
+	betweenness := g.Betweenness(Out, 0, 0)
+	want := map[int32]float64{a: 0, b: 1, c: 0, d: 0}
+	for v, w := range want {
+		if betweenness[v] != w {
+			t.Errorf("betweenness(%s) = %v, want %v", g.Name(v), betweenness[v], w)
+		}
+	}
+
+	// undirected, d - a - b - c: a and b each lie on two paths
+	betweenness = g.Betweenness(Both, 0, 0)
+	want = map[int32]float64{a: 2, b: 2, c: 0, d: 0}
+	for v, w := range want {
+		if betweenness[v] != w {
+			t.Errorf("undirected betweenness(%s) = %v, want %v", g.Name(v), betweenness[v], w)
+		}
+	}
+
+	harmonic := g.Harmonic(Out, 0, 0)
+	want = map[int32]float64{a: 0, b: 1, c: 1 + 0.5, d: 1}
+	for v, w := range want {
+		if harmonic[v] != w {
+			t.Errorf("harmonic(%s) = %v, want %v", g.Name(v), harmonic[v], w)
+		}
+	}
+}
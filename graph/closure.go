@@ -0,0 +1,31 @@
+package graph
+
+// Level summarizes the vertices first reached at one depth of a traversal
+type Level struct {
+	Depth      int   `json:"depth"`
+	Count      int   `json:"count"`
+	Popularity int64 `json:"popularity"`
+}
+
+// Closure returns the vertices reachable from src following dir whose
+// shortest distance lies in [minDepth, maxDepth], together with per-depth
+// counts and summed popularity. A negative maxDepth means no limit. Each
+// vertex is counted once, at its shortest depth, even when cycles lead back
+// to it.
+func (g *Graph) Closure(src int32, dir Direction, minDepth, maxDepth int) ([]Visit, []Level) {
+	var visits []Visit
+	var levels []Level
+	for _, visit := range g.BFS(src, dir, maxDepth) {
+		if visit.Depth < minDepth {
+			continue
+		}
+		visits = append(visits, visit)
+		if len(levels) == 0 || levels[len(levels)-1].Depth != visit.Depth {
+			levels = append(levels, Level{Depth: visit.Depth})
+		}
+		level := &levels[len(levels)-1]
+		level.Count++
+		level.Popularity += g.popularity[visit.Vertex]
+	}
+	return visits, levels
+}
@@ -0,0 +1,88 @@
+package graph
+
+// WeaklyConnectedComponents labels every vertex with the id of its weakly
+// connected component (edges followed in both directions) and returns the
+// labels with the number of components. Ids are assigned in order of the
+// lowest vertex id in each component.
+func (g *Graph) WeaklyConnectedComponents() ([]int32, int) {
+	n := g.NumVertices()
+	comp := make([]int32, n)
+	for v := range comp {
+		comp[v] = -1
+	}
+	var queue []int32
+	count := 0
+	for s := range comp {
+		if comp[s] >= 0 {
+			continue
+		}
+		id := int32(count)
+		count++
+		comp[s] = id
+		queue = append(queue[:0], int32(s))
+		for head := 0; head < len(queue); head++ {
+			first, second := g.adjacent(queue[head], Both)
+			for _, neighbors := range [2][]int32{first, second} {
+				for _, u := range neighbors {
+					if comp[u] < 0 {
+						comp[u] = id
+						queue = append(queue, u)
+					}
+				}
+			}
+		}
+	}
+	return comp, count
+}
+
+// eccentricity runs a BFS from src along dir and returns the farthest vertex
+// reached and its distance. dist must hold -1 for every vertex and is
+// restored before returning.
+func (g *Graph) eccentricity(src int32, dir Direction, dist []int32, queue []int32) (int32, int, []int32) {
+	queue = append(queue[:0], src)
+	dist[src] = 0
+	farthest := src
+	for head := 0; head < len(queue); head++ {
+		v := queue[head]
+		if dist[v] > dist[farthest] {
+			farthest = v
+		}
+		first, second := g.adjacent(v, dir)
+		for _, neighbors := range [2][]int32{first, second} {
+			for _, u := range neighbors {
+				if dist[u] < 0 {
+					dist[u] = dist[v] + 1
+					queue = append(queue, u)
+				}
+			}
+		}
+	}
+	depth := int(dist[farthest])
+	for _, v := range queue {
+		dist[v] = -1
+	}
+	return farthest, depth, queue
+}
+
+// EstimateDiameter returns a lower bound on the diameter of the component
+// containing start, ignoring edge direction. It uses repeated double sweeps:
+// each BFS starts from the farthest vertex found by the previous one. The
+// bound is usually exact or close on sparse, tree-like graphs.
+func (g *Graph) EstimateDiameter(start int32, sweeps int) int {
+	dist := make([]int32, g.NumVertices())
+	for v := range dist {
+		dist[v] = -1
+	}
+	var queue []int32
+	best := 0
+	cur := start
+	for i := 0; i < max(sweeps, 1); i++ {
+		var depth int
+		cur, depth, queue = g.eccentricity(cur, Both, dist, queue)
+		if i > 0 && depth <= best {
+			break
+		}
+		best = max(best, depth)
+	}
+	return best
+}
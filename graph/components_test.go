@@ -0,0 +1,29 @@
+package graph
+
+import "testing"
+
+func TestWeaklyConnectedComponents(t *testing.T) {
+	vertices := map[string]struct{}{"a": {}, "b": {}, "c": {}, "d": {}, "e": {}, "lonely": {}}
+	g := New(map[string]int{}, vertices, [][2]string{{"a", "b"}, {"c", "b"}, {"d", "e"}})
+
+	comp, count := g.WeaklyConnectedComponents()
+	if count != 3 {
+		t.Fatalf("got %d components, want 3", count)
+	}
+	same := func(x, y string) bool {
+		return comp[mustLookup(t, g, x)] == comp[mustLookup(t, g, y)]
+	}
+	if !same("a", "c") || !same("d", "e") || same("a", "d") || same("a", "lonely") {
+		t.Errorf("unexpected labels %v", comp)
+	}
+}
+
+func TestEstimateDiameter(t *testing.T) {
+	// root -> x -> y -> z and root -> w: the longest undirected path is z..w
+	vertices := map[string]struct{}{"root": {}, "x": {}, "y": {}, "z": {}, "w": {}}
+	g := New(map[string]int{}, vertices, [][2]string{{"root", "x"}, {"x", "y"}, {"y", "z"}, {"root", "w"}})
+
+	if got := g.EstimateDiameter(mustLookup(t, g, "x"), 4); got != 4 {
+		t.Errorf("EstimateDiameter = %d, want 4", got)
+	}
+}
@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dotEscape escapes s for use inside a quoted DOT string
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Every node is labeled
+// with its name and popularity.
+func (g *Graph) WriteDOT(w io.Writer, title string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph \"%s\" {\n", dotEscape(title))
+	fmt.Fprintln(bw, "  node [shape=box];")
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		name := dotEscape(g.names[v])
+		fmt.Fprintf(bw, "  \"%s\" [label=\"%s\\n%d\"];\n", name, name, g.popularity[v])
+	}
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		for _, u := range g.Out(v) {
+			fmt.Fprintf(bw, "  \"%s\" -> \"%s\";\n", dotEscape(g.names[v]), dotEscape(g.names[u]))
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteGraphML writes the graph in GraphML format with popularity as a node
+// attribute
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	fmt.Fprintln(bw, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(bw, `  <key id="popularity" for="node" attr.name="popularity" attr.type="long"/>`)
+	fmt.Fprintln(bw, `  <graph edgedefault="directed">`)
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		fmt.Fprintf(bw, "    <node id=\"%s\"><data key=\"popularity\">%d</data></node>\n", escape(g.names[v]), g.popularity[v])
+	}
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		for _, u := range g.Out(v) {
+			fmt.Fprintf(bw, "    <edge source=\"%s\" target=\"%s\"/>\n", escape(g.names[v]), escape(g.names[u]))
+		}
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// exportFixture has names that need escaping in DOT and XML
+func exportFixture() *Graph {
+	vertices := map[string]struct{}{`Say_"hi"`: {}, "R&D_<labs>": {}, "plain": {}}
+	return New(map[string]int{"plain": 7}, vertices, [][2]string{{`Say_"hi"`, "R&D_<labs>"}, {`Say_"hi"`, "plain"}})
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportFixture().WriteDOT(&buf, "fixture"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`digraph "fixture" {`,
+		`"plain" [label="plain\n7"];`,
+		`"Say_\"hi\"" -> "R&D_<labs>";`,
+		`"Say_\"hi\"" -> "plain";`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output lacks %s:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "->"); n != 2 {
+		t.Errorf("DOT output has %d edges, want 2", n)
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportFixture().WriteGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<node id="plain"><data key="popularity">7</data></node>`,
+		`<edge source="Say_&#34;hi&#34;" target="R&amp;D_&lt;labs&gt;"/>`,
+		`<edge source="Say_&#34;hi&#34;" target="plain"/>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("GraphML output lacks %s:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "<edge "); n != 2 {
+		t.Errorf("GraphML output has %d edges, want 2", n)
+	}
+	if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+		t.Errorf("GraphML output is not well-formed XML: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Direction selects which adjacency a traversal follows
+type Direction int
+
+const (
+	// Out follows edges from parent to child (category -> subcategory)
+	Out Direction = iota
+	// In follows edges from child to parent
+	In
+	// Both ignores edge direction
+	Both
+)
+
+// Graph is a compact in-memory representation of the taxonomy.
+//
+// Vertex names are interned into a single table and addressed by int32 ids.
+// Edges are stored twice in CSR (compressed sparse row) form: once grouped by
+// source (forward) and once grouped by target (reverse), so that both out()
+// and in() are a slice lookup.
+type Graph struct {
+	names      []string
+	popularity []int64
+
+	// order holds vertex ids sorted by name, used for lookups and prefix scans
+	order []int32
+
+	outOffsets []int32
+	outEdges   []int32
+	inOffsets  []int32
+	inEdges    []int32
+}
+
+// New builds a graph from the data produced by importer.LoadPopularity and
+// importer.LoadEdges. Vertices missing from the popularity map get popularity 0,
+// matching what the OrientDB import stores.
+func New(popularityMap map[string]int, vertices map[string]struct{}, edgePairs [][2]string) *Graph {
+	names := make([]string, 0, len(vertices))
+	for name := range vertices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	index := make(map[string]int32, len(names))
+	popularity := make([]int64, len(names))
+	order := make([]int32, len(names))
+	for i, name := range names {
+		index[name] = int32(i)
+		popularity[i] = int64(popularityMap[name])
+		order[i] = int32(i)
+	}
+
+	from := make([]int32, 0, len(edgePairs))
+	to := make([]int32, 0, len(edgePairs))
+	for _, pair := range edgePairs {
+		f, okFrom := index[pair[0]]
+		t, okTo := index[pair[1]]
+		if !okFrom || !okTo {
+			continue
+		}
+		from = append(from, f)
+		to = append(to, t)
+	}
+
+	g := &Graph{
+		names:      names,
+		popularity: popularity,
+		order:      order,
+	}
+	g.outOffsets, g.outEdges = buildCSR(len(names), from, to)
+	g.inOffsets, g.inEdges = buildCSR(len(names), to, from)
+	return g
+}
+
+// buildCSR groups dst by src using a counting sort. Each adjacency list is
+// sorted by vertex id, so duplicate edges are adjacent.
+func buildCSR(n int, src, dst []int32) ([]int32, []int32) {
+	offsets := make([]int32, n+1)
+	for _, s := range src {
+		offsets[s+1]++
+	}
+	for i := 0; i < n; i++ {
+		offsets[i+1] += offsets[i]
+	}
+
+	edges := make([]int32, len(dst))
+	next := make([]int32, n)
+	copy(next, offsets[:n])
+	for i, s := range src {
+		edges[next[s]] = dst[i]
+		next[s]++
+	}
+	for i := 0; i < n; i++ {
+		slices.Sort(edges[offsets[i]:offsets[i+1]])
+	}
+	return offsets, edges
+}
+
+// NumVertices returns the number of vertices
+func (g *Graph) NumVertices() int {
+	return len(g.names)
+}
+
+// NumEdges returns the number of edges
+func (g *Graph) NumEdges() int {
+	return len(g.outEdges)
+}
+
+// Name returns the name of vertex v
+func (g *Graph) Name(v int32) string {
+	return g.names[v]
+}
+
+// Popularity returns the popularity of vertex v
+func (g *Graph) Popularity(v int32) int64 {
+	return g.popularity[v]
+}
+
+// Out returns the children of vertex v. The slice must not be modified.
+func (g *Graph) Out(v int32) []int32 {
+	return g.outEdges[g.outOffsets[v]:g.outOffsets[v+1]]
+}
+
+// In returns the parents of vertex v. The slice must not be modified.
+func (g *Graph) In(v int32) []int32 {
+	return g.inEdges[g.inOffsets[v]:g.inOffsets[v+1]]
+}
+
+// OutDegree returns the number of children of vertex v
+func (g *Graph) OutDegree(v int32) int {
+	return int(g.outOffsets[v+1] - g.outOffsets[v])
+}
+
+// InDegree returns the number of parents of vertex v
+func (g *Graph) InDegree(v int32) int {
+	return int(g.inOffsets[v+1] - g.inOffsets[v])
+}
+
+// Neighbors calls fn for every vertex adjacent to v in the given direction.
+// With Both, a vertex connected in both directions is reported twice.
+func (g *Graph) Neighbors(v int32, dir Direction, fn func(u int32)) {
+	if dir == Out || dir == Both {
+		for _, u := range g.Out(v) {
+			fn(u)
+		}
+	}
+	if dir == In || dir == Both {
+		for _, u := range g.In(v) {
+			fn(u)
+		}
+	}
+}
+
+// Lookup returns the id of the vertex with the given name
+func (g *Graph) Lookup(name string) (int32, bool) {
+	i := g.searchOrder(name)
+	if i < len(g.order) && g.names[g.order[i]] == name {
+		return g.order[i], true
+	}
+	return -1, false
+}
+
+// searchOrder returns the position in order of the first name >= name
+func (g *Graph) searchOrder(name string) int {
+	return sort.Search(len(g.order), func(i int) bool {
+		return g.names[g.order[i]] >= name
+	})
+}
+
+// Rename changes the name of vertex v. Names are unique, like the
+// Vertex.name index in OrientDB.
+func (g *Graph) Rename(v int32, newName string) error {
+	oldName := g.names[v]
+	if oldName == newName {
+		return nil
+	}
+	if _, exists := g.Lookup(newName); exists {
+		return fmt.Errorf("vertex with name %q already exists", newName)
+	}
+
+	// Move v within order to keep it sorted
+	pos := g.searchOrder(oldName)
+	g.order = append(g.order[:pos], g.order[pos+1:]...)
+	g.names[v] = newName
+	pos = g.searchOrder(newName)
+	g.order = append(g.order, 0)
+	copy(g.order[pos+1:], g.order[pos:])
+	g.order[pos] = v
+	return nil
+}
+
+// SetPopularity changes the popularity of vertex v
+func (g *Graph) SetPopularity(v int32, popularity int64) {
+	g.popularity[v] = popularity
+}
@@ -0,0 +1,38 @@
+package graph
+
+// Bucket counts values in the inclusive range [Min, Max]
+type Bucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// LogHistogram bins non-negative values into power-of-two buckets:
+// [0,0], [1,1], [2,3], [4,7], [8,15], ... Empty buckets are omitted.
+func LogHistogram(values []int) []Bucket {
+	var counts []int
+	for _, value := range values {
+		bin := 0
+		for v := value; v > 0; v >>= 1 {
+			bin++
+		}
+		for len(counts) <= bin {
+			counts = append(counts, 0)
+		}
+		counts[bin]++
+	}
+
+	var buckets []Bucket
+	for bin, count := range counts {
+		if count == 0 {
+			continue
+		}
+		bucket := Bucket{Count: count}
+		if bin > 0 {
+			bucket.Min = 1 << (bin - 1)
+			bucket.Max = 1<<bin - 1
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
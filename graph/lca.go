@@ -0,0 +1,94 @@
+package graph
+
+// CommonAncestor is a lowest common ancestor with its distance (in upward
+// hops) from each input vertex
+type CommonAncestor struct {
+	Vertex    int32
+	Distances []int
+}
+
+// TotalDistance sums the distances from all inputs
+func (ca CommonAncestor) TotalDistance() int {
+	total := 0
+	for _, d := range ca.Distances {
+		total += d
+	}
+	return total
+}
+
+// LowestCommonAncestors returns the common ancestors of inputs that have no
+// other common ancestor below them. An input counts as its own ancestor at
+// distance 0, so the LCA of a category and its subcategory is the category.
+//
+// With multiple inheritance there can be several LCAs. Within a cycle every
+// vertex is an ancestor of every other, so a cycle of common ancestors is
+// treated as one unit: its members are either all lowest or none are.
+func (g *Graph) LowestCommonAncestors(inputs []int32) []CommonAncestor {
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	// Upward distances from every input; common ancestors appear in all
+	distances := make([]map[int32]int, len(inputs))
+	for i, v := range inputs {
+		distances[i] = make(map[int32]int)
+		for _, visit := range g.BFS(v, In, -1) {
+			distances[i][visit.Vertex] = visit.Depth
+		}
+	}
+	var common []int32
+	for v := range distances[0] {
+		isCommon := true
+		for _, d := range distances[1:] {
+			if _, ok := d[v]; !ok {
+				isCommon = false
+				break
+			}
+		}
+		if isCommon {
+			common = append(common, v)
+		}
+	}
+
+	// Every vertex on a path between two common ancestors is itself a common
+	// ancestor, so it is enough to look at the subgraph they induce. A common
+	// ancestor is not lowest if it reaches another one in a different
+	// strongly connected component.
+	sub := g.Induced(common)
+	comp, _ := sub.StronglyConnectedComponents()
+	above := make([]bool, sub.NumVertices())
+	var queue []int32
+	for v := int32(0); v < int32(sub.NumVertices()); v++ {
+		for _, u := range sub.Out(v) {
+			if comp[u] != comp[v] && !above[v] {
+				above[v] = true
+				queue = append(queue, v)
+				break
+			}
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, u := range sub.In(v) {
+			if !above[u] {
+				above[u] = true
+				queue = append(queue, u)
+			}
+		}
+	}
+
+	var result []CommonAncestor
+	for _, v := range common {
+		s, _ := sub.Lookup(g.names[v])
+		if above[s] {
+			continue
+		}
+		ca := CommonAncestor{Vertex: v, Distances: make([]int, len(inputs))}
+		for i, d := range distances {
+			ca.Distances[i] = d[v]
+		}
+		result = append(result, ca)
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestLowestCommonAncestors(t *testing.T) {
+	// x and y share parents p1 and p2 (multiple inheritance), which both sit
+	// under top. q1 <-> q2 is a cycle above z and w.
+	edges := [][2]string{
+		{"top", "p1"}, {"top", "p2"},
+		{"p1", "x"}, {"p1", "y"},
+		{"p2", "x"}, {"p2", "y"},
+		{"top", "z"},
+		{"q1", "q2"}, {"q2", "q1"},
+		{"q1", "z"}, {"q2", "w"},
+		{"p1", "sub"},
+	}
+	vertices := make(map[string]struct{})
+	for _, e := range edges {
+		vertices[e[0]] = struct{}{}
+		vertices[e[1]] = struct{}{}
+	}
+	g := New(map[string]int{}, vertices, edges)
+
+	lcaNames := func(names ...string) []string {
+		inputs := make([]int32, len(names))
+		for i, name := range names {
+			inputs[i] = mustLookup(t, g, name)
+		}
+		var result []string
+		for _, ca := range g.LowestCommonAncestors(inputs) {
+			result = append(result, g.Name(ca.Vertex))
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	tests := []struct {
+		inputs []string
+		want   []string
+	}{
+		{[]string{"x", "y"}, []string{"p1", "p2"}},
+		{[]string{"x", "sub"}, []string{"p1"}},
+		{[]string{"x", "z"}, []string{"top"}},
+		{[]string{"z", "w"}, []string{"q1", "q2"}},
+		{[]string{"p1", "x"}, []string{"p1"}},
+		{[]string{"x", "w"}, nil},
+	}
+	for _, tt := range tests {
+		got := lcaNames(tt.inputs...)
+		if len(got) != len(tt.want) {
+			t.Errorf("LCA(%v) = %v, want %v", tt.inputs, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("LCA(%v) = %v, want %v", tt.inputs, got, tt.want)
+				break
+			}
+		}
+	}
+
+	ancestors := g.LowestCommonAncestors([]int32{mustLookup(t, g, "x"), mustLookup(t, g, "z")})
+	if len(ancestors) != 1 || ancestors[0].Distances[0] != 2 || ancestors[0].Distances[1] != 1 {
+		t.Errorf("LCA(x, z) distances = %v, want [2 1]", ancestors)
+	}
+}
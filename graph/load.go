@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"dbcli/importer"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LoadCSV builds a graph from popularity_iw.csv and taxonomy_iw.csv in dataDir,
+// the same files the import command reads
+func LoadCSV(dataDir string) (*Graph, error) {
+	popularityMap, popularityVertices, err := importer.LoadPopularity(filepath.Join(dataDir, "popularity_iw.csv"))
+	if err != nil {
+		return nil, err
+	}
+	taxonomyVertices, edgePairs, err := importer.LoadEdges(filepath.Join(dataDir, "taxonomy_iw.csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range popularityVertices {
+		taxonomyVertices[name] = struct{}{}
+	}
+	return New(popularityMap, taxonomyVertices, edgePairs), nil
+}
+
+// WriteCSV writes the graph as popularity_iw.csv and taxonomy_iw.csv into
+// dataDir, creating it if needed, so it can be read back by LoadCSV or imported
+func (g *Graph) WriteCSV(dataDir string) error {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := importer.WritePopularity(filepath.Join(dataDir, "popularity_iw.csv"), g.names, g.popularity); err != nil {
+		return err
+	}
+	edgePairs := make([][2]string, 0, g.NumEdges())
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		for _, u := range g.Out(v) {
+			edgePairs = append(edgePairs, [2]string{g.names[v], g.names[u]})
+		}
+	}
+	return importer.WriteEdges(filepath.Join(dataDir, "taxonomy_iw.csv"), edgePairs)
+}
@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrSearchExploded is returned when a path search exceeds its expansion limit
+var ErrSearchExploded = errors.New("search space exploded")
+
+// PathSearch is the outcome of MaxPopularityPath
+type PathSearch struct {
+	// Path is the best path found, from source to target; nil if none
+	Path []int32
+	// Popularity is the summed popularity of all vertices on Path
+	Popularity int64
+	// Paths is the number of complete source -> target paths evaluated
+	Paths int64
+	// Expanded is the number of partial paths extended by the search
+	Expanded int64
+}
+
+// MaxPopularityPath finds the simple directed path from src to dst with at most
+// maxDepth edges whose vertices have the greatest total popularity.
+//
+// It runs a depth-first search over simple paths (no vertex repeats, so cycles
+// are cut) with two prunings: vertices that cannot reach dst in the remaining
+// hops are skipped, and a branch is abandoned once even the most popular
+// remaining vertices could not beat the best path found so far.
+//
+// The search stops with ErrSearchExploded after maxExpanded expansions
+// (0 means no limit) or with the context error on timeout. In both cases the
+// best path found so far is returned alongside the error.
+func (g *Graph) MaxPopularityPath(ctx context.Context, src, dst int32, maxDepth int, maxExpanded int64) (PathSearch, error) {
+	var search PathSearch
+	if maxDepth < 0 {
+		return search, fmt.Errorf("max depth must not be negative")
+	}
+
+	// Hops needed from each vertex to dst, limited to maxDepth
+	distToDst := make(map[int32]int)
+	for _, visit := range g.BFS(dst, In, maxDepth) {
+		distToDst[visit.Vertex] = visit.Depth
+	}
+	if _, ok := distToDst[src]; !ok {
+		return search, nil
+	}
+
+	// bound[k] is the best popularity that k more vertices could add, taken
+	// from vertices that lie on some src -> dst path within maxDepth
+	var candidates []int64
+	for _, visit := range g.BFS(src, Out, maxDepth) {
+		if d, ok := distToDst[visit.Vertex]; ok && visit.Depth+d <= maxDepth && visit.Vertex != src {
+			candidates = append(candidates, g.popularity[visit.Vertex])
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i] > candidates[j] })
+	bound := make([]int64, maxDepth+1)
+	var prefix int64
+	for k := 1; k <= maxDepth; k++ {
+		if k <= len(candidates) {
+			prefix += candidates[k-1]
+		}
+		bound[k] = bound[k-1]
+		if prefix > bound[k] {
+			bound[k] = prefix
+		}
+	}
+
+	s := &pathSearcher{
+		g:           g,
+		ctx:         ctx,
+		dst:         dst,
+		maxDepth:    maxDepth,
+		maxExpanded: maxExpanded,
+		distToDst:   distToDst,
+		bound:       bound,
+		onPath:      map[int32]struct{}{src: {}},
+		path:        []int32{src},
+		best:        &search,
+	}
+	err := s.extend(src, g.popularity[src])
+	return search, err
+}
+
+// pathSearcher holds the state of one MaxPopularityPath call
+type pathSearcher struct {
+	g           *Graph
+	ctx         context.Context
+	dst         int32
+	maxDepth    int
+	maxExpanded int64
+	distToDst   map[int32]int
+	bound       []int64
+	onPath      map[int32]struct{}
+	path        []int32
+	best        *PathSearch
+}
+
+// extend tries every child of v, the last vertex of s.path, whose summed
+// popularity so far is sum
+func (s *pathSearcher) extend(v int32, sum int64) error {
+	if v == s.dst {
+		s.best.Paths++
+		if s.best.Path == nil || sum > s.best.Popularity {
+			s.best.Path = append([]int32(nil), s.path...)
+			s.best.Popularity = sum
+		}
+		return nil
+	}
+
+	s.best.Expanded++
+	if s.maxExpanded > 0 && s.best.Expanded > s.maxExpanded {
+		return fmt.Errorf("%w: more than %d partial paths expanded, lower the max depth", ErrSearchExploded, s.maxExpanded)
+	}
+	if s.best.Expanded%4096 == 0 {
+		if err := s.ctx.Err(); err != nil {
+			return fmt.Errorf("search stopped after %d expansions: %w", s.best.Expanded, err)
+		}
+	}
+
+	depth := len(s.path) - 1
+	remaining := s.maxDepth - depth
+	if s.best.Path != nil && sum+s.bound[remaining] <= s.best.Popularity {
+		return nil
+	}
+
+	children := s.g.Out(v)
+	for i, u := range children {
+		if i > 0 && children[i-1] == u {
+			// Parallel edges lead to the same path
+			continue
+		}
+		d, ok := s.distToDst[u]
+		if !ok || depth+1+d > s.maxDepth {
+			continue
+		}
+		if _, ok := s.onPath[u]; ok {
+			continue
+		}
+		s.onPath[u] = struct{}{}
+		s.path = append(s.path, u)
+		err := s.extend(u, sum+s.g.popularity[u])
+		s.path = s.path[:len(s.path)-1]
+		delete(s.onPath, u)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMaxPopularityPath(t *testing.T) {
+	// Two routes from s to t: a short unpopular one and a longer popular one,
+	// plus a cycle b -> c -> b that must not be walked twice
+	edges := [][2]string{
+		{"s", "a"}, {"a", "t"},
+		{"s", "b"}, {"b", "c"}, {"c", "b"}, {"c", "t"},
+	}
+	vertices := map[string]struct{}{"s": {}, "a": {}, "b": {}, "c": {}, "t": {}}
+	popularity := map[string]int{"s": 1, "a": 2, "b": 50, "c": 40, "t": 1}
+	g := New(popularity, vertices, edges)
+	s, tgt := mustLookup(t, g, "s"), mustLookup(t, g, "t")
+
+	search, err := g.MaxPopularityPath(context.Background(), s, tgt, 3, 0)
+	if err != nil {
+		t.Fatalf("MaxPopularityPath: %v", err)
+	}
+	if search.Popularity != 92 || len(search.Path) != 4 || g.Name(search.Path[1]) != "b" {
+		t.Errorf("depth 3: got path %v with popularity %d, want s -> b -> c -> t with 92", search.Path, search.Popularity)
+	}
+	if search.Paths != 2 {
+		t.Errorf("depth 3: explored %d paths, want 2", search.Paths)
+	}
+
+	search, err = g.MaxPopularityPath(context.Background(), s, tgt, 2, 0)
+	if err != nil {
+		t.Fatalf("MaxPopularityPath: %v", err)
+	}
+	if search.Popularity != 4 {
+		t.Errorf("depth 2: got popularity %d, want 4 (s -> a -> t)", search.Popularity)
+	}
+
+	if _, err := g.MaxPopularityPath(context.Background(), s, tgt, 3, 1); !errors.Is(err, ErrSearchExploded) {
+		t.Errorf("expansion limit: got error %v, want ErrSearchExploded", err)
+	}
+}
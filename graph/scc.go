@@ -0,0 +1,179 @@
+package graph
+
+// StronglyConnectedComponents labels every vertex with the id of its strongly
+// connected component using an iterative version of Tarjan's algorithm, so
+// deep graphs cannot overflow the stack. Components are numbered in reverse
+// topological order: every edge between two components goes from a higher
+// id to a lower one.
+func (g *Graph) StronglyConnectedComponents() (comp []int32, count int) {
+	n := len(g.names)
+	index := make([]int32, n) // discovery order + 1, 0 means unvisited
+	low := make([]int32, n)
+	onStack := make([]bool, n)
+	comp = make([]int32, n)
+	for i := range comp {
+		comp[i] = -1
+	}
+
+	type frame struct {
+		v    int32
+		next int
+	}
+	var stack []int32
+	var calls []frame
+	var counter int32
+
+	visit := func(v int32) {
+		counter++
+		index[v], low[v] = counter, counter
+		stack = append(stack, v)
+		onStack[v] = true
+		calls = append(calls, frame{v: v})
+	}
+
+	for root := int32(0); root < int32(n); root++ {
+		if index[root] != 0 {
+			continue
+		}
+		visit(root)
+		for len(calls) > 0 {
+			f := &calls[len(calls)-1]
+			v := f.v
+			out := g.Out(v)
+			if f.next < len(out) {
+				w := out[f.next]
+				f.next++
+				if index[w] == 0 {
+					visit(w)
+				} else if onStack[w] && index[w] < low[v] {
+					low[v] = index[w]
+				}
+				continue
+			}
+
+			if low[v] == index[v] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					comp[w] = int32(count)
+					if w == v {
+						break
+					}
+				}
+				count++
+			}
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				parent := calls[len(calls)-1].v
+				if low[v] < low[parent] {
+					low[parent] = low[v]
+				}
+			}
+		}
+	}
+	return comp, count
+}
+
+// HasSelfLoop reports whether v has an edge to itself
+func (g *Graph) HasSelfLoop(v int32) bool {
+	for _, u := range g.Out(v) {
+		if u == v {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedbackEdges returns a set of edges whose removal makes the graph acyclic.
+//
+// Candidates are the back edges of a depth-first search inside every
+// strongly connected component (self-loops included). With minimize set, each
+// candidate is then put back if doing so does not close a cycle, which leaves
+// an inclusion-minimal set: restoring any returned edge creates a cycle. The
+// minimization runs a search per candidate and can be slow on huge components.
+func (g *Graph) FeedbackEdges(comp []int32, minimize bool) [][2]int32 {
+	n := len(g.names)
+	color := make([]uint8, n) // 0 white, 1 on the DFS path, 2 finished
+	removed := make(map[[2]int32]struct{})
+	var candidates [][2]int32
+
+	type frame struct {
+		v    int32
+		next int
+	}
+	for root := int32(0); root < int32(n); root++ {
+		if color[root] != 0 {
+			continue
+		}
+		color[root] = 1
+		calls := []frame{{v: root}}
+		for len(calls) > 0 {
+			f := &calls[len(calls)-1]
+			out := g.Out(f.v)
+			if f.next < len(out) {
+				w := out[f.next]
+				f.next++
+				if comp[w] != comp[f.v] {
+					continue
+				}
+				edge := [2]int32{f.v, w}
+				switch color[w] {
+				case 0:
+					color[w] = 1
+					calls = append(calls, frame{v: w})
+				case 1:
+					if _, ok := removed[edge]; !ok {
+						removed[edge] = struct{}{}
+						candidates = append(candidates, edge)
+					}
+				}
+				continue
+			}
+			color[f.v] = 2
+			calls = calls[:len(calls)-1]
+		}
+	}
+
+	if !minimize {
+		return candidates
+	}
+
+	var result [][2]int32
+	for _, edge := range candidates {
+		// Restoring u -> v closes a cycle only if v still reaches u
+		if edge[0] != edge[1] && !g.reachesWithin(edge[1], edge[0], comp, removed) {
+			delete(removed, edge)
+			continue
+		}
+		result = append(result, edge)
+	}
+	return result
+}
+
+// reachesWithin reports whether dst is reachable from src using only edges
+// inside src's component that are not in removed
+func (g *Graph) reachesWithin(src, dst int32, comp []int32, removed map[[2]int32]struct{}) bool {
+	visited := map[int32]struct{}{src: {}}
+	queue := []int32{src}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range g.Out(v) {
+			if comp[w] != comp[src] {
+				continue
+			}
+			if _, ok := removed[[2]int32{v, w}]; ok {
+				continue
+			}
+			if w == dst {
+				return true
+			}
+			if _, ok := visited[w]; !ok {
+				visited[w] = struct{}{}
+				queue = append(queue, w)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package graph
+
+import "testing"
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	g := fixtureGraph(t)
+	comp, count := g.StronglyConnectedComponents()
+
+	// root -> a -> c -> d -> root and root -> b -> c form one component;
+	// every other vertex is alone
+	cycle := []string{"root", "a", "b", "c", "d"}
+	for _, name := range cycle[1:] {
+		if comp[mustLookup(t, g, name)] != comp[mustLookup(t, g, "root")] {
+			t.Errorf("%s is not in the same component as root", name)
+		}
+	}
+	if want := 1 + 4; count != want {
+		t.Errorf("got %d components, want %d", count, want)
+	}
+
+	// Edges between components go from higher to lower ids
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		for _, u := range g.Out(v) {
+			if comp[v] != comp[u] && comp[v] < comp[u] {
+				t.Errorf("edge %s -> %s goes from component %d to %d", g.Name(v), g.Name(u), comp[v], comp[u])
+			}
+		}
+	}
+}
+
+func TestFeedbackEdges(t *testing.T) {
+	edges := [][2]string{
+		{"a", "b"}, {"b", "c"}, {"c", "a"},
+		{"c", "d"}, {"d", "c"},
+		{"e", "e"},
+	}
+	vertices := map[string]struct{}{"a": {}, "b": {}, "c": {}, "d": {}, "e": {}}
+	g := New(map[string]int{}, vertices, edges)
+	comp, _ := g.StronglyConnectedComponents()
+
+	for _, minimize := range []bool{false, true} {
+		feedback := g.FeedbackEdges(comp, minimize)
+		removed := make(map[[2]int32]struct{})
+		for _, edge := range feedback {
+			removed[edge] = struct{}{}
+		}
+
+		// What is left must be acyclic: no component is bigger than one
+		// vertex and no self-loop survives
+		var kept [][2]string
+		for _, e := range edges {
+			edge := [2]int32{mustLookup(t, g, e[0]), mustLookup(t, g, e[1])}
+			if _, ok := removed[edge]; !ok {
+				kept = append(kept, e)
+			}
+		}
+		dag := New(map[string]int{}, vertices, kept)
+		_, count := dag.StronglyConnectedComponents()
+		if count != dag.NumVertices() {
+			t.Errorf("minimize=%v: graph still has cycles after removing %v", minimize, kept)
+		}
+		for v := int32(0); v < int32(dag.NumVertices()); v++ {
+			if dag.HasSelfLoop(v) {
+				t.Errorf("minimize=%v: self-loop on %s survived", minimize, dag.Name(v))
+			}
+		}
+		if minimize && len(feedback) != 3 {
+			t.Errorf("minimized feedback set has %d edges, want 3", len(feedback))
+		}
+	}
+}
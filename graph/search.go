@@ -0,0 +1,187 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Search modes
+const (
+	SearchAuto      = "auto"
+	SearchPrefix    = "prefix"
+	SearchSubstring = "substring"
+	SearchFuzzy     = "fuzzy"
+)
+
+// Match kinds, from best to worst
+const (
+	MatchExact     = "exact"
+	MatchPrefix    = "prefix"
+	MatchSubstring = "substring"
+	MatchFuzzy     = "fuzzy"
+)
+
+var matchRank = map[string]int{MatchExact: 0, MatchPrefix: 1, MatchSubstring: 2, MatchFuzzy: 3}
+
+// SearchOptions configures Search
+type SearchOptions struct {
+	// Mode is one of SearchAuto, SearchPrefix, SearchSubstring or SearchFuzzy.
+	// Auto tries all of them.
+	Mode          string
+	CaseSensitive bool
+	// MaxDistance is the largest edit distance accepted by fuzzy matching
+	MaxDistance int
+	// Limit caps the number of matches, 0 means no limit
+	Limit int
+}
+
+// Match is a vertex found by Search
+type Match struct {
+	Vertex   int32
+	Kind     string
+	Distance int
+}
+
+// NormalizeSearch turns free text into the shape of a category name:
+// surrounding spaces are dropped and inner spaces become underscores
+func NormalizeSearch(text string) string {
+	return strings.ReplaceAll(strings.TrimSpace(text), " ", "_")
+}
+
+// Search finds vertices whose names match text and ranks them by match quality
+// (exact, prefix, substring, then fuzzy by edit distance) and then by
+// popularity. Case-sensitive prefix search uses the sorted name index; every
+// other mode scans all names.
+func (g *Graph) Search(text string, opts SearchOptions) ([]Match, error) {
+	query := NormalizeSearch(text)
+	if query == "" {
+		return nil, fmt.Errorf("empty search text")
+	}
+	switch opts.Mode {
+	case SearchAuto, SearchPrefix, SearchSubstring, SearchFuzzy:
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", opts.Mode)
+	}
+
+	var matches []Match
+	if opts.Mode == SearchPrefix && opts.CaseSensitive {
+		for i := g.searchOrder(query); i < len(g.order); i++ {
+			v := g.order[i]
+			if !strings.HasPrefix(g.names[v], query) {
+				break
+			}
+			kind := MatchPrefix
+			if g.names[v] == query {
+				kind = MatchExact
+			}
+			matches = append(matches, Match{Vertex: v, Kind: kind})
+		}
+	} else {
+		if !opts.CaseSensitive {
+			query = strings.ToLower(query)
+		}
+		queryRunes := []rune(query)
+		var buf []int
+		for v, name := range g.names {
+			if !opts.CaseSensitive {
+				name = strings.ToLower(name)
+			}
+			if match, ok := matchName(name, query, queryRunes, opts, &buf); ok {
+				match.Vertex = int32(v)
+				matches = append(matches, match)
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if matchRank[a.Kind] != matchRank[b.Kind] {
+			return matchRank[a.Kind] < matchRank[b.Kind]
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if g.popularity[a.Vertex] != g.popularity[b.Vertex] {
+			return g.popularity[a.Vertex] > g.popularity[b.Vertex]
+		}
+		return g.names[a.Vertex] < g.names[b.Vertex]
+	})
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+	return matches, nil
+}
+
+// matchName checks a single (already case-folded) name against the query
+func matchName(name, query string, queryRunes []rune, opts SearchOptions, buf *[]int) (Match, bool) {
+	if name == query {
+		return Match{Kind: MatchExact}, true
+	}
+	if opts.Mode != SearchFuzzy {
+		if strings.HasPrefix(name, query) {
+			return Match{Kind: MatchPrefix}, true
+		}
+		if opts.Mode != SearchPrefix && strings.Contains(name, query) {
+			return Match{Kind: MatchSubstring}, true
+		}
+	}
+	if opts.Mode == SearchAuto || opts.Mode == SearchFuzzy {
+		if d, ok := boundedLevenshtein(name, queryRunes, opts.MaxDistance, buf); ok {
+			return Match{Kind: MatchFuzzy, Distance: d}, true
+		}
+	}
+	return Match{}, false
+}
+
+// boundedLevenshtein returns the edit distance between s and t if it is at
+// most k. It gives up early once every alignment exceeds k.
+func boundedLevenshtein(s string, t []rune, k int, buf *[]int) (int, bool) {
+	n := utf8.RuneCountInString(s)
+	if n-len(t) > k || len(t)-n > k {
+		return 0, false
+	}
+
+	// prev holds the previous DP row; it is reused between calls
+	if cap(*buf) < 2*(len(t)+1) {
+		*buf = make([]int, 2*(len(t)+1))
+	}
+	prev := (*buf)[:len(t)+1]
+	cur := (*buf)[len(t)+1 : 2*(len(t)+1)]
+	for j := range prev {
+		prev[j] = j
+	}
+
+	i := 0
+	for _, r := range s {
+		i++
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if t[j-1] == r {
+				cost = 0
+			}
+			best := prev[j-1] + cost
+			if prev[j]+1 < best {
+				best = prev[j] + 1
+			}
+			if cur[j-1]+1 < best {
+				best = cur[j-1] + 1
+			}
+			cur[j] = best
+			if best < rowMin {
+				rowMin = best
+			}
+		}
+		if rowMin > k {
+			return 0, false
+		}
+		prev, cur = cur, prev
+	}
+	if prev[len(t)] > k {
+		return 0, false
+	}
+	return prev[len(t)], true
+}
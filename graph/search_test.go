@@ -0,0 +1,109 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func searchFixture() *Graph {
+	popularity := map[string]int{
+		"Planned_cities":          50,
+		"Planned_communities":     80,
+		"Cities_in_Poland":        30,
+		"Former_planned_cities":   10,
+		"Plane_crashes":           5,
+		"planned_cities_(stubs)":  1,
+		"Unrelated_category_name": 99,
+	}
+	vertices := make(map[string]struct{}, len(popularity))
+	for name := range popularity {
+		vertices[name] = struct{}{}
+	}
+	return New(popularity, vertices, nil)
+}
+
+func TestSearch(t *testing.T) {
+	g := searchFixture()
+
+	tests := []struct {
+		name  string
+		text  string
+		opts  SearchOptions
+		names []string
+		kinds []string
+	}{
+		{
+			name:  "case-sensitive prefix uses sorted names",
+			text:  "Planned",
+			opts:  SearchOptions{Mode: SearchPrefix, CaseSensitive: true},
+			names: []string{"Planned_communities", "Planned_cities"},
+			kinds: []string{MatchPrefix, MatchPrefix},
+		},
+		{
+			name:  "case-insensitive prefix with spaces",
+			text:  "planned cit",
+			opts:  SearchOptions{Mode: SearchPrefix},
+			names: []string{"Planned_cities", "planned_cities_(stubs)"},
+			kinds: []string{MatchPrefix, MatchPrefix},
+		},
+		{
+			name:  "exact match ranks before substrings",
+			text:  "planned cities",
+			opts:  SearchOptions{Mode: SearchSubstring},
+			names: []string{"Planned_cities", "planned_cities_(stubs)", "Former_planned_cities"},
+			kinds: []string{MatchExact, MatchPrefix, MatchSubstring},
+		},
+		{
+			name:  "fuzzy within distance",
+			text:  "Planed_citis",
+			opts:  SearchOptions{Mode: SearchFuzzy, MaxDistance: 2},
+			names: []string{"Planned_cities"},
+			kinds: []string{MatchFuzzy},
+		},
+		{
+			name:  "limit",
+			text:  "cities",
+			opts:  SearchOptions{Mode: SearchAuto, Limit: 2},
+			names: []string{"Cities_in_Poland", "Planned_cities"},
+			kinds: []string{MatchPrefix, MatchSubstring},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := g.Search(tt.text, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names, kinds []string
+			for _, m := range matches {
+				names = append(names, g.Name(m.Vertex))
+				kinds = append(kinds, m.Kind)
+			}
+			if !reflect.DeepEqual(names, tt.names) || !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Errorf("got %v %v, want %v %v", names, kinds, tt.names, tt.kinds)
+			}
+		})
+	}
+}
+
+func TestBoundedLevenshtein(t *testing.T) {
+	var buf []int
+	tests := []struct {
+		s, t string
+		k    int
+		want int
+		ok   bool
+	}{
+		{"kitten", "sitting", 3, 3, true},
+		{"kitten", "sitting", 2, 0, false},
+		{"", "abc", 3, 3, true},
+		{"żółw", "zolw", 3, 3, true},
+		{"same", "same", 0, 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := boundedLevenshtein(tt.s, []rune(tt.t), tt.k, &buf)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("boundedLevenshtein(%q, %q, %d) = %d, %v, want %d, %v", tt.s, tt.t, tt.k, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Similarity metrics
+const (
+	Jaccard    = "jaccard"
+	Cosine     = "cosine"
+	AdamicAdar = "adamic-adar"
+)
+
+// SimilarityOptions configures Similar
+type SimilarityOptions struct {
+	// Dir selects the neighborhood compared: In for parents, Out for
+	// children, Both for either
+	Dir    Direction
+	Metric string
+	// Weighted weighs every shared neighbor by 1 + ln(1 + popularity)
+	// instead of counting it once
+	Weighted bool
+	// Limit caps the number of results, 0 means no limit
+	Limit int
+}
+
+// Similarity is a vertex scored against the query vertex
+type Similarity struct {
+	Vertex int32
+	Score  float64
+	// Shared is the number of neighbors both vertices have
+	Shared int
+}
+
+// neighborhood returns the distinct neighbors of v in dir, v itself excluded
+func (g *Graph) neighborhood(v int32, dir Direction) []int32 {
+	seen := make(map[int32]struct{})
+	var result []int32
+	g.Neighbors(v, dir, func(u int32) {
+		if _, ok := seen[u]; ok || u == v {
+			return
+		}
+		seen[u] = struct{}{}
+		result = append(result, u)
+	})
+	return result
+}
+
+// Similar scores every vertex that shares at least one neighbor with v and
+// returns them best first, ties broken by popularity. Only vertices sharing a
+// neighbor are considered, so the cost depends on the size of the two-hop
+// neighborhood rather than on the whole graph.
+func (g *Graph) Similar(v int32, opts SimilarityOptions) ([]Similarity, error) {
+	switch opts.Metric {
+	case Jaccard, Cosine, AdamicAdar:
+	default:
+		return nil, fmt.Errorf("unknown similarity metric %q, expected %s, %s or %s", opts.Metric, Jaccard, Cosine, AdamicAdar)
+	}
+	weight := func(x int32) float64 {
+		if opts.Weighted {
+			return 1 + math.Log1p(float64(g.popularity[x]))
+		}
+		return 1
+	}
+	// norm is the total weight (or squared weight for cosine) of a neighborhood
+	norm := func(u int32) float64 {
+		total := 0.0
+		for _, x := range g.neighborhood(u, opts.Dir) {
+			w := weight(x)
+			if opts.Metric == Cosine {
+				w *= w
+			}
+			total += w
+		}
+		return total
+	}
+
+	back := reverse(opts.Dir)
+	overlap := make(map[int32]float64)
+	shared := make(map[int32]int)
+	for _, x := range g.neighborhood(v, opts.Dir) {
+		// vertices having x as a neighbor, found by looking back from x
+		sharers := g.neighborhood(x, back)
+		w := weight(x)
+		switch opts.Metric {
+		case Cosine:
+			w *= w
+		case AdamicAdar:
+			if len(sharers) < 2 {
+				continue
+			}
+			w /= math.Log(float64(len(sharers)))
+		}
+		for _, y := range sharers {
+			if y != v {
+				overlap[y] += w
+				shared[y]++
+			}
+		}
+	}
+
+	result := make([]Similarity, 0, len(overlap))
+	vNorm := 0.0
+	if opts.Metric != AdamicAdar {
+		vNorm = norm(v)
+	}
+	for y, s := range overlap {
+		score := s
+		switch opts.Metric {
+		case Jaccard:
+			score = s / (vNorm + norm(y) - s)
+		case Cosine:
+			score = s / math.Sqrt(vNorm*norm(y))
+		}
+		result = append(result, Similarity{Vertex: y, Score: score, Shared: shared[y]})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if g.popularity[a.Vertex] != g.popularity[b.Vertex] {
+			return g.popularity[a.Vertex] > g.popularity[b.Vertex]
+		}
+		return g.names[a.Vertex] < g.names[b.Vertex]
+	})
+	if opts.Limit > 0 && len(result) > opts.Limit {
+		result = result[:opts.Limit]
+	}
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package graph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilar(t *testing.T) {
+	// x and y share both parents p and q; z shares only q, which has three children
+	vertices := map[string]struct{}{"p": {}, "q": {}, "x": {}, "y": {}, "z": {}}
+	g := New(map[string]int{}, vertices, [][2]string{
+		{"p", "x"}, {"q", "x"}, {"p", "y"}, {"q", "y"}, {"q", "z"},
+	})
+	x := mustLookup(t, g, "x")
+
+	tests := []struct {
+		metric string
+		want   map[string]float64
+	}{
+		{Jaccard, map[string]float64{"y": 1, "z": 0.5}},
+		{Cosine, map[string]float64{"y": 1, "z": 1 / math.Sqrt(2)}},
+		{AdamicAdar, map[string]float64{"y": 1/math.Log(2) + 1/math.Log(3), "z": 1 / math.Log(3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			similar, err := g.Similar(x, SimilarityOptions{Dir: In, Metric: tt.metric})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(similar) != len(tt.want) || g.Name(similar[0].Vertex) != "y" {
+				t.Fatalf("got %v, want y first of %d", similar, len(tt.want))
+			}
+			for _, s := range similar {
+				if want := tt.want[g.Name(s.Vertex)]; math.Abs(s.Score-want) > 1e-12 {
+					t.Errorf("%s scored %v, want %v", g.Name(s.Vertex), s.Score, want)
+				}
+			}
+		})
+	}
+}
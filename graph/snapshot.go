@@ -0,0 +1,331 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Snapshot file layout (all integers little-endian):
+//
+//	header      64 bytes, see SnapshotHeader
+//	nameOffsets (vertices+1) x uint32, offsets into the string table
+//	strings     stringBytes bytes, all names concatenated
+//	order       vertices x int32, vertex ids sorted by name
+//	popularity  vertices x int64
+//	outOffsets  (vertices+1) x int32
+//	outEdges    edges x int32, each adjacency list sorted by vertex id
+//	inOffsets   (vertices+1) x int32
+//	inEdges     edges x int32, each adjacency list sorted by vertex id
+//	checksum    uint64, CRC-64/ECMA of everything above
+const (
+	snapshotMagic = "DBCLISNP"
+	// version 2 sorts adjacency lists
+	snapshotVersion    = 2
+	snapshotHeaderSize = 64
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// ErrSnapshotCorrupt is returned when a snapshot fails validation
+var ErrSnapshotCorrupt = errors.New("snapshot is corrupt")
+
+// SnapshotHeader describes the contents of a snapshot file
+type SnapshotHeader struct {
+	Version     uint32
+	CreatedAt   time.Time
+	Vertices    uint64
+	Edges       uint64
+	StringBytes uint64
+	// Checksum is the stored trailer; it is only verified by LoadSnapshot
+	Checksum uint64
+}
+
+// snapshotSize returns the expected file size for a header. The counts
+// are bounded by parseSnapshotHeader, so the sum cannot overflow.
+func (h SnapshotHeader) snapshotSize() uint64 {
+	n, m := h.Vertices, h.Edges
+	return snapshotHeaderSize +
+		4*(n+1) + h.StringBytes + 4*n + 8*n +
+		2*(4*(n+1)+4*m) +
+		8
+}
+
+// WriteSnapshot writes the graph to path in the binary snapshot format. The
+// snapshot is written to a temporary file renamed over path when complete,
+// so a crash never leaves a truncated snapshot behind.
+func (g *Graph) WriteSnapshot(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := g.writeSnapshot(file); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+	return nil
+}
+
+// writeSnapshot writes the snapshot contents to file
+func (g *Graph) writeSnapshot(file *os.File) error {
+	hash := crc64.New(crcTable)
+	w := bufio.NewWriterSize(io.MultiWriter(file, hash), 1<<20)
+
+	var stringBytes uint64
+	for _, name := range g.names {
+		stringBytes += uint64(len(name))
+	}
+	if stringBytes > 1<<32-1 {
+		return fmt.Errorf("string table of %d bytes does not fit in a snapshot", stringBytes)
+	}
+
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic)
+	binary.LittleEndian.PutUint32(header[8:], snapshotVersion)
+	binary.LittleEndian.PutUint64(header[16:], uint64(time.Now().Unix()))
+	binary.LittleEndian.PutUint64(header[24:], uint64(len(g.names)))
+	binary.LittleEndian.PutUint64(header[32:], uint64(len(g.outEdges)))
+	binary.LittleEndian.PutUint64(header[40:], stringBytes)
+	w.Write(header)
+
+	var offset uint32
+	writeUint32(w, offset)
+	for _, name := range g.names {
+		offset += uint32(len(name))
+		writeUint32(w, offset)
+	}
+	for _, name := range g.names {
+		w.WriteString(name)
+	}
+	writeInt32s(w, g.order)
+	for _, p := range g.popularity {
+		writeUint64(w, uint64(p))
+	}
+	writeInt32s(w, g.outOffsets)
+	writeInt32s(w, g.outEdges)
+	writeInt32s(w, g.inOffsets)
+	writeInt32s(w, g.inEdges)
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	var checksum [8]byte
+	binary.LittleEndian.PutUint64(checksum[:], hash.Sum64())
+	if _, err := file.Write(checksum[:]); err != nil {
+		return fmt.Errorf("failed to write snapshot checksum: %w", err)
+	}
+	return nil
+}
+
+func writeUint32(w *bufio.Writer, v uint32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	w.Write(buf[:])
+}
+
+func writeUint64(w *bufio.Writer, v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	w.Write(buf[:])
+}
+
+func writeInt32s(w *bufio.Writer, values []int32) {
+	for _, v := range values {
+		writeUint32(w, uint32(v))
+	}
+}
+
+// parseSnapshotHeader decodes and validates the fixed-size header
+func parseSnapshotHeader(data []byte) (SnapshotHeader, error) {
+	var h SnapshotHeader
+	if len(data) < snapshotHeaderSize || string(data[:8]) != snapshotMagic {
+		return h, fmt.Errorf("not a dbcli snapshot")
+	}
+	h.Version = binary.LittleEndian.Uint32(data[8:])
+	if h.Version != snapshotVersion {
+		return h, fmt.Errorf("unsupported snapshot version %d, expected %d; rebuild it with \"dbcli snapshot build\"", h.Version, snapshotVersion)
+	}
+	h.CreatedAt = time.Unix(int64(binary.LittleEndian.Uint64(data[16:])), 0)
+	h.Vertices = binary.LittleEndian.Uint64(data[24:])
+	h.Edges = binary.LittleEndian.Uint64(data[32:])
+	h.StringBytes = binary.LittleEndian.Uint64(data[40:])
+	if h.Vertices > 1<<31-1 || h.Edges > 1<<31-1 {
+		return h, fmt.Errorf("%w: vertex or edge count out of range", ErrSnapshotCorrupt)
+	}
+	// name offsets are uint32, so a larger string table cannot be written
+	if h.StringBytes > 1<<32-1 {
+		return h, fmt.Errorf("%w: string table size out of range", ErrSnapshotCorrupt)
+	}
+	return h, nil
+}
+
+// ReadSnapshotHeader reads only the header of a snapshot file
+func ReadSnapshotHeader(path string) (SnapshotHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return SnapshotHeader{}, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return SnapshotHeader{}, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	h, err := parseSnapshotHeader(header)
+	if err != nil {
+		return h, err
+	}
+
+	var checksum [8]byte
+	if _, err := file.ReadAt(checksum[:], int64(h.snapshotSize())-8); err != nil {
+		return h, fmt.Errorf("%w: failed to read checksum: %v", ErrSnapshotCorrupt, err)
+	}
+	h.Checksum = binary.LittleEndian.Uint64(checksum[:])
+	return h, nil
+}
+
+// LoadSnapshot reads a snapshot with a single read and verifies its checksum
+func LoadSnapshot(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	h, err := parseSnapshotHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != h.snapshotSize() {
+		return nil, fmt.Errorf("%w: size %d does not match header (expected %d)", ErrSnapshotCorrupt, len(data), h.snapshotSize())
+	}
+
+	body := data[:len(data)-8]
+	h.Checksum = binary.LittleEndian.Uint64(data[len(data)-8:])
+	if crc64.Checksum(body, crcTable) != h.Checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+	}
+
+	n, m := int(h.Vertices), int(h.Edges)
+	d := &decoder{buf: body[snapshotHeaderSize:]}
+	nameOffsets := d.uint32s(n + 1)
+	table := d.bytes(int(h.StringBytes))
+
+	// One allocation for all names; each name is a substring of it
+	strs := string(table)
+	names := make([]string, n)
+	for i := 0; i < n; i++ {
+		start, end := nameOffsets[i], nameOffsets[i+1]
+		if start > end || uint64(end) > h.StringBytes {
+			return nil, fmt.Errorf("%w: bad string table offsets", ErrSnapshotCorrupt)
+		}
+		names[i] = strs[start:end]
+	}
+
+	g := &Graph{names: names}
+	g.order = d.int32s(n)
+	g.popularity = make([]int64, n)
+	for i := range g.popularity {
+		g.popularity[i] = int64(binary.LittleEndian.Uint64(d.bytes(8)))
+	}
+	g.outOffsets = d.int32s(n + 1)
+	g.outEdges = d.int32s(m)
+	g.inOffsets = d.int32s(n + 1)
+	g.inEdges = d.int32s(m)
+
+	if err := g.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	return g, nil
+}
+
+// decoder consumes a byte slice whose length was already validated
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) bytes(count int) []byte {
+	b := d.buf[:count]
+	d.buf = d.buf[count:]
+	return b
+}
+
+func (d *decoder) uint32s(count int) []uint32 {
+	b := d.bytes(4 * count)
+	values := make([]uint32, count)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return values
+}
+
+func (d *decoder) int32s(count int) []int32 {
+	b := d.bytes(4 * count)
+	values := make([]int32, count)
+	for i := range values {
+		values[i] = int32(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return values
+}
+
+// validate checks that ids and offsets stay in range and that order sorts
+// the names, so a snapshot that passes the checksum but was written by a
+// buggy writer cannot cause panics or wrong lookups
+func (g *Graph) validate() error {
+	n := int32(len(g.names))
+	seen := make([]bool, n)
+	for i, v := range g.order {
+		if v < 0 || v >= n {
+			return fmt.Errorf("vertex id %d out of range", v)
+		}
+		if seen[v] {
+			return fmt.Errorf("vertex %d appears twice in the name order", v)
+		}
+		seen[v] = true
+		// strictly increasing also rules out duplicate names
+		if i > 0 && g.names[g.order[i-1]] >= g.names[v] {
+			return fmt.Errorf("names are not sorted at position %d of the name order", i)
+		}
+	}
+	for _, csr := range [][2][]int32{{g.outOffsets, g.outEdges}, {g.inOffsets, g.inEdges}} {
+		offsets, edges := csr[0], csr[1]
+		if offsets[0] != 0 || int(offsets[n]) != len(edges) {
+			return fmt.Errorf("adjacency offsets do not cover the edge list")
+		}
+		for i := int32(0); i < n; i++ {
+			if offsets[i] > offsets[i+1] {
+				return fmt.Errorf("adjacency offsets are not monotonic")
+			}
+		}
+		for _, v := range edges {
+			if v < 0 || v >= n {
+				return fmt.Errorf("edge endpoint %d out of range", v)
+			}
+		}
+		// duplicate edge handling relies on sorted adjacency lists
+		for i := int32(0); i < n; i++ {
+			if !slices.IsSorted(edges[offsets[i]:offsets[i+1]]) {
+				return fmt.Errorf("adjacency list of vertex %d is not sorted", i)
+			}
+		}
+	}
+	return nil
+}
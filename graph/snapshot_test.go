@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc64"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	g := fixtureGraph(t)
+	g.SetPopularity(mustLookup(t, g, "c"), 42)
+	path := filepath.Join(t.TempDir(), "graph.snap")
+	if err := g.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files after writing, want only the snapshot", len(entries))
+	}
+
+	header, err := ReadSnapshotHeader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != snapshotVersion || header.Vertices != uint64(g.NumVertices()) || header.Edges != uint64(g.NumEdges()) {
+		t.Errorf("header = %+v", header)
+	}
+
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NumVertices() != g.NumVertices() || loaded.NumEdges() != g.NumEdges() {
+		t.Fatalf("loaded %d vertices and %d edges, want %d and %d", loaded.NumVertices(), loaded.NumEdges(), g.NumVertices(), g.NumEdges())
+	}
+	for v := int32(0); v < int32(g.NumVertices()); v++ {
+		name := g.Name(v)
+		if loaded.Name(v) != name || loaded.Popularity(v) != g.Popularity(v) ||
+			!slices.Equal(loaded.Out(v), g.Out(v)) || !slices.Equal(loaded.In(v), g.In(v)) {
+			t.Errorf("vertex %d (%s) differs after loading", v, name)
+		}
+		if u, ok := loaded.Lookup(name); !ok || u != v {
+			t.Errorf("Lookup(%s) = %d, %v, want %d", name, u, ok, v)
+		}
+	}
+}
+
+func TestSnapshotCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.snap")
+	if err := fixtureGraph(t).WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := filepath.Join(t.TempDir(), "truncated.snap")
+	if err := os.WriteFile(truncated, data[:len(data)-10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(truncated); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("truncated snapshot: %v, want ErrSnapshotCorrupt", err)
+	}
+
+	flipped := filepath.Join(t.TempDir(), "flipped.snap")
+	data[snapshotHeaderSize] ^= 1
+	if err := os.WriteFile(flipped, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(flipped); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("snapshot with a flipped bit: %v, want ErrSnapshotCorrupt", err)
+	}
+}
+
+func TestSnapshotUnsortedAdjacency(t *testing.T) {
+	g := fixtureGraph(t)
+	root := mustLookup(t, g, "root")
+	out := g.outEdges[g.outOffsets[root]:g.outOffsets[root+1]]
+	out[0], out[1] = out[1], out[0]
+	path := filepath.Join(t.TempDir(), "graph.snap")
+	if err := g.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(path); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("unsorted adjacency: %v, want ErrSnapshotCorrupt", err)
+	}
+}
+
+func TestSnapshotUnsortedOrder(t *testing.T) {
+	g := fixtureGraph(t)
+	g.order[0], g.order[1] = g.order[1], g.order[0]
+	path := filepath.Join(t.TempDir(), "graph.snap")
+	if err := g.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(path); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("unsorted name order: %v, want ErrSnapshotCorrupt", err)
+	}
+}
+
+func TestSnapshotSizeOverflow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.snap")
+	if err := fixtureGraph(t).WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every added vertex takes 24 more bytes, which a string table that
+	// much smaller wraps below zero and the sum back to the file size; the
+	// checksum is made to match
+	vertices := binary.LittleEndian.Uint64(data[24:])
+	stringBytes := binary.LittleEndian.Uint64(data[40:])
+	added := stringBytes/24 + 1
+	binary.LittleEndian.PutUint64(data[24:], vertices+added)
+	binary.LittleEndian.PutUint64(data[40:], stringBytes-24*added)
+	body := data[:len(data)-8]
+	binary.LittleEndian.PutUint64(data[len(data)-8:], crc64.Checksum(body, crcTable))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(path); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("wrapped header counts: %v, want ErrSnapshotCorrupt", err)
+	}
+}
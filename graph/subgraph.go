@@ -0,0 +1,24 @@
+package graph
+
+// Induced returns the subgraph made of the given vertices and the edges
+// between them. Vertex ids are not preserved; look vertices up by name.
+func (g *Graph) Induced(vertices []int32) *Graph {
+	vertexSet := make(map[string]struct{}, len(vertices))
+	popularityMap := make(map[string]int, len(vertices))
+	member := make(map[int32]struct{}, len(vertices))
+	for _, v := range vertices {
+		vertexSet[g.names[v]] = struct{}{}
+		popularityMap[g.names[v]] = int(g.popularity[v])
+		member[v] = struct{}{}
+	}
+
+	var edgePairs [][2]string
+	for _, v := range vertices {
+		for _, u := range g.Out(v) {
+			if _, ok := member[u]; ok {
+				edgePairs = append(edgePairs, [2]string{g.names[v], g.names[u]})
+			}
+		}
+	}
+	return New(popularityMap, vertexSet, edgePairs)
+}
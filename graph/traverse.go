@@ -0,0 +1,104 @@
+package graph
+
+// Visit is a vertex reached by a traversal together with the depth at which
+// it was first reached
+type Visit struct {
+	Vertex int32
+	Depth  int
+}
+
+// BFS traverses the graph breadth-first from src following dir and returns
+// every vertex reached within maxDepth hops, src included at depth 0. Each
+// vertex is reported once, at its shortest depth, so cycles are harmless.
+// A negative maxDepth means no limit.
+func (g *Graph) BFS(src int32, dir Direction, maxDepth int) []Visit {
+	return g.BFSFunc(src, dir, maxDepth, nil)
+}
+
+// BFSFunc is like BFS but only reaches vertices for which allow returns true.
+// A vertex that is not allowed is neither reported nor expanded, so nothing is
+// reached through it. A nil allow admits every vertex.
+func (g *Graph) BFSFunc(src int32, dir Direction, maxDepth int, allow func(v int32) bool) []Visit {
+	if allow != nil && !allow(src) {
+		return nil
+	}
+	visited := make(map[int32]struct{})
+	visited[src] = struct{}{}
+	result := []Visit{{Vertex: src, Depth: 0}}
+
+	for head := 0; head < len(result); head++ {
+		cur := result[head]
+		if maxDepth >= 0 && cur.Depth >= maxDepth {
+			continue
+		}
+		g.Neighbors(cur.Vertex, dir, func(u int32) {
+			if _, ok := visited[u]; ok {
+				return
+			}
+			visited[u] = struct{}{}
+			if allow != nil && !allow(u) {
+				return
+			}
+			result = append(result, Visit{Vertex: u, Depth: cur.Depth + 1})
+		})
+	}
+	return result
+}
+
+// ShortestPath returns the vertices on a shortest path from src to dst
+// following dir, or nil if dst is not reachable within maxDepth hops.
+// A negative maxDepth means no limit.
+func (g *Graph) ShortestPath(src, dst int32, dir Direction, maxDepth int) []int32 {
+	if src == dst {
+		return []int32{src}
+	}
+
+	parent := map[int32]int32{src: -1}
+	frontier := []int32{src}
+	for depth := 0; len(frontier) > 0 && (maxDepth < 0 || depth < maxDepth); depth++ {
+		var next []int32
+		for _, v := range frontier {
+			found := false
+			g.Neighbors(v, dir, func(u int32) {
+				if found {
+					return
+				}
+				if _, ok := parent[u]; ok {
+					return
+				}
+				parent[u] = v
+				if u == dst {
+					found = true
+					return
+				}
+				next = append(next, u)
+			})
+			if found {
+				return buildPath(parent, dst)
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
+
+// buildPath walks the parent links back from dst
+func buildPath(parent map[int32]int32, dst int32) []int32 {
+	var path []int32
+	for v := dst; v != -1; v = parent[v] {
+		path = append(path, v)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// ReachableAvoiding returns the vertices reachable from src within maxDepth
+// hops that can be reached without passing through avoid. avoid itself is
+// never included.
+func (g *Graph) ReachableAvoiding(src int32, dir Direction, maxDepth int, avoid int32) []Visit {
+	return g.BFSFunc(src, dir, maxDepth, func(v int32) bool {
+		return v != avoid
+	})
+}
@@ -0,0 +1,212 @@
+package graph
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fixtureGraph builds a small taxonomy with a cycle (d -> root) and a vertex
+// (e) that is only reachable through target:
+//
+//	root -> a, b
+//	a    -> c, target
+//	b    -> c, f
+//	c    -> d
+//	d    -> root
+//	f    -> g
+//	target -> e
+func fixtureGraph(t *testing.T) *Graph {
+	t.Helper()
+	edges := [][2]string{
+		{"root", "a"}, {"root", "b"},
+		{"a", "c"}, {"a", "target"},
+		{"b", "c"}, {"b", "f"},
+		{"c", "d"},
+		{"d", "root"},
+		{"f", "g"},
+		{"target", "e"},
+	}
+	vertices := make(map[string]struct{})
+	for _, e := range edges {
+		vertices[e[0]] = struct{}{}
+		vertices[e[1]] = struct{}{}
+	}
+	return New(map[string]int{}, vertices, edges)
+}
+
+func mustLookup(t *testing.T, g *Graph, name string) int32 {
+	t.Helper()
+	v, ok := g.Lookup(name)
+	if !ok {
+		t.Fatalf("vertex %q not found", name)
+	}
+	return v
+}
+
+// visitNames returns name -> depth for a traversal result
+func visitNames(g *Graph, visits []Visit) map[string]int {
+	result := make(map[string]int, len(visits))
+	for _, visit := range visits {
+		result[g.Name(visit.Vertex)] = visit.Depth
+	}
+	return result
+}
+
+func TestReachableAvoiding(t *testing.T) {
+	g := fixtureGraph(t)
+	target := mustLookup(t, g, "target")
+
+	tests := []struct {
+		name     string
+		source   string
+		avoid    int32
+		maxDepth int
+		want     map[string]int
+	}{
+		{
+			name:     "depth one",
+			source:   "root",
+			avoid:    target,
+			maxDepth: 1,
+			want:     map[string]int{"root": 0, "a": 1, "b": 1},
+		},
+		{
+			name:     "depth two stops at target",
+			source:   "root",
+			avoid:    target,
+			maxDepth: 2,
+			want:     map[string]int{"root": 0, "a": 1, "b": 1, "c": 2, "f": 2},
+		},
+		{
+			name:     "unbounded never passes through target",
+			source:   "root",
+			avoid:    target,
+			maxDepth: -1,
+			want:     map[string]int{"root": 0, "a": 1, "b": 1, "c": 2, "f": 2, "d": 3, "g": 3},
+		},
+		{
+			name:     "cycle back to source is not repeated",
+			source:   "c",
+			avoid:    target,
+			maxDepth: 4,
+			want:     map[string]int{"c": 0, "d": 1, "root": 2, "a": 3, "b": 3, "f": 4},
+		},
+		{
+			name:     "nothing avoided reaches through target",
+			source:   "a",
+			avoid:    -1,
+			maxDepth: 2,
+			want:     map[string]int{"a": 0, "c": 1, "target": 1, "d": 2, "e": 2},
+		},
+		{
+			name:     "source equal to target",
+			source:   "target",
+			avoid:    target,
+			maxDepth: 3,
+			want:     map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := mustLookup(t, g, tt.source)
+			got := visitNames(g, g.ReachableAvoiding(src, Out, tt.maxDepth, tt.avoid))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReachableAvoiding(%s) = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	g := fixtureGraph(t)
+	src := mustLookup(t, g, "root")
+	dst := mustLookup(t, g, "d")
+
+	path := g.ShortestPath(src, dst, Out, -1)
+	var names []string
+	for _, v := range path {
+		names = append(names, g.Name(v))
+	}
+	if len(names) != 4 || names[0] != "root" || names[2] != "c" || names[3] != "d" {
+		t.Errorf("ShortestPath(root, d) = %v, want root -> a|b -> c -> d", names)
+	}
+
+	if path := g.ShortestPath(src, dst, Out, 2); path != nil {
+		t.Errorf("ShortestPath with maxDepth 2 = %v, want nil", path)
+	}
+}
+
+func TestLookupAfterRename(t *testing.T) {
+	g := fixtureGraph(t)
+	v := mustLookup(t, g, "c")
+	if err := g.Rename(v, "zzz"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, ok := g.Lookup("c"); ok {
+		t.Error("old name still found after rename")
+	}
+	if got := mustLookup(t, g, "zzz"); got != v {
+		t.Errorf("Lookup(zzz) = %d, want %d", got, v)
+	}
+	if err := g.Rename(v, "root"); err == nil {
+		t.Error("Rename to an existing name succeeded")
+	}
+
+	var names []string
+	for _, id := range g.order {
+		names = append(names, g.Name(id))
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("order not sorted after rename: %v", names)
+	}
+}
+
+func TestClosure(t *testing.T) {
+	g := fixtureGraph(t)
+	root := mustLookup(t, g, "root")
+
+	// The cycle d -> root must not bring root back at depth 4
+	visits, levels := g.Closure(root, Out, 1, -1)
+	want := map[string]int{"a": 1, "b": 1, "c": 2, "target": 2, "f": 2, "d": 3, "e": 3, "g": 3}
+	if got := visitNames(g, visits); !reflect.DeepEqual(got, want) {
+		t.Errorf("Closure(root) = %v, want %v", got, want)
+	}
+	wantLevels := []Level{{Depth: 1, Count: 2}, {Depth: 2, Count: 3}, {Depth: 3, Count: 3}}
+	if !reflect.DeepEqual(levels, wantLevels) {
+		t.Errorf("Closure(root) levels = %v, want %v", levels, wantLevels)
+	}
+
+	visits, _ = g.Closure(mustLookup(t, g, "c"), In, 2, 2)
+	if got := visitNames(g, visits); !reflect.DeepEqual(got, map[string]int{"root": 2}) {
+		t.Errorf("Closure(c, In, 2, 2) = %v, want root at depth 2", got)
+	}
+}
+
+func TestClosurePopularity(t *testing.T) {
+	g := fixtureGraph(t)
+	for i, name := range []string{"root", "a", "b", "c", "target", "f", "d", "e", "g"} {
+		g.SetPopularity(mustLookup(t, g, name), 1<<i)
+	}
+
+	_, levels := g.Closure(mustLookup(t, g, "root"), Out, 1, -1)
+	want := []Level{
+		{Depth: 1, Count: 2, Popularity: 2 + 4},
+		{Depth: 2, Count: 3, Popularity: 8 + 16 + 32},
+		{Depth: 3, Count: 3, Popularity: 64 + 128 + 256},
+	}
+	if !reflect.DeepEqual(levels, want) {
+		t.Errorf("Closure(root) levels = %v, want %v", levels, want)
+	}
+
+	// e -> target -> a -> root, then around the cycle d -> c -> b
+	_, levels = g.Closure(mustLookup(t, g, "e"), In, 0, -1)
+	var total int64
+	for _, level := range levels {
+		total += level.Popularity
+	}
+	if want := int64(128 + 16 + 2 + 1 + 64 + 8 + 4); len(levels) != 7 || total != want {
+		t.Errorf("Closure(e, In) levels = %v, want 7 levels summing to %d", levels, want)
+	}
+}
@@ -0,0 +1,77 @@
+package graph
+
+import "sort"
+
+// TreeNode is a vertex in the tree view built by Tree
+type TreeNode struct {
+	Vertex   int32
+	Children []*TreeNode
+	// Hidden counts children left out because of the per-node limit
+	Hidden int
+	// Cycle is set when the vertex is already on the path from the root
+	Cycle bool
+	// Repeated is set when the vertex was expanded elsewhere in the tree;
+	// its children are not shown again
+	Repeated bool
+}
+
+// Tree unfolds the graph around root into a tree following dir, down to
+// maxDepth levels (negative for no limit). Children are ordered by popularity,
+// most popular first, and at most maxChildren of them are kept per node
+// (0 for all). A vertex is expanded only the first time it is met, so the
+// tree stays finite on cycles and small on heavily shared categories. With a
+// depth limit a vertex met again closer to the root is expanded again, since
+// the limit cut its first subtree shorter.
+func (g *Graph) Tree(root int32, dir Direction, maxDepth, maxChildren int) *TreeNode {
+	expandedAt := make(map[int32]int)
+	onPath := make(map[int32]bool)
+
+	var build func(v int32, depth int) *TreeNode
+	build = func(v int32, depth int) *TreeNode {
+		node := &TreeNode{Vertex: v}
+		if onPath[v] {
+			node.Cycle = true
+			return node
+		}
+		if d, ok := expandedAt[v]; ok && (maxDepth < 0 || d <= depth) {
+			node.Repeated = true
+			return node
+		}
+		if maxDepth >= 0 && depth >= maxDepth {
+			return node
+		}
+		expandedAt[v] = depth
+
+		children := g.neighborhood(v, dir)
+		if g.HasSelfLoop(v) && dir != Both {
+			children = append(children, v)
+		}
+		sort.Slice(children, func(i, j int) bool {
+			a, b := children[i], children[j]
+			if g.popularity[a] != g.popularity[b] {
+				return g.popularity[a] > g.popularity[b]
+			}
+			return g.names[a] < g.names[b]
+		})
+		if maxChildren > 0 && len(children) > maxChildren {
+			node.Hidden = len(children) - maxChildren
+			children = children[:maxChildren]
+		}
+
+		onPath[v] = true
+		for _, u := range children {
+			node.Children = append(node.Children, build(u, depth+1))
+		}
+		onPath[v] = false
+		return node
+	}
+	return build(root, 0)
+}
+
+// Walk calls fn for every edge of the tree, from parent to child
+func (n *TreeNode) Walk(fn func(parent, child *TreeNode)) {
+	for _, child := range n.Children {
+		fn(n, child)
+		child.Walk(fn)
+	}
+}
@@ -0,0 +1,50 @@
+package graph
+
+import "testing"
+
+func TestTree(t *testing.T) {
+	g := fixtureGraph(t)
+	tree := g.Tree(mustLookup(t, g, "root"), Out, -1, 0)
+
+	marks := make(map[string][]string)
+	tree.Walk(func(parent, child *TreeNode) {
+		mark := "expanded"
+		switch {
+		case child.Cycle:
+			mark = "cycle"
+		case child.Repeated:
+			mark = "repeated"
+		}
+		marks[g.Name(child.Vertex)] = append(marks[g.Name(child.Vertex)], mark)
+	})
+	// c is reached from a and b but expanded once; d leads back to root
+	if got := marks["c"]; len(got) != 2 || got[0] != "expanded" || got[1] != "repeated" {
+		t.Errorf("c marks = %v", got)
+	}
+	if got := marks["root"]; len(got) != 1 || got[0] != "cycle" {
+		t.Errorf("root marks = %v", got)
+	}
+
+	limited := g.Tree(mustLookup(t, g, "root"), Out, 1, 1)
+	if len(limited.Children) != 1 || limited.Hidden != 1 || len(limited.Children[0].Children) != 0 {
+		t.Errorf("limited tree has %d children, %d hidden", len(limited.Children), limited.Hidden)
+	}
+}
+
+func TestTreeExpandsVertexMetAtDepthLimit(t *testing.T) {
+	// a reaches v at the depth limit before root reaches it directly
+	vertices := map[string]struct{}{"root": {}, "a": {}, "v": {}, "w": {}}
+	g := New(map[string]int{}, vertices, [][2]string{{"root", "a"}, {"root", "v"}, {"a", "v"}, {"v", "w"}})
+	tree := g.Tree(mustLookup(t, g, "root"), Out, 2, 0)
+
+	if len(tree.Children) != 2 {
+		t.Fatalf("root has %d children, want 2", len(tree.Children))
+	}
+	a, v := tree.Children[0], tree.Children[1]
+	if len(a.Children) != 1 || a.Children[0].Repeated || len(a.Children[0].Children) != 0 {
+		t.Errorf("v under a should be a plain leaf at the depth limit")
+	}
+	if g.Name(v.Vertex) != "v" || v.Repeated || len(v.Children) != 1 || g.Name(v.Children[0].Vertex) != "w" {
+		t.Errorf("v under root: repeated=%v with %d children, want w", v.Repeated, len(v.Children))
+	}
+}
@@ -0,0 +1,239 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WeightFunc returns the cost of entering a vertex with the given popularity
+type WeightFunc func(popularity int64) float64
+
+// weightPresets are the named weight functions accepted by ParseWeight
+var weightPresets = map[string]string{
+	// unit counts hops, like an unweighted shortest path
+	"unit": "1",
+	// inverse makes popular vertices cheap, so paths favor them
+	"inverse": "1/(1+p)",
+	// inverse-log favors popular vertices with less extreme differences
+	"inverse-log": "1/(1+log(1+p))",
+	// popularity makes popular vertices expensive, so paths avoid them
+	"popularity": "1+p",
+	// log avoids popular vertices with less extreme differences
+	"log": "1+log(1+p)",
+}
+
+// WeightPresets returns the names of the built-in weight functions
+func WeightPresets() []string {
+	return []string{"unit", "inverse", "inverse-log", "popularity", "log"}
+}
+
+// ParseWeight turns a preset name or an arithmetic expression over p (the
+// popularity of the vertex being entered) into a WeightFunc. Expressions
+// support numbers, p, + - * / ^, parentheses and the functions log, log2,
+// log10, sqrt, exp and abs, e.g. "1/(1+p)" or "1 + sqrt(p)/100".
+func ParseWeight(expr string) (WeightFunc, error) {
+	if preset, ok := weightPresets[expr]; ok {
+		expr = preset
+	}
+	parser := &exprParser{input: expr}
+	node, err := parser.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid weight expression %q: %w", expr, err)
+	}
+	return func(popularity int64) float64 {
+		return node(float64(popularity))
+	}, nil
+}
+
+// exprNode evaluates an expression for a given popularity
+type exprNode func(p float64) float64
+
+var exprFunctions = map[string]func(float64) float64{
+	"log":   math.Log,
+	"log2":  math.Log2,
+	"log10": math.Log10,
+	"sqrt":  math.Sqrt,
+	"exp":   math.Exp,
+	"abs":   math.Abs,
+}
+
+// exprParser is a recursive descent parser for
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = unary { ("*" | "/") unary }
+//	unary  = "-" unary | power
+//	power  = atom [ "^" unary ]
+//	atom   = number | "p" | ident "(" expr ")" | "(" expr ")"
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (ep *exprParser) parse() (exprNode, error) {
+	node, err := ep.expr()
+	if err != nil {
+		return nil, err
+	}
+	ep.skipSpaces()
+	if ep.pos < len(ep.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", ep.input[ep.pos:], ep.pos)
+	}
+	return node, nil
+}
+
+func (ep *exprParser) skipSpaces() {
+	for ep.pos < len(ep.input) && ep.input[ep.pos] == ' ' {
+		ep.pos++
+	}
+}
+
+// consume skips spaces and reports whether the next byte is c, consuming it
+func (ep *exprParser) consume(c byte) bool {
+	ep.skipSpaces()
+	if ep.pos < len(ep.input) && ep.input[ep.pos] == c {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *exprParser) expr() (exprNode, error) {
+	left, err := ep.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case ep.consume('+'):
+			right, err := ep.term()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(p float64) float64 { return l(p) + right(p) }
+		case ep.consume('-'):
+			right, err := ep.term()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(p float64) float64 { return l(p) - right(p) }
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (ep *exprParser) term() (exprNode, error) {
+	left, err := ep.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case ep.consume('*'):
+			right, err := ep.unary()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(p float64) float64 { return l(p) * right(p) }
+		case ep.consume('/'):
+			right, err := ep.unary()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(p float64) float64 { return l(p) / right(p) }
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (ep *exprParser) unary() (exprNode, error) {
+	if ep.consume('-') {
+		operand, err := ep.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(p float64) float64 { return -operand(p) }, nil
+	}
+	return ep.power()
+}
+
+func (ep *exprParser) power() (exprNode, error) {
+	base, err := ep.atom()
+	if err != nil {
+		return nil, err
+	}
+	if ep.consume('^') {
+		exponent, err := ep.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(p float64) float64 { return math.Pow(base(p), exponent(p)) }, nil
+	}
+	return base, nil
+}
+
+func (ep *exprParser) atom() (exprNode, error) {
+	ep.skipSpaces()
+	if ep.pos >= len(ep.input) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	if ep.consume('(') {
+		inner, err := ep.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !ep.consume(')') {
+			return nil, fmt.Errorf("missing ) at position %d", ep.pos)
+		}
+		return inner, nil
+	}
+
+	start := ep.pos
+	c := rune(ep.input[ep.pos])
+	if unicode.IsDigit(c) || c == '.' {
+		for ep.pos < len(ep.input) && (unicode.IsDigit(rune(ep.input[ep.pos])) || ep.input[ep.pos] == '.' || ep.input[ep.pos] == 'e') {
+			ep.pos++
+		}
+		value, err := strconv.ParseFloat(ep.input[start:ep.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", ep.input[start:ep.pos])
+		}
+		return func(float64) float64 { return value }, nil
+	}
+
+	if unicode.IsLetter(c) {
+		for ep.pos < len(ep.input) && (unicode.IsLetter(rune(ep.input[ep.pos])) || unicode.IsDigit(rune(ep.input[ep.pos]))) {
+			ep.pos++
+		}
+		ident := strings.ToLower(ep.input[start:ep.pos])
+		if ident == "p" || ident == "popularity" {
+			return func(p float64) float64 { return p }, nil
+		}
+		fn, ok := exprFunctions[ident]
+		if !ok {
+			return nil, fmt.Errorf("unknown identifier %q", ident)
+		}
+		if !ep.consume('(') {
+			return nil, fmt.Errorf("expected ( after %s", ident)
+		}
+		arg, err := ep.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !ep.consume(')') {
+			return nil, fmt.Errorf("missing ) after %s argument", ident)
+		}
+		return func(p float64) float64 { return fn(arg(p)) }, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", string(c), ep.pos)
+}
@@ -0,0 +1,292 @@
+package graph
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PathQuery configures weighted path searches
+type PathQuery struct {
+	Dir Direction
+	// MaxDepth limits the number of edges on a path; 0 means no limit
+	MaxDepth int
+	// Weight is the cost of entering a vertex; it must be positive
+	Weight WeightFunc
+}
+
+// WeightedPath is a path together with its total cost and popularity
+type WeightedPath struct {
+	Vertices   []int32
+	Cost       float64
+	Popularity int64
+}
+
+// pathFilter removes vertices and edges from a search, as Yen's algorithm needs
+type pathFilter struct {
+	vertices map[int32]struct{}
+	edges    map[[2]int32]struct{}
+}
+
+func (f pathFilter) allows(u, v int32) bool {
+	if _, ok := f.vertices[v]; ok {
+		return false
+	}
+	_, ok := f.edges[[2]int32{u, v}]
+	return !ok
+}
+
+// weight evaluates q.Weight for vertex v and rejects unusable values
+func (g *Graph) weight(q PathQuery, v int32) (float64, error) {
+	w := q.Weight(g.popularity[v])
+	if !(w > 0) || math.IsInf(w, 0) {
+		return 0, fmt.Errorf("weight must be positive and finite, got %v for %s (popularity %d)", w, g.names[v], g.popularity[v])
+	}
+	return w, nil
+}
+
+// newWeightedPath computes the cost and popularity of a path
+func (g *Graph) newWeightedPath(vertices []int32, q PathQuery) (WeightedPath, error) {
+	path := WeightedPath{Vertices: vertices}
+	for i, v := range vertices {
+		path.Popularity += g.popularity[v]
+		if i == 0 {
+			continue
+		}
+		w, err := g.weight(q, v)
+		if err != nil {
+			return path, err
+		}
+		path.Cost += w
+	}
+	return path, nil
+}
+
+// CheapestPath returns the path from src to dst with the lowest total cost,
+// where each edge costs q.Weight of the vertex it enters. It uses Dijkstra's
+// algorithm, or a hop-bounded Bellman-Ford when q.MaxDepth is set.
+func (g *Graph) CheapestPath(src, dst int32, q PathQuery) (WeightedPath, bool, error) {
+	vertices, err := g.cheapest(src, dst, q, q.MaxDepth, pathFilter{})
+	if err != nil || vertices == nil {
+		return WeightedPath{}, false, err
+	}
+	path, err := g.newWeightedPath(vertices, q)
+	return path, err == nil, err
+}
+
+// cheapest returns the vertices of the cheapest path using at most maxHops
+// edges (0 for no limit) that the filter allows, or nil if there is none
+func (g *Graph) cheapest(src, dst int32, q PathQuery, maxHops int, f pathFilter) ([]int32, error) {
+	if maxHops > 0 {
+		return g.cheapestBounded(src, dst, q, maxHops, f)
+	}
+
+	dist := map[int32]float64{src: 0}
+	parent := map[int32]int32{src: -1}
+	done := make(map[int32]struct{})
+	queue := &costQueue{{vertex: src}}
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(costItem)
+		u := item.vertex
+		if _, ok := done[u]; ok {
+			continue
+		}
+		done[u] = struct{}{}
+		if u == dst {
+			return buildPath(parent, dst), nil
+		}
+
+		var err error
+		g.Neighbors(u, q.Dir, func(v int32) {
+			if err != nil || !f.allows(u, v) {
+				return
+			}
+			if _, ok := done[v]; ok {
+				return
+			}
+			var w float64
+			if w, err = g.weight(q, v); err != nil {
+				return
+			}
+			if d, ok := dist[v]; !ok || item.cost+w < d {
+				dist[v] = item.cost + w
+				parent[v] = u
+				heap.Push(queue, costItem{vertex: v, cost: item.cost + w})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// hopLabel records how a vertex was reached in round k of cheapestBounded
+type hopLabel struct {
+	cost        float64
+	parent      int32
+	parentRound int
+}
+
+// cheapestBounded runs maxHops rounds of Bellman-Ford relaxation, where
+// round k only extends paths that gained an edge in round k-1. With positive
+// weights the result is a simple path.
+func (g *Graph) cheapestBounded(src, dst int32, q PathQuery, maxHops int, f pathFilter) ([]int32, error) {
+	dist := map[int32]float64{src: 0}
+	lastRound := map[int32]int{src: 0}
+	rounds := []map[int32]hopLabel{{src: {parent: -1, parentRound: -1}}}
+
+	for k := 1; k <= maxHops && len(rounds[k-1]) > 0; k++ {
+		improved := make(map[int32]hopLabel)
+		for u, label := range rounds[k-1] {
+			if u == dst {
+				continue
+			}
+			var err error
+			g.Neighbors(u, q.Dir, func(v int32) {
+				if err != nil || v == src || !f.allows(u, v) {
+					return
+				}
+				var w float64
+				if w, err = g.weight(q, v); err != nil {
+					return
+				}
+				cost := label.cost + w
+				if d, ok := dist[v]; ok && cost >= d {
+					return
+				}
+				if l, ok := improved[v]; ok && cost >= l.cost {
+					return
+				}
+				improved[v] = hopLabel{cost: cost, parent: u, parentRound: k - 1}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		for v, label := range improved {
+			dist[v] = label.cost
+			lastRound[v] = k
+		}
+		rounds = append(rounds, improved)
+	}
+
+	round, ok := lastRound[dst]
+	if !ok {
+		return nil, nil
+	}
+	var path []int32
+	for v := dst; v != -1; {
+		path = append(path, v)
+		label := rounds[round][v]
+		v, round = label.parent, label.parentRound
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// KShortestPaths returns up to k loopless paths from src to dst in order of
+// increasing cost, using Yen's algorithm on top of cheapest.
+func (g *Graph) KShortestPaths(src, dst int32, k int, q PathQuery) ([]WeightedPath, error) {
+	first, ok, err := g.CheapestPath(src, dst, q)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	accepted := []WeightedPath{first}
+	var candidates []WeightedPath
+	seen := map[string]struct{}{pathKey(first.Vertices): {}}
+
+	for len(accepted) < k {
+		prev := accepted[len(accepted)-1].Vertices
+		for i := 0; i < len(prev)-1; i++ {
+			spur := prev[i]
+			root := prev[:i+1]
+
+			maxHops := 0
+			if q.MaxDepth > 0 {
+				maxHops = q.MaxDepth - i
+			}
+
+			filter := pathFilter{vertices: make(map[int32]struct{}), edges: make(map[[2]int32]struct{})}
+			for _, v := range root[:i] {
+				filter.vertices[v] = struct{}{}
+			}
+			for _, p := range accepted {
+				if len(p.Vertices) > i+1 && equalPrefix(p.Vertices, root) {
+					filter.edges[[2]int32{p.Vertices[i], p.Vertices[i+1]}] = struct{}{}
+				}
+			}
+
+			spurPath, err := g.cheapest(spur, dst, q, maxHops, filter)
+			if err != nil {
+				return accepted, err
+			}
+			if spurPath == nil {
+				continue
+			}
+
+			vertices := append(append([]int32(nil), root[:i]...), spurPath...)
+			key := pathKey(vertices)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			candidate, err := g.newWeightedPath(vertices, q)
+			if err != nil {
+				return accepted, err
+			}
+			candidates = append(candidates, candidate)
+		}
+
+		if len(candidates) == 0 {
+			break
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			if candidates[a].Cost != candidates[b].Cost {
+				return candidates[a].Cost < candidates[b].Cost
+			}
+			return len(candidates[a].Vertices) < len(candidates[b].Vertices)
+		})
+		accepted = append(accepted, candidates[0])
+		candidates = candidates[1:]
+	}
+	return accepted, nil
+}
+
+func equalPrefix(path, prefix []int32) bool {
+	for i, v := range prefix {
+		if path[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func pathKey(vertices []int32) string {
+	return fmt.Sprint(vertices)
+}
+
+// costItem is a vertex with its tentative distance
+type costItem struct {
+	vertex int32
+	cost   float64
+}
+
+// costQueue is a min-heap of costItems for Dijkstra's algorithm
+type costQueue []costItem
+
+func (q costQueue) Len() int            { return len(q) }
+func (q costQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q costQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *costQueue) Push(x interface{}) { *q = append(*q, x.(costItem)) }
+func (q *costQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseWeight(t *testing.T) {
+	tests := []struct {
+		expr       string
+		popularity int64
+		want       float64
+	}{
+		{"unit", 42, 1},
+		{"inverse", 3, 0.25},
+		{"1 + 2 * p", 3, 7},
+		{"(1 + 2) * p", 3, 9},
+		{"-p + 10", 3, 7},
+		{"2 ^ p / 4", 3, 2},
+		{"sqrt(p) + log10(100)", 16, 6},
+		{"1/(1+log(1+p))", 0, 1},
+	}
+	for _, tt := range tests {
+		weight, err := ParseWeight(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseWeight(%q): %v", tt.expr, err)
+		}
+		if got := weight(tt.popularity); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ParseWeight(%q)(%d) = %v, want %v", tt.expr, tt.popularity, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "1 +", "q", "log 3", "(1", "1 2"} {
+		if _, err := ParseWeight(expr); err == nil {
+			t.Errorf("ParseWeight(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestKShortestPaths(t *testing.T) {
+	// s reaches t directly through an unpopular vertex x, or through the
+	// popular vertices a and b
+	edges := [][2]string{
+		{"s", "x"}, {"x", "t"},
+		{"s", "a"}, {"a", "b"}, {"b", "t"},
+		{"s", "b"},
+	}
+	vertices := map[string]struct{}{"s": {}, "x": {}, "a": {}, "b": {}, "t": {}}
+	popularity := map[string]int{"x": 1, "a": 9, "b": 9, "t": 0}
+	g := New(popularity, vertices, edges)
+	s, tgt := mustLookup(t, g, "s"), mustLookup(t, g, "t")
+
+	weight, _ := ParseWeight("inverse")
+	paths, err := g.KShortestPaths(s, tgt, 5, PathQuery{Dir: Out, Weight: weight})
+	if err != nil {
+		t.Fatalf("KShortestPaths: %v", err)
+	}
+
+	// Costs: s-b-t = 0.1+1, s-a-b-t = 0.1+0.1+1, s-x-t = 0.5+1
+	want := [][]string{{"s", "b", "t"}, {"s", "a", "b", "t"}, {"s", "x", "t"}}
+	if len(paths) != len(want) {
+		t.Fatalf("got %d paths, want %d", len(paths), len(want))
+	}
+	for i, path := range paths {
+		var names []string
+		for _, v := range path.Vertices {
+			names = append(names, g.Name(v))
+		}
+		if len(names) != len(want[i]) {
+			t.Errorf("path %d = %v, want %v", i, names, want[i])
+			continue
+		}
+		for j := range names {
+			if names[j] != want[i][j] {
+				t.Errorf("path %d = %v, want %v", i, names, want[i])
+				break
+			}
+		}
+		if i > 0 && path.Cost < paths[i-1].Cost {
+			t.Errorf("path %d cost %v is lower than path %d cost %v", i, path.Cost, i-1, paths[i-1].Cost)
+		}
+	}
+
+	// Limiting the depth to two edges drops s-a-b-t
+	paths, err = g.KShortestPaths(s, tgt, 5, PathQuery{Dir: Out, MaxDepth: 2, Weight: weight})
+	if err != nil {
+		t.Fatalf("KShortestPaths: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("with max depth 2 got %d paths, want 2", len(paths))
+	}
+}
@@ -3,19 +3,22 @@ package importer
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"log"
 	"os"
 )
 
 const maxScanBufferSize = 10 * 1024 * 1024 // 10MB buffer size
 
-func LoadEdges(filePath string) (map[string]struct{}, [][2]string) {
+// LoadEdges reads the parent,child pairs of a taxonomy file and returns every
+// vertex named in it along with the pairs
+func LoadEdges(filePath string) (map[string]struct{}, [][2]string, error) {
 	allVertices := make(map[string]struct{})
 	edgePairs := make([][2]string, 0, 10000)
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Fatalf("Failed to open edges file: %v", err)
+		return nil, nil, fmt.Errorf("failed to open edges file: %w", err)
 	}
 	defer file.Close()
 
@@ -43,8 +46,8 @@ func LoadEdges(filePath string) (map[string]struct{}, [][2]string) {
 	}
 
 	if err := sc.Err(); err != nil {
-		log.Fatalf("Error reading edges file: %v", err)
+		return nil, nil, fmt.Errorf("failed to read edges file: %w", err)
 	}
 
-	return allVertices, edgePairs
+	return allVertices, edgePairs, nil
 }
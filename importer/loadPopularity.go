@@ -3,18 +3,21 @@ package importer
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
 )
 
-func LoadPopularity(filePath string) (map[string]int, map[string]struct{}) {
+// LoadPopularity reads the name,popularity lines of a popularity file and
+// returns the popularity by name along with every name
+func LoadPopularity(filePath string) (map[string]int, map[string]struct{}, error) {
 	popularityMap := make(map[string]int)
 	allVertices := make(map[string]struct{})
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Fatalf("Failed to open popularity file: %v", err)
+		return nil, nil, fmt.Errorf("failed to open popularity file: %w", err)
 	}
 	defer file.Close()
 
@@ -44,8 +47,8 @@ func LoadPopularity(filePath string) (map[string]int, map[string]struct{}) {
 	}
 
 	if err := sc.Err(); err != nil {
-		log.Fatalf("Error reading popularity file: %v", err)
+		return nil, nil, fmt.Errorf("failed to read popularity file: %w", err)
 	}
 
-	return popularityMap, allVertices
+	return popularityMap, allVertices, nil
 }
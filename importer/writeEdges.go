@@ -0,0 +1,25 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// WriteEdges writes edge pairs in the taxonomy_iw.csv format read by LoadEdges
+func WriteEdges(filePath string, edgePairs [][2]string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create edges file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriterSize(file, maxScanBufferSize)
+	for _, pair := range edgePairs {
+		fmt.Fprintf(w, "\"%s\",\"%s\"\n", pair[0], pair[1])
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write edges file: %w", err)
+	}
+	return file.Close()
+}
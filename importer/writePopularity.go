@@ -0,0 +1,26 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// WritePopularity writes name/popularity pairs in the popularity_iw.csv format
+// read by LoadPopularity. Names are written in the order given.
+func WritePopularity(filePath string, names []string, popularity []int64) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create popularity file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriterSize(file, maxScanBufferSize)
+	for i, name := range names {
+		fmt.Fprintf(w, "\"%s\",%d\n", name, popularity[i])
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write popularity file: %w", err)
+	}
+	return file.Close()
+}
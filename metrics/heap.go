@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// heapMetric is the runtime metric for memory held by live and not yet
+// swept heap objects
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// SampleHeap raises g to the heap size every interval until stop is called.
+// Unlike runtime.ReadMemStats, reading it does not stop the world.
+func SampleHeap(g *Gauge, interval time.Duration) (stop func()) {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	read := func() {
+		metrics.Read(sample)
+		if sample[0].Value.Kind() == metrics.KindUint64 {
+			g.SetMax(float64(sample[0].Value.Uint64()))
+		}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			read()
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+		read()
+	}
+}
@@ -0,0 +1,323 @@
+// Package metrics collects counters, gauges and histograms for a single run
+// of a command and writes them as JSON or in the Prometheus text format, for
+// the node exporter textfile collector.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types
+const (
+	CounterType   = "counter"
+	GaugeType     = "gauge"
+	HistogramType = "histogram"
+)
+
+// Registry holds the metrics of a run. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// Default is the registry the commands record into
+var Default = NewRegistry()
+
+// family is a metric name with all its labeled series
+type family struct {
+	name, help, kind string
+	buckets          []float64
+	series           map[string]*series
+}
+
+// series is one labeled time series of a family
+type series struct {
+	labels []string // name, value pairs
+	value  float64
+	// histogram state; counts are per bucket, not cumulative
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// lookup returns the series of name with the given labels, creating the
+// family and the series as needed
+func (r *Registry) lookup(name, help, kind string, buckets []float64, labels []string) *series {
+	if len(labels)%2 != 0 {
+		panic(fmt.Sprintf("metrics: odd number of label arguments for %s", name))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, kind: kind, buckets: buckets, series: make(map[string]*series)}
+		r.families[name] = f
+	} else if f.kind != kind {
+		panic(fmt.Sprintf("metrics: %s registered as %s and %s", name, f.kind, kind))
+	}
+	key := strings.Join(labels, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		if kind == HistogramType {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	r *Registry
+	s *series
+}
+
+// Counter returns the counter name with labels given as name, value pairs
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r, r.lookup(name, help, CounterType, nil, labels)}
+}
+
+// Add increases the counter by delta
+func (c *Counter) Add(delta float64) {
+	c.r.mu.Lock()
+	c.s.value += delta
+	c.r.mu.Unlock()
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	r *Registry
+	s *series
+}
+
+// Gauge returns the gauge name with labels given as name, value pairs
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r, r.lookup(name, help, GaugeType, nil, labels)}
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.r.mu.Lock()
+	g.s.value = v
+	g.r.mu.Unlock()
+}
+
+// SetMax raises the gauge to v if v is larger
+func (g *Gauge) SetMax(v float64) {
+	g.r.mu.Lock()
+	g.s.value = math.Max(g.s.value, v)
+	g.r.mu.Unlock()
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	r       *Registry
+	s       *series
+	buckets []float64
+}
+
+// Histogram returns the histogram name with the given ascending bucket upper
+// bounds and labels given as name, value pairs. The buckets of the first
+// call for a name are kept.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	s := r.lookup(name, help, HistogramType, buckets, labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Histogram{r, s, r.families[name].buckets}
+}
+
+// Observe adds one observation
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.r.mu.Lock()
+	h.s.counts[i]++
+	h.s.sum += v
+	h.s.count++
+	h.r.mu.Unlock()
+}
+
+// sortedFamilies returns the families by name and each family's series by
+// labels. The registry must be locked.
+func (r *Registry) sortedFamilies() ([]*family, map[*family][]*series) {
+	families := make([]*family, 0, len(r.families))
+	series := make(map[*family][]*series, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series[f] = append(series[f], f.series[key])
+		}
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+	return families, series
+}
+
+// Bucket is a cumulative histogram bucket in JSON output
+type Bucket struct {
+	// Le is the upper bound, "+Inf" for the last bucket
+	Le    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// Sample is one series in JSON output
+type Sample struct {
+	Name    string            `json:"name"`
+	Help    string            `json:"help,omitempty"`
+	Type    string            `json:"type"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Value   *float64          `json:"value,omitempty"`
+	Buckets []Bucket          `json:"buckets,omitempty"`
+	Sum     *float64          `json:"sum,omitempty"`
+	Count   *uint64           `json:"count,omitempty"`
+}
+
+// Samples returns a snapshot of every series, sorted by name and labels
+func (r *Registry) Samples() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	families, series := r.sortedFamilies()
+	var samples []Sample
+	for _, f := range families {
+		for _, s := range series[f] {
+			sample := Sample{Name: f.name, Help: f.help, Type: f.kind}
+			if len(s.labels) > 0 {
+				sample.Labels = make(map[string]string, len(s.labels)/2)
+				for i := 0; i < len(s.labels); i += 2 {
+					sample.Labels[s.labels[i]] = s.labels[i+1]
+				}
+			}
+			if f.kind == HistogramType {
+				cumulative := uint64(0)
+				for i, count := range s.counts {
+					cumulative += count
+					sample.Buckets = append(sample.Buckets, Bucket{Le: bound(f.buckets, i), Count: cumulative})
+				}
+				sum, count := s.sum, s.count
+				sample.Sum, sample.Count = &sum, &count
+			} else {
+				value := s.value
+				sample.Value = &value
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// bound formats the upper bound of bucket i
+func bound(buckets []float64, i int) string {
+	if i == len(buckets) {
+		return "+Inf"
+	}
+	return formatValue(buckets[i])
+}
+
+// formatValue formats a sample value the way Prometheus parses it
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteJSON writes every series as an indented JSON object
+func (r *Registry) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Metrics []Sample `json:"metrics"`
+	}{r.Samples()})
+}
+
+// labelEscaper escapes label values for the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders labels as {a="x",b="y"}, with extra appended
+func formatLabels(labels []string, extra ...string) string {
+	all := append(append([]string{}, labels...), extra...)
+	if len(all) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(all)/2)
+	for i := 0; i < len(all); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, all[i], labelEscaper.Replace(all[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// WriteText writes every series in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	families, series := r.sortedFamilies()
+	var b strings.Builder
+	for _, f := range families {
+		if f.help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", f.name, strings.ReplaceAll(f.help, "\n", " "))
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+		for _, s := range series[f] {
+			if f.kind != HistogramType {
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, formatLabels(s.labels), formatValue(s.value))
+				continue
+			}
+			cumulative := uint64(0)
+			for i, count := range s.counts {
+				cumulative += count
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(s.labels, "le", bound(f.buckets, i)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, formatLabels(s.labels), formatValue(s.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", f.name, formatLabels(s.labels), s.count)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteFile writes to a temporary file next to path and renames it into
+// place, so a collector never reads a half written file
+func WriteFile(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("dbcli_batches_total", "Batches sent.", "phase", "vertices").Add(2)
+	r.Counter("dbcli_batches_total", "Batches sent.", "phase", "edges").Add(1)
+	r.Gauge("dbcli_peak", "", "path", `a"b`).SetMax(3)
+	h := r.Histogram("dbcli_batch_seconds", "Batch latency.", []float64{0.1, 1}, "phase", "edges")
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var b bytes.Buffer
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP dbcli_batch_seconds Batch latency.
+# TYPE dbcli_batch_seconds histogram
+dbcli_batch_seconds_bucket{phase="edges",le="0.1"} 1
+dbcli_batch_seconds_bucket{phase="edges",le="1"} 2
+dbcli_batch_seconds_bucket{phase="edges",le="+Inf"} 3
+dbcli_batch_seconds_sum{phase="edges"} 5.55
+dbcli_batch_seconds_count{phase="edges"} 3
+# HELP dbcli_batches_total Batches sent.
+# TYPE dbcli_batches_total counter
+dbcli_batches_total{phase="edges"} 1
+dbcli_batches_total{phase="vertices"} 2
+# TYPE dbcli_peak gauge
+dbcli_peak{path="a\"b"} 3
+`
+	if b.String() != want {
+		t.Errorf("WriteText =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteJSONAndFile(t *testing.T) {
+	r := NewRegistry()
+	r.Gauge("up", "").Set(1)
+	r.Histogram("latency", "", []float64{1}).Observe(2)
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	if err := WriteFile(path, r.WriteJSON); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Metrics []Sample `json:"metrics"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Metrics) != 2 {
+		t.Fatalf("got %d samples, want 2", len(decoded.Metrics))
+	}
+	latency := decoded.Metrics[0]
+	if latency.Name != "latency" || *latency.Count != 1 || latency.Buckets[0].Count != 0 || latency.Buckets[1].Le != "+Inf" {
+		t.Errorf("unexpected histogram sample %+v", latency)
+	}
+	if up := decoded.Metrics[1]; *up.Value != 1 {
+		t.Errorf("up = %v, want 1", *up.Value)
+	}
+}
+
+func TestSampleHeap(t *testing.T) {
+	r := NewRegistry()
+	g := r.Gauge("heap", "")
+	stop := SampleHeap(g, time.Millisecond)
+	stop()
+	if samples := r.Samples(); *samples[0].Value <= 0 {
+		t.Errorf("peak heap = %v, want > 0", *samples[0].Value)
+	}
+}
+
+func TestResourceSampler(t *testing.T) {
+	sampler := StartResourceSampler(time.Millisecond, -1)
+	time.Sleep(20 * time.Millisecond)
+	series := sampler.Stop()
+	if len(series) != 2 {
+		t.Fatalf("got %d series, want 2", len(series))
+	}
+	self := series[0]
+	if !self.Self || len(self.Samples) < 2 {
+		t.Fatalf("self series has %d samples", len(self.Samples))
+	}
+	last := self.Samples[len(self.Samples)-1]
+	if last.Goroutines == 0 || last.HeapBytes == 0 {
+		t.Errorf("runtime fields missing: %+v", last)
+	}
+	if summary := self.Summarize(); summary.DurationSec <= 0 {
+		t.Errorf("summary duration = %v", summary.DurationSec)
+	}
+	if series[1].Error == "" || len(series[1].Samples) != 0 {
+		t.Errorf("expected an error for an invalid pid, got %+v", series[1])
+	}
+}
+
+func TestResourceSeriesBetween(t *testing.T) {
+	started := time.Now()
+	series := ResourceSeries{Started: started}
+	for i, cpu := range []float64{0, 1, 3, 6, 10} {
+		series.Samples = append(series.Samples, ResourceSample{ElapsedSec: float64(i), CPUSec: cpu, GCPauseSec: 1})
+	}
+	at := func(sec float64) time.Time { return started.Add(time.Duration(sec * float64(time.Second))) }
+
+	// 1.5s to 2.5s is bracketed by the samples at 1s and 3s
+	window := series.Between(at(1.5), at(2.5))
+	if len(window.Samples) != 3 || window.Samples[0].ElapsedSec != 1 {
+		t.Fatalf("window = %+v, want the samples at 1s to 3s", window.Samples)
+	}
+	if summary := window.Summarize(); summary.CPUSec != 5 || summary.GCPauseSec != 2 || summary.DurationSec != 2 {
+		t.Errorf("summary = %+v, want 5s CPU and 2s pauses over 2s", summary)
+	}
+
+	// past the last sample the window ends with it
+	if window := series.Between(at(3.5), at(9)); len(window.Samples) != 2 {
+		t.Errorf("window = %+v, want the samples at 3s and 4s", window.Samples)
+	}
+}
@@ -0,0 +1,291 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is the unit of the CPU times in /proc/<pid>/stat. It is 100 on
+// every mainstream Linux build and cannot be queried without cgo.
+const clockTicks = 100
+
+// ResourceSample is the resource use of a process at one point in time.
+// The runtime fields are only filled in for the dbcli process itself.
+type ResourceSample struct {
+	ElapsedSec float64 `json:"elapsedSec"`
+	// CPUSec is the user and system CPU time used so far
+	CPUSec float64 `json:"cpuSec"`
+	// CPUPercent is the CPU use since the previous sample, 100 being one core
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   int64   `json:"rssBytes"`
+	Threads    int     `json:"threads"`
+
+	HeapBytes  uint64 `json:"heapBytes,omitempty"`
+	Goroutines int    `json:"goroutines,omitempty"`
+	GCCycles   uint32 `json:"gcCycles,omitempty"`
+	// GCPauseSec and GCMaxPauseSec cover the pauses since the previous
+	// sample, estimated from the runtime's pause histogram buckets
+	GCPauseSec    float64 `json:"gcPauseSec,omitempty"`
+	GCMaxPauseSec float64 `json:"gcMaxPauseSec,omitempty"`
+}
+
+// runtimeMetrics are the runtime/metrics read for every sample of dbcli, in
+// the order sampleRuntime expects them
+var runtimeMetrics = []string{
+	heapMetric,
+	"/sched/goroutines:goroutines",
+	"/gc/cycles/total:gc-cycles",
+	"/sched/pauses/total/gc:seconds",
+}
+
+// ResourceSeries is the samples of one process
+type ResourceSeries struct {
+	PID  int  `json:"pid"`
+	Self bool `json:"self"`
+	// Started is the time the samples' ElapsedSec counts from
+	Started time.Time `json:"started"`
+	// Error is set when the process could not be sampled
+	Error   string           `json:"error,omitempty"`
+	Samples []ResourceSample `json:"samples"`
+}
+
+// procStat holds the fields of /proc/<pid>/stat used for sampling
+type procStat struct {
+	cpuSec   float64
+	rssBytes int64
+	threads  int
+}
+
+// readProcStat reads /proc/<pid>/stat; pid 0 means the calling process
+func readProcStat(pid int) (procStat, error) {
+	path := "/proc/self/stat"
+	if pid != 0 {
+		path = fmt.Sprintf("/proc/%d/stat", pid)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return procStat{}, err
+	}
+	// the command name is in parentheses and may contain spaces
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return procStat{}, fmt.Errorf("unexpected format of %s", path)
+	}
+	// fields after the name start at field 3 (state)
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("unexpected format of %s", path)
+	}
+	field := func(n int) int64 {
+		v, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return v
+	}
+	return procStat{
+		cpuSec:   float64(field(14)+field(15)) / clockTicks,
+		threads:  int(field(20)),
+		rssBytes: field(24) * int64(os.Getpagesize()),
+	}, nil
+}
+
+// ResourceSampler samples the resource use of dbcli and optionally of other
+// processes, such as the database server, at a fixed interval. The Go
+// runtime is read through runtime/metrics, which unlike
+// runtime.ReadMemStats does not stop the world.
+type ResourceSampler struct {
+	interval time.Duration
+	started  time.Time
+	series   []*ResourceSeries
+	runtime  []metrics.Sample
+	// lastPauses is the GC pause histogram at the previous sample
+	lastPauses []uint64
+	done       chan struct{}
+	finished   sync.WaitGroup
+}
+
+// StartResourceSampler starts sampling dbcli and the processes in pids. On
+// systems without /proc only the runtime fields are sampled.
+func StartResourceSampler(interval time.Duration, pids ...int) *ResourceSampler {
+	s := &ResourceSampler{
+		interval: interval,
+		started:  time.Now(),
+		done:     make(chan struct{}),
+	}
+	s.series = []*ResourceSeries{{PID: os.Getpid(), Self: true, Started: s.started, Samples: []ResourceSample{}}}
+	for _, pid := range pids {
+		s.series = append(s.series, &ResourceSeries{PID: pid, Started: s.started, Samples: []ResourceSample{}})
+	}
+	for _, name := range runtimeMetrics {
+		s.runtime = append(s.runtime, metrics.Sample{Name: name})
+	}
+	metrics.Read(s.runtime)
+	s.lastPauses = pauseCounts(s.runtime[3].Value)
+
+	s.finished.Add(1)
+	go func() {
+		defer s.finished.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.sample()
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Stop takes a last sample and returns the series, dbcli first
+func (s *ResourceSampler) Stop() []ResourceSeries {
+	close(s.done)
+	s.finished.Wait()
+	s.sample()
+	series := make([]ResourceSeries, len(s.series))
+	for i, one := range s.series {
+		series[i] = *one
+	}
+	return series
+}
+
+// sample appends one sample to every series that can still be read
+func (s *ResourceSampler) sample() {
+	elapsed := time.Since(s.started).Seconds()
+	for _, series := range s.series {
+		if series.Error != "" {
+			continue
+		}
+		pid := series.PID
+		if series.Self {
+			pid = 0
+		}
+		stat, err := readProcStat(pid)
+		if err != nil && !series.Self {
+			// the process is gone or not visible, e.g. in another container
+			series.Error = err.Error()
+			continue
+		}
+		sample := ResourceSample{ElapsedSec: elapsed, CPUSec: stat.cpuSec, RSSBytes: stat.rssBytes, Threads: stat.threads}
+		if n := len(series.Samples); n > 0 {
+			previous := series.Samples[n-1]
+			if dt := elapsed - previous.ElapsedSec; dt > 0 {
+				sample.CPUPercent = (sample.CPUSec - previous.CPUSec) / dt * 100
+			}
+		}
+		if series.Self {
+			s.sampleRuntime(&sample)
+		}
+		series.Samples = append(series.Samples, sample)
+	}
+}
+
+// sampleRuntime fills in the Go runtime fields
+func (s *ResourceSampler) sampleRuntime(sample *ResourceSample) {
+	metrics.Read(s.runtime)
+	sample.HeapBytes = uint64Value(s.runtime[0].Value)
+	sample.Goroutines = int(uint64Value(s.runtime[1].Value))
+	sample.GCCycles = uint32(uint64Value(s.runtime[2].Value))
+
+	// the pause histogram only has buckets, so each new pause is counted
+	// at the middle of its bucket
+	counts := pauseCounts(s.runtime[3].Value)
+	if len(counts) == len(s.lastPauses) {
+		buckets := s.runtime[3].Value.Float64Histogram().Buckets
+		for i, count := range counts {
+			added := count - s.lastPauses[i]
+			if added == 0 {
+				continue
+			}
+			low, high := buckets[i], buckets[i+1]
+			if math.IsInf(low, -1) {
+				low = 0
+			}
+			if math.IsInf(high, 1) {
+				high = low
+			}
+			sample.GCPauseSec += float64(added) * (low + high) / 2
+			sample.GCMaxPauseSec = max(sample.GCMaxPauseSec, high)
+		}
+	}
+	s.lastPauses = counts
+}
+
+// uint64Value returns a runtime metric, or 0 if the runtime does not
+// support it
+func uint64Value(value metrics.Value) uint64 {
+	if value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return value.Uint64()
+}
+
+// pauseCounts copies the bucket counts of the GC pause histogram
+func pauseCounts(value metrics.Value) []uint64 {
+	if value.Kind() != metrics.KindFloat64Histogram {
+		return nil
+	}
+	return append([]uint64(nil), value.Float64Histogram().Counts...)
+}
+
+// Between returns the samples that bracket the time from start to end: the
+// last one taken at or before start through the first one at or after end
+func (series ResourceSeries) Between(start, end time.Time) ResourceSeries {
+	from, to := start.Sub(series.Started).Seconds(), end.Sub(series.Started).Seconds()
+	first, last := 0, len(series.Samples)
+	for i, sample := range series.Samples {
+		if sample.ElapsedSec <= from {
+			first = i
+		}
+		if sample.ElapsedSec >= to {
+			last = i + 1
+			break
+		}
+	}
+	window := series
+	window.Samples = series.Samples[first:last]
+	return window
+}
+
+// Summary is the peak and total resource use of a series
+type Summary struct {
+	PeakRSSBytes   int64   `json:"peakRssBytes"`
+	CPUSec         float64 `json:"cpuSec"`
+	PeakCPUPercent float64 `json:"peakCpuPercent"`
+	PeakHeapBytes  uint64  `json:"peakHeapBytes,omitempty"`
+	PeakGoroutines int     `json:"peakGoroutines,omitempty"`
+	GCCycles       uint32  `json:"gcCycles,omitempty"`
+	GCPauseSec     float64 `json:"gcPauseSec,omitempty"`
+	GCMaxPauseSec  float64 `json:"gcMaxPauseSec,omitempty"`
+	DurationSec    float64 `json:"durationSec"`
+}
+
+// Summarize returns the peaks and totals of the series
+func (series ResourceSeries) Summarize() Summary {
+	var summary Summary
+	if len(series.Samples) == 0 {
+		return summary
+	}
+	first, last := series.Samples[0], series.Samples[len(series.Samples)-1]
+	summary.CPUSec = last.CPUSec - first.CPUSec
+	summary.DurationSec = last.ElapsedSec - first.ElapsedSec
+	summary.GCCycles = last.GCCycles - first.GCCycles
+	for i, sample := range series.Samples {
+		summary.PeakRSSBytes = max(summary.PeakRSSBytes, sample.RSSBytes)
+		summary.PeakHeapBytes = max(summary.PeakHeapBytes, sample.HeapBytes)
+		summary.PeakGoroutines = max(summary.PeakGoroutines, sample.Goroutines)
+		// CPU use and pauses of the first sample happened before it
+		if i > 0 {
+			summary.PeakCPUPercent = max(summary.PeakCPUPercent, sample.CPUPercent)
+			summary.GCPauseSec += sample.GCPauseSec
+			summary.GCMaxPauseSec = max(summary.GCMaxPauseSec, sample.GCMaxPauseSec)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,391 @@
+package orientdbtest
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// Exception classes reported in error responses
+const (
+	parsingException                = "com.orientechnologies.orient.core.sql.OCommandSQLParsingException"
+	executionException              = "com.orientechnologies.orient.core.exception.OCommandExecutionException"
+	schemaException                 = "com.orientechnologies.orient.core.exception.OSchemaException"
+	duplicatedException             = "com.orientechnologies.orient.core.storage.ORecordDuplicatedException"
+	notFoundException               = "com.orientechnologies.orient.core.exception.ORecordNotFoundException"
+	concurrentModificationException = "com.orientechnologies.orient.core.exception.OConcurrentModificationException"
+)
+
+// dbError is a failed request, reported with an HTTP status and the
+// exception OrientDB would throw
+type dbError struct {
+	status    int
+	exception string
+	message   string
+}
+
+func (e *dbError) Error() string {
+	return e.exception + ": " + e.message
+}
+
+// errorf returns a dbError with a formatted message
+func errorf(status int, exception, format string, args ...interface{}) *dbError {
+	return &dbError{status: status, exception: exception, message: fmt.Sprintf(format, args...)}
+}
+
+// record is a document; vertices and edges are documents of classes
+// extending V and E
+type record struct {
+	rid     string
+	class   string
+	version int
+	fields  map[string]interface{}
+}
+
+// document returns the record the way the REST API serializes it
+func (r *record) document() map[string]interface{} {
+	doc := map[string]interface{}{
+		"@type":    "d",
+		"@rid":     r.rid,
+		"@version": r.version,
+		"@class":   r.class,
+	}
+	for field, value := range r.fields {
+		doc[field] = value
+	}
+	return doc
+}
+
+// database is the in-memory store behind the server. It is not safe for
+// concurrent use; the server serializes requests.
+type database struct {
+	// superclasses maps every class to the class it extends, "" for none
+	superclasses map[string]string
+	clusters     map[string]int
+	positions    map[int]int
+	nextCluster  int
+	properties   map[string]map[string]string
+	// indexes maps "Class.property" to whether the index is unique
+	indexes map[string]bool
+
+	records []*record
+	byRID   map[string]*record
+	// out and in list the rids of the edges leaving and entering a vertex
+	out, in map[string][]string
+}
+
+// newDatabase returns a database with the V and E base classes
+func newDatabase() *database {
+	db := &database{
+		superclasses: make(map[string]string),
+		clusters:     make(map[string]int),
+		positions:    make(map[int]int),
+		nextCluster:  9,
+		properties:   make(map[string]map[string]string),
+		indexes:      make(map[string]bool),
+		byRID:        make(map[string]*record),
+		out:          make(map[string][]string),
+		in:           make(map[string][]string),
+	}
+	db.createClass("V", "")
+	db.createClass("E", "")
+	return db
+}
+
+// clone returns a deep copy, used to roll back a failed batch
+func (db *database) clone() *database {
+	c := *db
+	c.superclasses = maps.Clone(db.superclasses)
+	c.clusters = maps.Clone(db.clusters)
+	c.positions = maps.Clone(db.positions)
+	c.properties = make(map[string]map[string]string, len(db.properties))
+	for class, props := range db.properties {
+		c.properties[class] = maps.Clone(props)
+	}
+	c.indexes = maps.Clone(db.indexes)
+	c.records = make([]*record, len(db.records))
+	c.byRID = make(map[string]*record, len(db.byRID))
+	for i, r := range db.records {
+		copied := *r
+		copied.fields = maps.Clone(r.fields)
+		c.records[i] = &copied
+		c.byRID[r.rid] = &copied
+	}
+	c.out = make(map[string][]string, len(db.out))
+	for rid, edges := range db.out {
+		c.out[rid] = slices.Clone(edges)
+	}
+	c.in = make(map[string][]string, len(db.in))
+	for rid, edges := range db.in {
+		c.in[rid] = slices.Clone(edges)
+	}
+	return &c
+}
+
+// createClass adds a class extending superclass
+func (db *database) createClass(class, superclass string) error {
+	if _, ok := db.superclasses[class]; ok {
+		return errorf(http.StatusInternalServerError, schemaException, "Class '%s' already exists in current database", class)
+	}
+	if _, ok := db.superclasses[superclass]; superclass != "" && !ok {
+		return errorf(http.StatusInternalServerError, schemaException, "Super-class '%s' not found", superclass)
+	}
+	db.superclasses[class] = superclass
+	db.clusters[class] = db.nextCluster
+	db.nextCluster++
+	return nil
+}
+
+// hasClass reports whether class exists
+func (db *database) hasClass(class string) bool {
+	_, ok := db.superclasses[class]
+	return ok
+}
+
+// isA reports whether class is target or extends it
+func (db *database) isA(class, target string) bool {
+	for class != "" {
+		if class == target {
+			return true
+		}
+		class = db.superclasses[class]
+	}
+	return false
+}
+
+// classRecords returns the records of class and its subclasses
+func (db *database) classRecords(class string) ([]*record, error) {
+	if !db.hasClass(class) {
+		return nil, errorf(http.StatusInternalServerError, executionException, "Class not found: %s", class)
+	}
+	var result []*record
+	for _, r := range db.records {
+		if db.isA(r.class, class) {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// createProperty declares a property of class
+func (db *database) createProperty(class, property, propertyType string) error {
+	if !db.hasClass(class) {
+		return errorf(http.StatusNotFound, schemaException, "Class '%s' not found", class)
+	}
+	if db.properties[class] == nil {
+		db.properties[class] = make(map[string]string)
+	}
+	if _, ok := db.properties[class][property]; ok {
+		return errorf(http.StatusInternalServerError, schemaException, "Property '%s.%s' already exists", class, property)
+	}
+	db.properties[class][property] = propertyType
+	return nil
+}
+
+// createIndex adds an index on class.property; a unique index is enforced
+// from then on and fails to build over duplicate values
+func (db *database) createIndex(class, property string, unique bool) error {
+	if !db.hasClass(class) {
+		return errorf(http.StatusInternalServerError, executionException, "Class not found: %s", class)
+	}
+	key := class + "." + property
+	if _, ok := db.indexes[key]; ok {
+		return errorf(http.StatusInternalServerError, executionException, "Index with name %s already exists", key)
+	}
+	if unique {
+		seen := make(map[interface{}]bool)
+		records, _ := db.classRecords(class)
+		for _, r := range records {
+			value, ok := r.fields[property]
+			if !ok {
+				continue
+			}
+			if seen[value] {
+				return errorf(http.StatusConflict, duplicatedException, "Cannot index record %s: found duplicated key '%v' in index '%s'", r.rid, value, key)
+			}
+			seen[value] = true
+		}
+	}
+	db.indexes[key] = unique
+	return nil
+}
+
+// checkUnique fails if storing value in field of r would violate a unique index
+func (db *database) checkUnique(r *record, field string, value interface{}) error {
+	for class := r.class; class != ""; class = db.superclasses[class] {
+		key := class + "." + field
+		if !db.indexes[key] {
+			continue
+		}
+		records, _ := db.classRecords(class)
+		for _, other := range records {
+			if other != r && other.fields[field] == value {
+				return errorf(http.StatusConflict, duplicatedException, "Cannot index record %s: found duplicated key '%v' in index '%s' previously assigned to the record %s", r.rid, value, key, other.rid)
+			}
+		}
+	}
+	return nil
+}
+
+// insert creates a record of class with the given fields
+func (db *database) insert(class string, fields map[string]interface{}) (*record, error) {
+	if !db.hasClass(class) {
+		return nil, errorf(http.StatusInternalServerError, schemaException, "Class '%s' not found", class)
+	}
+	cluster := db.clusters[class]
+	r := &record{
+		rid:     fmt.Sprintf("#%d:%d", cluster, db.positions[cluster]),
+		class:   class,
+		version: 1,
+		fields:  make(map[string]interface{}, len(fields)),
+	}
+	for field, value := range fields {
+		if err := db.checkUnique(r, field, value); err != nil {
+			return nil, err
+		}
+		r.fields[field] = value
+	}
+	db.positions[cluster]++
+	db.records = append(db.records, r)
+	db.byRID[r.rid] = r
+	return r, nil
+}
+
+// update sets fields of r, deleting those set to nil
+func (db *database) update(r *record, fields map[string]interface{}) error {
+	for field, value := range fields {
+		if err := db.checkUnique(r, field, value); err != nil {
+			return err
+		}
+	}
+	for field, value := range fields {
+		if value == nil {
+			delete(r.fields, field)
+		} else {
+			r.fields[field] = value
+		}
+	}
+	r.version++
+	return nil
+}
+
+// createEdge connects two vertices with an edge of class
+func (db *database) createEdge(class, fromRID, toRID string) (*record, error) {
+	if !db.isA(class, "E") {
+		return nil, errorf(http.StatusInternalServerError, executionException, "Class '%s' is not an edge class", class)
+	}
+	from, to := db.byRID[fromRID], db.byRID[toRID]
+	if from == nil || to == nil || !db.isA(from.class, "V") || !db.isA(to.class, "V") {
+		return nil, errorf(http.StatusInternalServerError, notFoundException, "Vertex %s or %s not found", fromRID, toRID)
+	}
+	edge, err := db.insert(class, map[string]interface{}{"out": fromRID, "in": toRID})
+	if err != nil {
+		return nil, err
+	}
+	db.out[fromRID] = append(db.out[fromRID], edge.rid)
+	db.in[toRID] = append(db.in[toRID], edge.rid)
+	from.version++
+	to.version++
+	return edge, nil
+}
+
+// neighbors returns the vertices adjacent to r in direction dir, one per
+// edge: out, in or both
+func (db *database) neighbors(r *record, dir string) []*record {
+	var result []*record
+	if dir == "out" || dir == "both" {
+		for _, edge := range db.out[r.rid] {
+			result = append(result, db.byRID[db.byRID[edge].fields["in"].(string)])
+		}
+	}
+	if dir == "in" || dir == "both" {
+		for _, edge := range db.in[r.rid] {
+			result = append(result, db.byRID[db.byRID[edge].fields["out"].(string)])
+		}
+	}
+	return result
+}
+
+// traverse visits the records reachable from starts, each once, and
+// returns them in visiting order. Like OrientDB it walks depth first unless
+// breadthFirst is set, so with a depth limit a record first met along a long
+// path is not expanded again when a shorter path reaches it. A record is
+// only visited, and expanded, when allowed accepts it at its depth.
+func (db *database) traverse(starts []*record, dir string, allowed func(r *record, depth int) bool, breadthFirst bool) []*record {
+	visited := make(map[string]bool)
+	var result []*record
+	if !breadthFirst {
+		var visit func(r *record, depth int)
+		visit = func(r *record, depth int) {
+			if visited[r.rid] || !allowed(r, depth) {
+				return
+			}
+			visited[r.rid] = true
+			result = append(result, r)
+			for _, next := range db.neighbors(r, dir) {
+				visit(next, depth+1)
+			}
+		}
+		for _, r := range starts {
+			visit(r, 0)
+		}
+		return result
+	}
+
+	type visit struct {
+		r     *record
+		depth int
+	}
+	var queue []visit
+	for _, r := range starts {
+		queue = append(queue, visit{r, 0})
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		if visited[v.r.rid] || !allowed(v.r, v.depth) {
+			continue
+		}
+		visited[v.r.rid] = true
+		result = append(result, v.r)
+		for _, next := range db.neighbors(v.r, dir) {
+			queue = append(queue, visit{next, v.depth + 1})
+		}
+	}
+	return result
+}
+
+// shortestPath returns the vertices of a shortest path from src to dst
+// ignoring edge direction, with at most maxDepth edges, or nil if there is
+// none
+func (db *database) shortestPath(src, dst *record, maxDepth int) []*record {
+	previous := map[string]*record{src.rid: nil}
+	frontier := []*record{src}
+	for depth := 0; len(frontier) > 0; depth++ {
+		for _, r := range frontier {
+			if r == dst {
+				var path []*record
+				for ; r != nil; r = previous[r.rid] {
+					path = append(path, r)
+				}
+				slices.Reverse(path)
+				return path
+			}
+		}
+		if depth == maxDepth {
+			break
+		}
+		var next []*record
+		for _, r := range frontier {
+			for _, n := range db.neighbors(r, "both") {
+				if _, ok := previous[n.rid]; !ok {
+					previous[n.rid] = r
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
@@ -0,0 +1,517 @@
+// Package orientdbtest provides an in-memory stand-in for the OrientDB REST
+// API, so commands can be tested without a running database. It serves the
+// endpoints dbcli uses (/database, /class, /property, /command, /query and
+// /batch) and understands the SQL statements dbcli issues, not SQL in
+// general: anything else fails the way a parse error does. TRAVERSE runs
+// depth first unless its STRATEGY is BREADTH_FIRST, as in OrientDB.
+//
+// Faults can be injected to test how callers handle slow or failing
+// requests.
+package orientdbtest
+
+import (
+	"bytes"
+	"dbcli/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials and the database the server hosts, matching the defaults of
+// utils.OrientDB
+const (
+	Database = "dbcli"
+	Username = "root"
+	Password = "rootpwd"
+)
+
+// Fault changes how the server answers matching requests
+type Fault struct {
+	// Path selects requests by URL path prefix, e.g. "/batch"; empty
+	// matches every request
+	Path string
+	// Latency delays the response
+	Latency time.Duration
+	// Status, if not 0, fails the request with this status instead of
+	// running it
+	Status int
+	// ConcurrentModification fails the request with 409 and the
+	// exception OrientDB reports when a record changed under a transaction
+	ConcurrentModification bool
+	// Times is how many requests the fault applies to, 0 for all
+	Times int
+}
+
+// Server is an OrientDB stand-in listening on a local port
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// db is nil until the database is created
+	db       *database
+	faults   []*fault
+	requests map[string]int
+}
+
+// fault is an injected Fault and the number of requests it applied to
+type fault struct {
+	Fault
+	used int
+}
+
+// NewServer starts a server without a database. Import creates it, or the
+// first AddVertex does.
+func NewServer() *Server {
+	s := &Server{requests: make(map[string]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /database/{db}", s.getDatabase)
+	mux.HandleFunc("POST /database/{db}/{storage}", s.postDatabase)
+	mux.HandleFunc("DELETE /database/{db}", s.deleteDatabase)
+	mux.HandleFunc("GET /class/{db}/{class}", s.getClass)
+	mux.HandleFunc("POST /class/{db}/{class}", s.postClass)
+	mux.HandleFunc("POST /class/{db}/{class}/{superclass}", s.postClass)
+	mux.HandleFunc("POST /property/{db}/{class}", s.postProperty)
+	mux.HandleFunc("POST /command/{db}/{language}", s.postCommand)
+	mux.HandleFunc("GET /query/{db}/{language}/{query...}", s.getQuery)
+	mux.HandleFunc("POST /batch/{db}", s.postBatch)
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// Connection returns the connection settings for the server, to be
+// assigned to utils.OrientDB
+func (s *Server) Connection() utils.Connection {
+	return utils.Connection{BaseURL: s.URL, Username: Username, Password: Password, Database: Database}
+}
+
+// Inject adds a fault. Faults are tried in the order they were added and
+// the first matching one applies.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes all faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns how many requests were received for paths starting with
+// prefix, including those failed by faults
+func (s *Server) Requests(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for path, count := range s.requests {
+		if strings.HasPrefix(path, prefix) {
+			n += count
+		}
+	}
+	return n
+}
+
+// AddVertex stores a vertex, creating the database with the schema of
+// dbcli import if it does not exist yet
+func (s *Server) AddVertex(name string, popularity int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		s.db = newDatabase()
+		for _, statement := range []string{"CREATE CLASS Vertex EXTENDS V", "CREATE CLASS Edge EXTENDS E", "CREATE INDEX Vertex.name UNIQUE"} {
+			if _, err := s.db.execute(statement); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := s.db.insert("Vertex", map[string]interface{}{"name": name, "popularity": int64(popularity)})
+	return err
+}
+
+// AddEdge connects two vertices added before
+func (s *Server) AddEdge(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return errors.New("no database")
+	}
+	fromVertex, toVertex := s.vertex(from), s.vertex(to)
+	if fromVertex == nil || toVertex == nil {
+		return fmt.Errorf("vertex %q or %q not found", from, to)
+	}
+	_, err := s.db.createEdge("Edge", fromVertex.rid, toVertex.rid)
+	return err
+}
+
+// vertex returns the vertex named name, or nil; the caller holds s.mu
+func (s *Server) vertex(name string) *record {
+	records, _ := s.db.classRecords("V")
+	for _, r := range records {
+		if r.fields["name"] == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Vertices returns the popularity of every vertex by name
+func (s *Server) Vertices() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vertices := make(map[string]int64)
+	if s.db == nil {
+		return vertices
+	}
+	records, _ := s.db.classRecords("V")
+	for _, r := range records {
+		name, _ := r.fields["name"].(string)
+		popularity, _ := number(r.fields["popularity"])
+		vertices[name] = int64(popularity)
+	}
+	return vertices
+}
+
+// Edges returns every edge as a pair of vertex names, sorted
+func (s *Server) Edges() [][2]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var edges [][2]string
+	if s.db == nil {
+		return edges
+	}
+	records, _ := s.db.classRecords("E")
+	for _, r := range records {
+		from, _ := s.db.byRID[r.fields["out"].(string)].fields["name"].(string)
+		to, _ := s.db.byRID[r.fields["in"].(string)].fields["name"].(string)
+		edges = append(edges, [2]string{from, to})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// middleware checks credentials, counts requests and applies faults
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != Username || password != Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="OrientDB db-dbcli"`)
+			writeError(w, errorf(http.StatusUnauthorized, "com.orientechnologies.orient.core.exception.OSecurityAccessException", "401 Unauthorized."))
+			return
+		}
+
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		var applied Fault
+		for _, f := range s.faults {
+			if strings.HasPrefix(r.URL.Path, f.Path) && (f.Times == 0 || f.used < f.Times) {
+				f.used++
+				applied = f.Fault
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		time.Sleep(applied.Latency)
+		switch {
+		case applied.ConcurrentModification:
+			writeError(w, errorf(http.StatusConflict, concurrentModificationException, "Cannot UPDATE the record #9:0 because the version is not the latest. Probably you are updating an old record or it has been modified by another user (db=v2 your=v1)"))
+		case applied.Status != 0:
+			writeError(w, errorf(applied.Status, "com.orientechnologies.common.exception.OException", "injected fault"))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// database returns the hosted database if the request names it; the
+// caller holds s.mu
+func (s *Server) database(r *http.Request) (*database, error) {
+	if r.PathValue("db") != Database || s.db == nil {
+		return nil, errorf(http.StatusNotFound, "com.orientechnologies.orient.core.exception.OStorageException", "Database '%s' does not exist", r.PathValue("db"))
+	}
+	return s.db, nil
+}
+
+func (s *Server) getDatabase(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, err := s.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	classes := make([]map[string]interface{}, 0, len(db.superclasses))
+	for class, superclass := range db.superclasses {
+		classes = append(classes, map[string]interface{}{"name": class, "superClass": superclass})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"classes": classes})
+}
+
+func (s *Server) postDatabase(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.PathValue("db") != Database {
+		writeError(w, errorf(http.StatusBadRequest, executionException, "orientdbtest only hosts the database %s", Database))
+		return
+	}
+	if s.db != nil {
+		writeError(w, errorf(http.StatusConflict, "com.orientechnologies.orient.core.exception.ODatabaseException", "Database named '%s' already exists", Database))
+		return
+	}
+	s.db = newDatabase()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"classes": []interface{}{}})
+}
+
+func (s *Server) deleteDatabase(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.database(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.db = nil
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getClass(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, err := s.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	class := r.PathValue("class")
+	if !db.hasClass(class) {
+		writeError(w, errorf(http.StatusNotFound, schemaException, "Invalid class '%s'", class))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"name": class, "superClass": db.superclasses[class]})
+}
+
+func (s *Server) postClass(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, err := s.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := db.createClass(r.PathValue("class"), r.PathValue("superclass")); err != nil {
+		writeError(w, err)
+		return
+	}
+	// like OrientDB, the body is the number of classes
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprint(w, len(db.superclasses))
+}
+
+func (s *Server) postProperty(w http.ResponseWriter, r *http.Request) {
+	var props map[string]struct {
+		PropertyType string `json:"propertyType"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&props); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, executionException, "invalid property definitions: %v", err))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, err := s.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	next := db.clone()
+	for name, prop := range props {
+		if err := next.createProperty(r.PathValue("class"), name, prop.PropertyType); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	s.db = next
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprint(w, len(props))
+}
+
+func (s *Server) postCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errorf(http.StatusBadRequest, executionException, "failed to read body: %v", err))
+		return
+	}
+	// the body is JSON with the command, or the command itself
+	var command utils.CommandBody
+	if json.Unmarshal(body, &command) != nil {
+		command.Command = string(body)
+	}
+	s.run(w, r, func(db *database) ([]map[string]interface{}, error) {
+		return db.execute(command.Command)
+	})
+}
+
+func (s *Server) getQuery(w http.ResponseWriter, r *http.Request) {
+	s.run(w, r, func(db *database) ([]map[string]interface{}, error) {
+		return db.query(strings.TrimSpace(r.PathValue("query")))
+	})
+}
+
+// batch is the body of a batch request
+type batch struct {
+	Transaction bool `json:"transaction"`
+	Operations  []struct {
+		Type     string                 `json:"type"`
+		Language string                 `json:"language"`
+		Command  string                 `json:"command"`
+		Record   map[string]interface{} `json:"record"`
+		Script   json.RawMessage        `json:"script"`
+	} `json:"operations"`
+}
+
+// postBatch runs the operations of a batch. A batch is atomic: if any
+// operation fails nothing is stored, like a transaction or a BEGIN/COMMIT
+// script in OrientDB.
+func (s *Server) postBatch(w http.ResponseWriter, r *http.Request) {
+	var request batch
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, executionException, "invalid batch: %v", err))
+		return
+	}
+	s.run(w, r, func(db *database) ([]map[string]interface{}, error) {
+		var result []map[string]interface{}
+		for _, op := range request.Operations {
+			switch op.Type {
+			case "c":
+				class, _ := op.Record["@class"].(string)
+				fields := make(map[string]interface{}, len(op.Record))
+				for field, value := range op.Record {
+					if !strings.HasPrefix(field, "@") {
+						fields[field] = jsonValue(value)
+					}
+				}
+				created, err := db.insert(class, fields)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, created.document())
+			case "cmd", "script":
+				lines, err := scriptLines(op.Command, op.Script)
+				if err != nil {
+					return nil, err
+				}
+				for _, line := range lines {
+					switch strings.ToUpper(line) {
+					case "", "BEGIN", "COMMIT", "ROLLBACK":
+						continue
+					}
+					if result, err = db.execute(line); err != nil {
+						return nil, err
+					}
+				}
+			default:
+				return nil, errorf(http.StatusBadRequest, executionException, "operation type %q not supported by orientdbtest", op.Type)
+			}
+		}
+		return result, nil
+	})
+}
+
+// scriptLines returns the statements of a cmd or script operation; a
+// script is a string or an array of lines
+func scriptLines(command string, script json.RawMessage) ([]string, error) {
+	var lines []string
+	if len(script) > 0 && script[0] == '[' {
+		if err := json.Unmarshal(script, &lines); err != nil {
+			return nil, errorf(http.StatusBadRequest, executionException, "invalid script: %v", err)
+		}
+	} else if len(script) > 0 {
+		var text string
+		if err := json.Unmarshal(script, &text); err != nil {
+			return nil, errorf(http.StatusBadRequest, executionException, "invalid script: %v", err)
+		}
+		lines = strings.Split(text, "\n")
+	} else {
+		lines = []string{command}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(strings.TrimSpace(line), ";")
+	}
+	return lines, nil
+}
+
+// jsonValue converts a decoded JSON value to the value stored for it
+func jsonValue(value interface{}) interface{} {
+	if n, ok := value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		f, _ := n.Float64()
+		return f
+	}
+	return value
+}
+
+// run executes a request on a copy of the database and keeps the copy only
+// if it succeeds
+func (s *Server) run(w http.ResponseWriter, r *http.Request, execute func(db *database) ([]map[string]interface{}, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, err := s.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	next := db.clone()
+	result, err := execute(next)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.db = next
+	if result == nil {
+		result = []map[string]interface{}{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+}
+
+// writeJSON writes value with the given status
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(value); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// writeError writes an error in the format of OrientDB
+func writeError(w http.ResponseWriter, err error) {
+	var dbErr *dbError
+	if !errors.As(err, &dbErr) {
+		dbErr = errorf(http.StatusInternalServerError, executionException, "%v", err)
+	}
+	writeJSON(w, dbErr.status, map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"code":    dbErr.status,
+			"reason":  dbErr.status,
+			"content": dbErr.Error(),
+		}},
+	})
+}
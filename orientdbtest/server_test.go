@@ -0,0 +1,181 @@
+package orientdbtest
+
+import (
+	"dbcli/utils"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer starts a server with a small graph and points
+// utils.OrientDB at it
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer()
+	t.Cleanup(s.Close)
+	saved := utils.OrientDB
+	utils.OrientDB = s.Connection()
+	t.Cleanup(func() { utils.OrientDB = saved })
+
+	// stored as #11:0 to #11:4
+	for i, name := range []string{"root", "a", "b", "c", "lone"} {
+		if err := s.AddVertex(name, i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, edge := range [][2]string{{"root", "a"}, {"root", "b"}, {"a", "c"}, {"b", "c"}} {
+		if err := s.AddEdge(edge[0], edge[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// names returns the sorted names of the result rows
+func names(result utils.ResultSet) []string {
+	var names []string
+	for _, row := range result.Result {
+		names = append(names, row["name"].(string))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestQueries(t *testing.T) {
+	newTestServer(t)
+	tests := []struct {
+		query string
+		names string
+	}{
+		{"SELECT expand(out()) FROM `Vertex` WHERE name = \"root\"", "a b"},
+		{"SELECT expand(out()).out() FROM `Vertex` WHERE name = \"root\"", "c c"},
+		{"SELECT expand(in().in()) FROM `Vertex` WHERE name = 'c'", "root root"},
+		{"SELECT * FROM `Vertex` WHERE in().size() = 0", "lone root"},
+		{"SELECT FROM `Vertex` WHERE out().size() = (SELECT max(out().size()) FROM `Vertex`)", "root"},
+		{"SELECT FROM `Vertex` WHERE out().size() = (SELECT min(out().size()) FROM `Vertex` WHERE out().size() > 0)", "a b"},
+		{"TRAVERSE out() FROM (SELECT FROM `Vertex` WHERE name = \"root\") WHILE $depth <= 1 AND name <> \"b\" STRATEGY BREADTH_FIRST", "a root"},
+		{"SELECT name FROM `Vertex` SKIP 1 LIMIT 2", "a b"},
+		{"SELECT name, popularity, out().name AS children FROM (TRAVERSE in() FROM (SELECT FROM `Vertex` WHERE name IN [\"c\"]) STRATEGY BREADTH_FIRST) LIMIT -1", "a b c root"},
+	}
+	for _, tt := range tests {
+		result, err := utils.ExecuteQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := strings.Join(names(result), " "); got != tt.names {
+			t.Errorf("%s = %s, want %s", tt.query, got, tt.names)
+		}
+	}
+
+	values := []struct {
+		query, field string
+		want         interface{}
+	}{
+		{"SELECT out().size() FROM `Vertex` WHERE name = \"root\"", "out().size()", 2.0},
+		{"SELECT count(distinct(name)) FROM `Vertex`", "count(distinct(name))", 5.0},
+		{"SELECT count(*) FROM `Vertex` WHERE name = \"missing\"", "count(*)", 0.0},
+		{"SELECT sum(popularity) FROM (TRAVERSE both() FROM (SELECT FROM `Vertex` WHERE name = \"a\") WHILE $depth <= 1 AND $depth <= 3 STRATEGY BREADTH_FIRST)", "sum(popularity)", 7.0},
+		{"SELECT sum(popularity) FROM (SELECT expand(path) FROM (SELECT shortestPath((SELECT FROM `Vertex` WHERE name = 'a'), (SELECT FROM `Vertex` WHERE name = 'root'), {maxDepth: 2}) AS path) UNWIND path)", "sum(popularity)", 3.0},
+		{"SELECT sum(popularity) FROM (SELECT expand(path) FROM (SELECT shortestPath((SELECT FROM `Vertex` WHERE name = 'a'), (SELECT FROM `Vertex` WHERE name = 'lone'), {maxDepth: 5}) AS path) UNWIND path)", "sum(popularity)", nil},
+		{"UPDATE `Vertex` SET popularity = 7 WHERE name = 'lone'", "count", 1.0},
+		{"SELECT popularity FROM `Vertex` WHERE name = \"lone\"", "popularity", 7.0},
+	}
+	for _, tt := range values {
+		result, err := utils.ExecuteQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if len(result.Result) != 1 || result.Result[0][tt.field] != tt.want {
+			t.Errorf("%s = %v, want %s = %v", tt.query, result.Result, tt.field, tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	newTestServer(t)
+	for query, status := range map[string]string{
+		"UPDATE `Vertex` SET name = 'a' WHERE name = 'b'":                          "409",
+		"SELECT stale FROM `Meta` WHERE name = \"aggregates\"":                     "500",
+		"SELECT name FROM `Vertex` WHERE SEARCH_INDEX(\"idx\", \"a*\", {}) = true": "500",
+		"CREATE CLASS `Vertex` EXTENDS V":                                          "500",
+	} {
+		if _, err := utils.ExecuteQuery(query); err == nil || !strings.Contains(err.Error(), "status: "+status) {
+			t.Errorf("%s: %v, want status %s", query, err, status)
+		}
+	}
+
+	utils.OrientDB.Password = "wrong"
+	if _, err := utils.ExecuteQuery("SELECT count(*) FROM `Vertex`"); err == nil || !strings.Contains(err.Error(), "status: 401") {
+		t.Errorf("wrong password: %v, want status 401", err)
+	}
+}
+
+func TestBatchIsAtomic(t *testing.T) {
+	s := newTestServer(t)
+	body := `{"transaction": false, "operations": [{"type": "script", "language": "sql", "script": [
+		"BEGIN;", "UPDATE #11:4 SET popularity = 9;", "CREATE EDGE ` + "`Edge`" + ` FROM #11:0 TO #11:99;", "COMMIT;"]}]}`
+	resp := post(t, s, "/batch/dbcli", body)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("batch with a missing vertex: status %d, want 500", resp.StatusCode)
+	}
+	if popularity := s.Vertices()["lone"]; popularity != 5 {
+		t.Errorf("popularity of lone = %d after a failed batch, want 5", popularity)
+	}
+
+	body = `{"transaction": true, "operations": [
+		{"type": "c", "record": {"@class": "Vertex", "name": "d", "popularity": 1}},
+		{"type": "script", "language": "sql", "script": ["CREATE EDGE ` + "`Edge`" + ` FROM #11:0 TO #11:5;"]}]}`
+	if resp := post(t, s, "/batch/dbcli", body); resp.StatusCode != http.StatusOK {
+		t.Fatalf("batch: status %d", resp.StatusCode)
+	}
+	if edges := s.Edges(); len(edges) != 5 || edges[4] != [2]string{"root", "d"} {
+		t.Errorf("edges = %v, want root -> d added", edges)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	s := newTestServer(t)
+	s.Inject(Fault{Path: "/command", Status: http.StatusServiceUnavailable, Times: 1})
+	s.Inject(Fault{Path: "/command", ConcurrentModification: true, Times: 1})
+	s.Inject(Fault{Latency: 20 * time.Millisecond})
+
+	query := "SELECT count(*) FROM `Vertex`"
+	if _, err := utils.ExecuteQuery(query); err == nil || !strings.Contains(err.Error(), "status: 503") {
+		t.Errorf("first request: %v, want status 503", err)
+	}
+	if _, err := utils.ExecuteQuery(query); err == nil || !strings.Contains(err.Error(), "OConcurrentModificationException") {
+		t.Errorf("second request: %v, want a concurrent modification", err)
+	}
+	start := time.Now()
+	if _, err := utils.ExecuteQuery(query); err != nil {
+		t.Errorf("third request: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("third request took %s, want the injected latency", elapsed)
+	}
+
+	s.ClearFaults()
+	if n := s.Requests("/command/"); n != 3 {
+		t.Errorf("%d command requests, want 3", n)
+	}
+}
+
+// post sends body to path on s
+func post(t *testing.T, s *Server, path, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(Username, Password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
@@ -0,0 +1,500 @@
+package orientdbtest
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Building blocks of the statement patterns
+const (
+	classPattern   = "`?(\\w+)`?"
+	stringPattern  = `("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')`
+	literalPattern = `("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|-?\d+(?:\.\d+)?|true|false|null)`
+	ridPattern     = `(#\d+:\d+)`
+	hopsPattern    = `((?:out|in|both)\(\)(?:\.(?:out|in|both)\(\))*)`
+)
+
+var (
+	alterDatabase   = regexp.MustCompile(`^ALTER DATABASE .+$`)
+	createClass     = regexp.MustCompile(`^CREATE CLASS ` + classPattern + `(?: EXTENDS ` + classPattern + `)?$`)
+	createIndex     = regexp.MustCompile("^CREATE INDEX `?(\\w+)\\.(\\w+)`? (UNIQUE|NOTUNIQUE)$")
+	createFullText  = regexp.MustCompile("^CREATE INDEX `?[\\w.]+`? ON " + classPattern + ` \((\w+)\) FULLTEXT ENGINE LUCENE$`)
+	createEdge      = regexp.MustCompile(`^CREATE EDGE ` + classPattern + ` FROM ` + ridPattern + ` TO ` + ridPattern + `$`)
+	updateWhere     = regexp.MustCompile(`^UPDATE ` + classPattern + ` SET (.+?)( UPSERT)? WHERE (.+)$`)
+	updateRID       = regexp.MustCompile(`^UPDATE ` + ridPattern + ` SET (.+)$`)
+	assignment      = regexp.MustCompile(`^(\w+) = ` + literalPattern + `(?:, |$)`)
+	selectStatement = regexp.MustCompile(`^SELECT (.*?) ?FROM (\(.+\)|` + classPattern + `)(?: WHERE (.+?))?(?: SKIP (\d+))?(?: LIMIT (-?\d+))?$`)
+	selectExpand    = regexp.MustCompile(`^SELECT expand\(` + hopsPattern + `\)((?:\.(?:out|in|both)\(\))*) FROM (.+)$`)
+	selectPath      = regexp.MustCompile(`^SELECT expand\(path\) FROM \(SELECT shortestPath\(\((SELECT .+?)\), \((SELECT .+?)\), \{maxDepth: (\d+)\}\) AS path\) UNWIND path$`)
+	traverse        = regexp.MustCompile(`^TRAVERSE (out|in|both)\(\) FROM \((SELECT .+?)\)(?: WHILE (.+?))?(?: STRATEGY (BREADTH_FIRST|DEPTH_FIRST))?$`)
+
+	whereName     = regexp.MustCompile(`^name = ` + stringPattern + `$`)
+	whereNameIn   = regexp.MustCompile(`^name IN \[(.*)\]$`)
+	whereSize     = regexp.MustCompile(`^(out|in|both)\(\)\.size\(\) (=|<>|<=|>=|<|>) (\d+)$`)
+	whereSizeOf   = regexp.MustCompile(`^(out|in|both)\(\)\.size\(\) = \(SELECT (max|min)\((out|in|both)\(\)\.size\(\)\) FROM ` + classPattern + `(?: WHERE (.+))?\)$`)
+	whileDepth    = regexp.MustCompile(`^\$depth (<=|<) (\d+)$`)
+	whileNot      = regexp.MustCompile(`^name (?:<>|!=) ` + stringPattern + `$`)
+	stringLiteral = regexp.MustCompile(stringPattern)
+
+	countAll      = regexp.MustCompile(`^count\(\*\)$`)
+	countDistinct = regexp.MustCompile(`^count\(distinct\((\w+)\)\)$`)
+	aggregate     = regexp.MustCompile(`^(sum|max|min)\((@version|\w+)\)$`)
+	degree        = regexp.MustCompile(`^(out|in|both)\(\)\.size\(\)$`)
+	neighborField = regexp.MustCompile(`^(out|in|both)\(\)\.(\w+) AS (\w+)$`)
+	field         = regexp.MustCompile(`^(@rid|@class|@version|\w+)$`)
+)
+
+// unsupported is the error for SQL outside the subset the server knows
+func unsupported(sql string) error {
+	return errorf(http.StatusInternalServerError, parsingException, "Statement not supported by orientdbtest: %s", sql)
+}
+
+// execute runs one SQL statement and returns its result rows
+func (db *database) execute(sql string) ([]map[string]interface{}, error) {
+	sql = strings.TrimSpace(sql)
+	if alterDatabase.MatchString(sql) {
+		return nil, nil
+	}
+	if m := createClass.FindStringSubmatch(sql); m != nil {
+		return nil, db.createClass(m[1], m[2])
+	}
+	if m := createIndex.FindStringSubmatch(sql); m != nil {
+		return nil, db.createIndex(m[1], m[2], m[3] == "UNIQUE")
+	}
+	if m := createFullText.FindStringSubmatch(sql); m != nil {
+		// accepted, but SEARCH_INDEX queries are not supported
+		if !db.hasClass(m[1]) {
+			return nil, errorf(http.StatusInternalServerError, executionException, "Class not found: %s", m[1])
+		}
+		return nil, nil
+	}
+	if m := createEdge.FindStringSubmatch(sql); m != nil {
+		edge, err := db.createEdge(m[1], m[2], m[3])
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]interface{}{edge.document()}, nil
+	}
+	if m := updateRID.FindStringSubmatch(sql); m != nil {
+		fields, err := parseAssignments(m[2], sql)
+		if err != nil {
+			return nil, err
+		}
+		r := db.byRID[m[1]]
+		if r == nil {
+			return countRow(0), nil
+		}
+		return countRow(1), db.update(r, fields)
+	}
+	if m := updateWhere.FindStringSubmatch(sql); m != nil {
+		return db.updateWhere(m[1], m[2], m[3] != "", m[4], sql)
+	}
+	return db.query(sql)
+}
+
+// countRow is the result of an UPDATE
+func countRow(n int) []map[string]interface{} {
+	return []map[string]interface{}{{"count": n}}
+}
+
+// updateWhere sets fields on the records of class matching where, or
+// creates one with UPSERT if none matches
+func (db *database) updateWhere(class, assignments string, upsert bool, where, sql string) ([]map[string]interface{}, error) {
+	fields, err := parseAssignments(assignments, sql)
+	if err != nil {
+		return nil, err
+	}
+	records, err := db.classRecords(class)
+	if err != nil {
+		return nil, err
+	}
+	match, err := db.condition(where, sql)
+	if err != nil {
+		return nil, err
+	}
+	count := 0
+	for _, r := range records {
+		if !match(r) {
+			continue
+		}
+		if err := db.update(r, fields); err != nil {
+			return nil, err
+		}
+		count++
+	}
+	if count == 0 && upsert {
+		if _, err := db.insert(class, fields); err != nil {
+			return nil, err
+		}
+		count = 1
+	}
+	return countRow(count), nil
+}
+
+// parseAssignments parses "a = 1, b = 'x'" into fields
+func parseAssignments(text, sql string) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for text != "" {
+		m := assignment.FindStringSubmatch(text)
+		if m == nil {
+			return nil, unsupported(sql)
+		}
+		fields[m[1]] = parseLiteral(m[2])
+		text = text[len(m[0]):]
+	}
+	return fields, nil
+}
+
+// parseLiteral converts an SQL literal to the value stored for it
+func parseLiteral(text string) interface{} {
+	switch text {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		return unquote(text)
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n
+	}
+	f, _ := strconv.ParseFloat(text, 64)
+	return f
+}
+
+// unquote strips the quotes of a string literal and resolves its escapes
+func unquote(text string) string {
+	inner := text[1 : len(text)-1]
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		b.WriteByte(inner[i])
+	}
+	return b.String()
+}
+
+// query runs a SELECT or TRAVERSE
+func (db *database) query(sql string) ([]map[string]interface{}, error) {
+	if selectExpand.MatchString(sql) || selectPath.MatchString(sql) || traverse.MatchString(sql) {
+		records, err := db.resolve(sql)
+		if err != nil {
+			return nil, err
+		}
+		return documents(records), nil
+	}
+	m := selectStatement.FindStringSubmatch(sql)
+	if m == nil {
+		return nil, unsupported(sql)
+	}
+	records, err := db.selectRecords(m, sql)
+	if err != nil {
+		return nil, err
+	}
+	return db.project(records, m[1], sql)
+}
+
+// documents returns the records as REST documents
+func documents(records []*record) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(records))
+	for i, r := range records {
+		result[i] = r.document()
+	}
+	return result
+}
+
+// resolve runs a query whose rows are whole records, as used in FROM and
+// TRAVERSE
+func (db *database) resolve(sql string) ([]*record, error) {
+	if m := traverse.FindStringSubmatch(sql); m != nil {
+		starts, err := db.resolve(m[2])
+		if err != nil {
+			return nil, err
+		}
+		allowed, err := whileCondition(m[3], sql)
+		if err != nil {
+			return nil, err
+		}
+		return db.traverse(starts, m[1], allowed, m[4] == "BREADTH_FIRST"), nil
+	}
+	if m := selectPath.FindStringSubmatch(sql); m != nil {
+		src, err := db.resolve(m[1])
+		if err != nil {
+			return nil, err
+		}
+		dst, err := db.resolve(m[2])
+		if err != nil {
+			return nil, err
+		}
+		maxDepth, _ := strconv.Atoi(m[3])
+		if len(src) == 0 || len(dst) == 0 {
+			return nil, nil
+		}
+		return db.shortestPath(src[0], dst[0], maxDepth), nil
+	}
+	if m := selectExpand.FindStringSubmatch(sql); m != nil {
+		current, err := db.resolve("SELECT FROM " + m[3])
+		if err != nil {
+			return nil, err
+		}
+		hops := strings.Split(m[1]+m[2], ".")
+		for _, hop := range hops {
+			var next []*record
+			for _, r := range current {
+				next = append(next, db.neighbors(r, strings.TrimSuffix(hop, "()"))...)
+			}
+			current = next
+		}
+		return current, nil
+	}
+	m := selectStatement.FindStringSubmatch(sql)
+	if m == nil || (m[1] != "" && m[1] != "*") {
+		return nil, unsupported(sql)
+	}
+	return db.selectRecords(m, sql)
+}
+
+// selectRecords returns the records a SELECT reads, given the submatches
+// of selectStatement
+func (db *database) selectRecords(m []string, sql string) ([]*record, error) {
+	var records []*record
+	var err error
+	if m[3] != "" {
+		records, err = db.classRecords(m[3])
+	} else {
+		records, err = db.resolve(m[2][1 : len(m[2])-1])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m[4] != "" {
+		match, err := db.condition(m[4], sql)
+		if err != nil {
+			return nil, err
+		}
+		filtered := records[:0:0]
+		for _, r := range records {
+			if match(r) {
+				filtered = append(filtered, r)
+			}
+		}
+		records = filtered
+	}
+	if m[5] != "" {
+		skip, _ := strconv.Atoi(m[5])
+		records = records[min(skip, len(records)):]
+	}
+	if m[6] != "" {
+		if limit, _ := strconv.Atoi(m[6]); limit >= 0 && limit < len(records) {
+			records = records[:limit]
+		}
+	}
+	return records, nil
+}
+
+// condition compiles a WHERE clause
+func (db *database) condition(where, sql string) (func(*record) bool, error) {
+	if m := whereName.FindStringSubmatch(where); m != nil {
+		name := unquote(m[1])
+		return func(r *record) bool { return r.fields["name"] == name }, nil
+	}
+	if m := whereNameIn.FindStringSubmatch(where); m != nil {
+		var names []string
+		for _, literal := range stringLiteral.FindAllString(m[1], -1) {
+			names = append(names, unquote(literal))
+		}
+		return func(r *record) bool {
+			name, ok := r.fields["name"].(string)
+			return ok && slices.Contains(names, name)
+		}, nil
+	}
+	if m := whereSize.FindStringSubmatch(where); m != nil {
+		n, _ := strconv.Atoi(m[3])
+		return func(r *record) bool { return compare(len(db.neighbors(r, m[1])), m[2], n) }, nil
+	}
+	if m := whereSizeOf.FindStringSubmatch(where); m != nil {
+		candidates, err := db.classRecords(m[4])
+		if err != nil {
+			return nil, err
+		}
+		match := func(*record) bool { return true }
+		if m[5] != "" {
+			if match, err = db.condition(m[5], sql); err != nil {
+				return nil, err
+			}
+		}
+		found := false
+		best := 0
+		for _, r := range candidates {
+			if !match(r) {
+				continue
+			}
+			size := len(db.neighbors(r, m[3]))
+			if !found || (m[2] == "max" && size > best) || (m[2] == "min" && size < best) {
+				best = size
+			}
+			found = true
+		}
+		// comparing with the null of an empty subquery matches nothing
+		return func(r *record) bool { return found && len(db.neighbors(r, m[1])) == best }, nil
+	}
+	return nil, unsupported(sql)
+}
+
+// compare applies an SQL comparison operator
+func compare(a int, op string, b int) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "<>":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// whileCondition compiles the WHILE clause of a TRAVERSE, a conjunction of
+// depth limits and excluded names
+func whileCondition(while, sql string) (func(*record, int) bool, error) {
+	maxDepth := -1
+	var excluded []string
+	if while != "" {
+		for _, term := range strings.Split(while, " AND ") {
+			if m := whileDepth.FindStringSubmatch(term); m != nil {
+				limit, _ := strconv.Atoi(m[2])
+				if m[1] == "<" {
+					limit--
+				}
+				if maxDepth < 0 || limit < maxDepth {
+					maxDepth = limit
+				}
+			} else if m := whileNot.FindStringSubmatch(term); m != nil {
+				excluded = append(excluded, unquote(m[1]))
+			} else {
+				return nil, unsupported(sql)
+			}
+		}
+	}
+	return func(r *record, depth int) bool {
+		name, _ := r.fields["name"].(string)
+		return (maxDepth < 0 || depth <= maxDepth) && !slices.Contains(excluded, name)
+	}, nil
+}
+
+// project computes the projection of a SELECT over records. Aggregates
+// return a single row, also over no records.
+func (db *database) project(records []*record, projection, sql string) ([]map[string]interface{}, error) {
+	if projection == "" || projection == "*" {
+		return documents(records), nil
+	}
+	items := strings.Split(projection, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+
+	if countAll.MatchString(items[0]) || countDistinct.MatchString(items[0]) || aggregate.MatchString(items[0]) {
+		row := map[string]interface{}{"@type": "d", "@version": 0}
+		for _, item := range items {
+			value, err := aggregateValue(records, item, sql)
+			if err != nil {
+				return nil, err
+			}
+			row[item] = value
+		}
+		return []map[string]interface{}{row}, nil
+	}
+
+	result := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		row := map[string]interface{}{"@type": "d", "@version": 0}
+		for _, item := range items {
+			switch {
+			case degree.MatchString(item):
+				row[item] = len(db.neighbors(r, degree.FindStringSubmatch(item)[1]))
+			case neighborField.MatchString(item):
+				m := neighborField.FindStringSubmatch(item)
+				values := []interface{}{}
+				for _, n := range db.neighbors(r, m[1]) {
+					if value, ok := n.fields[m[2]]; ok {
+						values = append(values, value)
+					}
+				}
+				row[m[3]] = values
+			case field.MatchString(item):
+				if value, ok := r.document()[item]; ok {
+					row[item] = value
+				}
+			default:
+				return nil, unsupported(sql)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// aggregateValue computes one aggregate projection. sum, max and min of no
+// values are null.
+func aggregateValue(records []*record, item, sql string) (interface{}, error) {
+	if countAll.MatchString(item) {
+		return len(records), nil
+	}
+	if m := countDistinct.FindStringSubmatch(item); m != nil {
+		distinct := make(map[interface{}]bool)
+		for _, r := range records {
+			if value, ok := r.fields[m[1]]; ok {
+				distinct[value] = true
+			}
+		}
+		return len(distinct), nil
+	}
+	m := aggregate.FindStringSubmatch(item)
+	if m == nil {
+		return nil, unsupported(sql)
+	}
+	var result interface{}
+	for _, r := range records {
+		value, ok := number(r.document()[m[2]])
+		if !ok {
+			continue
+		}
+		current, _ := number(result)
+		switch {
+		case result == nil:
+			result = value
+		case m[1] == "sum":
+			result = current + value
+		case m[1] == "max" && value > current:
+			result = value
+		case m[1] == "min" && value < current:
+			result = value
+		}
+	}
+	return result, nil
+}
+
+// number converts a stored numeric value
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -8,12 +8,28 @@ import (
 	"strings"
 )
 
-const (
-	orientDBBaseURL  = "http://orientdb:2480"
-	orientDBUsername = "root"
-	orientDBPassword = "rootpwd"
-	databaseName     = "dbcli"
-)
+// Connection is the address and credentials of an OrientDB database
+type Connection struct {
+	BaseURL  string
+	Username string
+	Password string
+	Database string
+}
+
+// OrientDB is the database every command talks to. The defaults match
+// docker-compose.yml; the root command overrides them with its flags.
+var OrientDB = Connection{
+	BaseURL:  "http://orientdb:2480",
+	Username: "root",
+	Password: "rootpwd",
+	Database: "dbcli",
+}
+
+// URL returns the address of a REST endpoint of the database, e.g.
+// URL("command", "sql") for <base>/command/<database>/sql
+func (c Connection) URL(endpoint string, path ...string) string {
+	return strings.Join(append([]string{c.BaseURL, endpoint, c.Database}, path...), "/")
+}
 
 type CommandBody struct {
 	Command    string                 `json:"command"`
@@ -26,7 +42,7 @@ type ResultSet struct {
 
 func ExecuteQuery(command string) (ResultSet, error) {
 	var jsonBody ResultSet
-	url := fmt.Sprintf("%s/command/%s/sql", orientDBBaseURL, databaseName)
+	url := OrientDB.URL("command", "sql")
 	bodyCmd := CommandBody{
 		Command: command,
 	}
@@ -40,7 +56,7 @@ func ExecuteQuery(command string) (ResultSet, error) {
 	if err != nil {
 		return jsonBody, fmt.Errorf("failed to create command request: %w", err)
 	}
-	req.SetBasicAuth(orientDBUsername, orientDBPassword)
+	req.SetBasicAuth(OrientDB.Username, OrientDB.Password)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := http.DefaultClient.Do(req)
@@ -55,7 +71,6 @@ func ExecuteQuery(command string) (ResultSet, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
 		return jsonBody, fmt.Errorf("command failed with status: %d, body: %s", resp.StatusCode, string(body))
 	}
 
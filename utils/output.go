@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats accepted by WriteResultSet
+const (
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// Path is a list of vertex names. It prints as "a -> b -> c" in tables and
+// as a JSON array.
+type Path []string
+
+func (p Path) String() string {
+	return strings.Join(p, " -> ")
+}
+
+// WriteResultSet writes a result set as an aligned table or a Markdown table
+// with the given columns, or as indented JSON
+func WriteResultSet(w io.Writer, format string, rs ResultSet, columns []string) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rs)
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rs.Result {
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = formatCell(row[column])
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		return tw.Flush()
+	case FormatMarkdown:
+		fmt.Fprintf(w, "| %s |\n", strings.Join(columns, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(columns)))
+		for _, row := range rs.Result {
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = strings.ReplaceAll(formatCell(row[column]), "|", "\\|")
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, expected %s, %s or %s", format, FormatTable, FormatJSON, FormatMarkdown)
+	}
+}
+
+// formatCell renders a single value for table output
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return fmt.Sprintf("%.6g", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
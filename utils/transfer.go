@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// TransferStats counts the requests sent to OrientDB. ServerTime is the time
+// from sending a request until its response headers arrive, which for the
+// REST API is the server-side execution time plus one round trip.
+type TransferStats struct {
+	Requests      int64
+	BytesSent     int64
+	BytesReceived int64
+	ServerTime    time.Duration
+}
+
+// Sub returns the counts accumulated since before
+func (s TransferStats) Sub(before TransferStats) TransferStats {
+	return TransferStats{
+		Requests:      s.Requests - before.Requests,
+		BytesSent:     s.BytesSent - before.BytesSent,
+		BytesReceived: s.BytesReceived - before.BytesReceived,
+		ServerTime:    s.ServerTime - before.ServerTime,
+	}
+}
+
+var transfer struct {
+	requests, bytesSent, bytesReceived, serverTime atomic.Int64
+}
+
+// Transfer returns the totals since the process started
+func Transfer() TransferStats {
+	return TransferStats{
+		Requests:      transfer.requests.Load(),
+		BytesSent:     transfer.bytesSent.Load(),
+		BytesReceived: transfer.bytesReceived.Load(),
+		ServerTime:    time.Duration(transfer.serverTime.Load()),
+	}
+}
+
+// countingTransport records every round trip in the transfer counters
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	transfer.serverTime.Add(int64(time.Since(start)))
+	transfer.requests.Add(1)
+	if req.ContentLength > 0 {
+		transfer.bytesSent.Add(req.ContentLength)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = countingBody{resp.Body}
+	return resp, nil
+}
+
+// countingBody counts the response bytes as they are read
+type countingBody struct {
+	io.ReadCloser
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	transfer.bytesReceived.Add(int64(n))
+	return n, err
+}
+
+// every command talks to OrientDB through http.DefaultClient
+func init() {
+	http.DefaultClient.Transport = countingTransport{base: http.DefaultTransport}
+}
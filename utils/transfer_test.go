@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransferCountsDefaultClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"result":[]}`)
+	}))
+	defer server.Close()
+
+	before := Transfer()
+	resp, err := http.DefaultClient.Post(server.URL, "text/plain", strings.NewReader("SELECT 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	got := Transfer().Sub(before)
+	if got.Requests != 1 || got.BytesSent != 8 || got.BytesReceived != 13 || got.ServerTime <= 0 {
+		t.Errorf("Transfer = %+v, want 1 request, 8 bytes sent, 13 received", got)
+	}
+}